@@ -48,6 +48,22 @@ func initConfig() {
 	viper.SetDefault("ARCHIVE_DEST", "./pg_wal")
 	viper.SetDefault("DISK_PATH", ".")
 	viper.SetDefault("BLOCK_INTERVAL", 20)
+	viper.SetDefault("PG_VERSION", "auto")
+	viper.SetDefault("BYTE_ORDER", "auto")
+	viper.SetDefault("BLOCK_SIZE", 0)
+	viper.SetDefault("RELSEG_SIZE", 0)
+	viper.SetDefault("XLOG_BLOCK_SIZE", 0)
+	viper.SetDefault("WAL_SEGMENT_SIZE", 0)
+	viper.SetDefault("ERROR_POLICY", "skip-tuple")
+	viper.SetDefault("MAX_ERRORS", 0)
+	viper.SetDefault("WORKERS", 0)
+	viper.SetDefault("ORDERED_OUTPUT", false)
+	viper.SetDefault("IO_MODE", "pread")
+	viper.SetDefault("READ_AHEAD", 16)
+	viper.SetDefault("MAX_READ_RATE", "0")
+	viper.SetDefault("MAX_IOPS", 0)
+	viper.SetDefault("ALLOW_LIVE", false)
+	viper.SetDefault("DICTIONARY", "./pdu_dictionary.json")
 
 	// Read configuration from file
 	viper.SetConfigName("pdu")
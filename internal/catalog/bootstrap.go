@@ -0,0 +1,442 @@
+package catalog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/internal/relmap"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Bootstrap reads pg_database from global and, for every database, its
+// pg_class, pg_namespace, pg_attribute and pg_type, and returns the model
+// they describe. Only the current version of each catalog row is used.
+// Damaged pages and rows that cannot be decoded are skipped with a warning,
+// as are databases whose catalogs cannot be read, so that as much metadata
+// as possible is recovered.
+func Bootstrap(ctx context.Context, c *cluster.Cluster) (*Catalog, error) {
+	b := &bootstrapper{
+		ctx:     ctx,
+		cluster: c,
+		profile: c.Params.Profile,
+		order:   c.Params.ByteOrder,
+		clog:    NewCommitLog(c),
+		catalog: &Catalog{
+			FormatVersion: DictionaryVersion,
+			PGData:        c.PGData,
+			PGVersion:     c.Params.Profile.Name,
+			Created:       time.Now(),
+		},
+		maps: make(map[uint32]*relmap.RelationMap),
+	}
+	if c.Control != nil {
+		b.catalog.SystemIdentifier = c.Control.SystemIdentifier
+	}
+
+	// Databases
+	path := c.RelationPath(cluster.GlobalTablespaceOid, 0, b.mappedFileNode(nil, DatabaseRelationId))
+	if err := b.readDatabases(path); err != nil {
+		return nil, fmt.Errorf("pg_database: %v", err)
+	}
+
+	// Catalogs of each database
+	for _, db := range b.catalog.Databases {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := b.readDatabase(db); err != nil {
+			b.warnf("database %s (%d): %v", db.Name, db.Oid, err)
+		}
+		db.index()
+	}
+
+	return b.catalog, nil
+}
+
+// bootstrapper holds the state of Bootstrap.
+type bootstrapper struct {
+	ctx     context.Context
+	cluster *cluster.Cluster
+	profile *pgtypes.Profile
+	order   binary.ByteOrder
+	clog    *CommitLog
+	catalog *Catalog
+
+	// Relation maps by database OID, 0 for global; nil if unreadable
+	maps map[uint32]*relmap.RelationMap
+}
+
+// warnf records a warning in the catalog.
+func (b *bootstrapper) warnf(format string, args ...interface{}) {
+	b.catalog.Warnings = append(b.catalog.Warnings, fmt.Sprintf(format, args...))
+}
+
+// relationMap returns the relation map of a database, or of global for a
+// nil database, reading it on first use. It returns nil if the map cannot be read.
+func (b *bootstrapper) relationMap(db *Database) *relmap.RelationMap {
+	key, dir, name := uint32(0), b.cluster.DatabasePath(cluster.GlobalTablespaceOid, 0), "global"
+	if db != nil {
+		key, dir, name = db.Oid, b.cluster.DatabasePath(db.TablespaceOid, db.Oid), "database "+db.Name
+	}
+	if m, ok := b.maps[key]; ok {
+		return m
+	}
+
+	m, err := relmap.ReadRelationMap(dir)
+	if err != nil {
+		b.warnf("%s: cannot read relation map: %v; assuming mapped catalogs have their initial relfilenodes", name, err)
+		m = nil
+	} else if !m.CRCValid {
+		b.warnf("%s: %s: CRC mismatch, contents may be unreliable", name, relmap.Path(dir))
+	}
+	b.maps[key] = m
+	return m
+}
+
+// mappedFileNode returns the relfilenode of a mapped catalog, whose pg_class
+// entry has relfilenode 0, from the relation map of a database or, for a nil
+// database, of global. Without a map entry the catalog is assumed to be in
+// the file initdb created for it, whose relfilenode equals its OID.
+func (b *bootstrapper) mappedFileNode(db *Database, relation uint32) uint32 {
+	m := b.relationMap(db)
+	if m == nil {
+		return relation
+	}
+	if fileNode, ok := m.FileNode(relation); ok {
+		return fileNode
+	}
+	where := "global"
+	if db != nil {
+		where = "database " + db.Name
+	}
+	b.warnf("%s: relation %d is not in the relation map; assuming relfilenode %d", where, relation, relation)
+	return relation
+}
+
+// scan passes the current version of every row of a catalog heap to fn.
+// Errors returned by fn, like rows that fail to decode, are recorded as
+// warnings and the row is skipped.
+func (b *bootstrapper) scan(name, path string, fn func(r *row) error) error {
+	attrs, err := descriptor(b.profile, name)
+	if err != nil {
+		return err
+	}
+	deformer := decoder.NewDeformer(attrs, b.order)
+
+	reader := fileio.NewSegmentedFileReader(fileio.MAIN_FORKNUM, b.cluster.Params)
+	reader.SetIOOptions(b.cluster.IO)
+	processor := pager.NewPageProcessor(reader, pager.NewPageParser(b.cluster.Params))
+	if err := processor.Open(path); err != nil {
+		return err
+	}
+	defer processor.Close()
+
+	err = processor.ForEachTuple(b.ctx, func(t *pager.ScannedTuple) error {
+		if !b.clog.Visible(t.Tuple.Header) {
+			return nil
+		}
+		datums, err := deformer.Deform(t.Tuple)
+		if err == nil {
+			err = fn(newRow(t.Tuple, datums, b.order, !b.profile.CatalogOidColumn))
+		}
+		if err != nil {
+			b.warnf("%s: %s block %d item %d: %v", name, path, t.Block, t.Item, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if damage := processor.Damage(); damage.Damaged() {
+		b.warnf("%s: %s is damaged, %d pages and rows skipped", name, path, damage.Errors())
+	}
+	return nil
+}
+
+// versions remembers the xmin of the newest version of each row seen, so
+// that a row left with several current versions is only used once.
+type versions map[interface{}]uint32
+
+// newest reports whether the version of a row inserted by xmin is newer
+// than the versions seen so far, and records it if so.
+func (v versions) newest(key interface{}, xmin uint32) bool {
+	if seen, ok := v[key]; ok && !newer(xmin, seen) {
+		return false
+	}
+	v[key] = xmin
+	return true
+}
+
+// readDatabases reads pg_database.
+func (b *bootstrapper) readDatabases(path string) error {
+	databases := make(map[uint32]*Database)
+	seen := make(versions)
+	err := b.scan("pg_database", path, func(r *row) error {
+		db := &Database{
+			Oid:           r.oid(),
+			Name:          r.name("datname"),
+			Encoding:      r.int32("encoding"),
+			TablespaceOid: r.uint32("dattablespace"),
+			IsTemplate:    r.bool("datistemplate"),
+			AllowConn:     r.bool("datallowconn"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if seen.newest(db.Oid, r.tuple.Header.THeap.TXmin) {
+			databases[db.Oid] = db
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, db := range databases {
+		b.catalog.Databases = append(b.catalog.Databases, db)
+	}
+	sort.Slice(b.catalog.Databases, func(i, j int) bool {
+		return b.catalog.Databases[i].Oid < b.catalog.Databases[j].Oid
+	})
+	return nil
+}
+
+// readDatabase reads the catalogs of a database. pg_class comes first, as it
+// says where pg_namespace is; pg_class, pg_attribute and pg_type are mapped.
+func (b *bootstrapper) readDatabase(db *Database) error {
+	path := func(fileNode uint32) string {
+		return b.cluster.RelationPath(db.TablespaceOid, db.Oid, fileNode)
+	}
+	if _, err := os.Stat(b.cluster.DatabasePath(db.TablespaceOid, db.Oid)); err != nil {
+		return err
+	}
+	classPath := path(b.mappedFileNode(db, RelationRelationId))
+
+	// Relations
+	if err := b.readRelations(db, classPath); err != nil {
+		return fmt.Errorf("pg_class: %v", err)
+	}
+	relations := make(map[uint32]*Relation, len(db.Relations))
+	for _, rel := range db.Relations {
+		relations[rel.Oid] = rel
+	}
+
+	// Schemas
+	namespaceFileNode := NamespaceRelationId
+	if rel := relations[NamespaceRelationId]; rel != nil {
+		namespaceFileNode = rel.FileNode
+	} else {
+		b.warnf("database %s: pg_namespace not found in pg_class; assuming relfilenode %d", db.Name, namespaceFileNode)
+	}
+	if err := b.readNamespaces(db, path(namespaceFileNode)); err != nil {
+		b.warnf("database %s: pg_namespace: %v", db.Name, err)
+	}
+
+	// Columns
+	attributePath := path(b.mappedFileNode(db, AttributeRelationId))
+	if err := b.readAttributes(relations, attributePath); err != nil {
+		b.warnf("database %s: pg_attribute: %v", db.Name, err)
+	}
+
+	// Types
+	typePath := path(b.mappedFileNode(db, TypeRelationId))
+	if err := b.readTypes(db, typePath); err != nil {
+		b.warnf("database %s: pg_type: %v", db.Name, err)
+	}
+
+	return nil
+}
+
+// readRelations reads pg_class.
+func (b *bootstrapper) readRelations(db *Database, path string) error {
+	relations := make(map[uint32]*Relation)
+	seen := make(versions)
+	err := b.scan("pg_class", path, func(r *row) error {
+		rel := &Relation{
+			Oid:           r.oid(),
+			Name:          r.name("relname"),
+			NamespaceOid:  r.uint32("relnamespace"),
+			FileNode:      r.uint32("relfilenode"),
+			TablespaceOid: r.uint32("reltablespace"),
+			Kind:          Char(r.char("relkind")),
+			Persistence:   Char(r.char("relpersistence")),
+			IsShared:      r.bool("relisshared"),
+			NAtts:         r.int16("relnatts"),
+			ToastRelid:    r.uint32("reltoastrelid"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if rel.FileNode == 0 && hasStorage(rel.Kind) {
+			rel.Mapped = true
+			if rel.IsShared {
+				rel.FileNode = b.mappedFileNode(nil, rel.Oid)
+			} else {
+				rel.FileNode = b.mappedFileNode(db, rel.Oid)
+			}
+		}
+		if seen.newest(rel.Oid, r.tuple.Header.THeap.TXmin) {
+			relations[rel.Oid] = rel
+		}
+		return nil
+	})
+	for _, rel := range relations {
+		db.Relations = append(db.Relations, rel)
+	}
+	return err
+}
+
+// hasStorage reports whether relations of a kind have data files.
+func hasStorage(kind Char) bool {
+	switch kind {
+	case RELKIND_RELATION, RELKIND_INDEX, RELKIND_SEQUENCE, RELKIND_TOASTVALUE, RELKIND_MATVIEW:
+		return true
+	}
+	return false
+}
+
+// readNamespaces reads pg_namespace.
+func (b *bootstrapper) readNamespaces(db *Database, path string) error {
+	namespaces := make(map[uint32]*Namespace)
+	seen := make(versions)
+	err := b.scan("pg_namespace", path, func(r *row) error {
+		ns := &Namespace{
+			Oid:  r.oid(),
+			Name: r.name("nspname"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if seen.newest(ns.Oid, r.tuple.Header.THeap.TXmin) {
+			namespaces[ns.Oid] = ns
+		}
+		return nil
+	})
+	for _, ns := range namespaces {
+		db.Namespaces = append(db.Namespaces, ns)
+	}
+	return err
+}
+
+// attributeKey identifies a pg_attribute row.
+type attributeKey struct {
+	relation uint32
+	attnum   int16
+}
+
+// readAttributes reads pg_attribute and adds the user columns to their
+// relations, with the missing values of columns added with a default since
+// the oldest rows were written.
+func (b *bootstrapper) readAttributes(relations map[uint32]*Relation, path string) error {
+	columns := make(map[attributeKey]Column)
+	missingErrs := make(map[attributeKey]error)
+	seen := make(versions)
+	err := b.scan("pg_attribute", path, func(r *row) error {
+		key := attributeKey{relation: r.uint32("attrelid"), attnum: r.int16("attnum")}
+		col := Column{
+			Name:      r.name("attname"),
+			AttNum:    key.attnum,
+			TypeOid:   r.uint32("atttypid"),
+			Len:       r.int16("attlen"),
+			Align:     Char(r.char("attalign")),
+			ByVal:     r.bool("attbyval"),
+			Storage:   Char(r.char("attstorage")),
+			TypMod:    r.int32("atttypmod"),
+			NotNull:   r.bool("attnotnull"),
+			IsDropped: r.bool("attisdropped"),
+		}
+		var missing []byte
+		if b.profile.AttributeMissingValues {
+			col.HasMissing = r.bool("atthasmissing")
+			missing = r.nullable("attmissingval")
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if key.attnum > 0 && seen.newest(key, r.tuple.Header.THeap.TXmin) {
+			delete(missingErrs, key)
+			if col.HasMissing && missing != nil {
+				col.MissingValue, missingErrs[key] = b.missingValue(col, missing)
+			}
+			columns[key] = col
+		}
+		return nil
+	})
+
+	for key, err := range missingErrs {
+		if err != nil {
+			b.warnf("pg_attribute: %s: relation %d column %s: attmissingval: %v; rows older than the column read it as null",
+				path, key.relation, columns[key].Name, err)
+		}
+	}
+	for key, col := range columns {
+		if rel := relations[key.relation]; rel != nil {
+			rel.Columns = append(rel.Columns, col)
+		}
+	}
+	for _, rel := range relations {
+		sort.Slice(rel.Columns, func(i, j int) bool { return rel.Columns[i].AttNum < rel.Columns[j].AttNum })
+	}
+	return err
+}
+
+// missingValue decodes attmissingval, a one-element array of the column's
+// type, and returns a copy of the element as a tuple would store it.
+func (b *bootstrapper) missingValue(col Column, data []byte) ([]byte, error) {
+	elem := col.Attribute()
+	array, err := decoder.ArrayElements(data, &elem, b.order)
+	if err != nil {
+		return nil, err
+	}
+	if array.ElemType != col.TypeOid {
+		return nil, fmt.Errorf("element type %d, expected %d", array.ElemType, col.TypeOid)
+	}
+	if len(array.Elements) != 1 {
+		return nil, fmt.Errorf("%d elements, expected 1", len(array.Elements))
+	}
+	if array.Elements[0].IsNull {
+		return nil, nil
+	}
+	return append([]byte(nil), array.Elements[0].Data...), nil
+}
+
+// readTypes reads pg_type.
+func (b *bootstrapper) readTypes(db *Database, path string) error {
+	types := make(map[uint32]*Type)
+	seen := make(versions)
+	err := b.scan("pg_type", path, func(r *row) error {
+		t := &Type{
+			Oid:          r.oid(),
+			Name:         r.name("typname"),
+			NamespaceOid: r.uint32("typnamespace"),
+			Len:          r.int16("typlen"),
+			ByVal:        r.bool("typbyval"),
+			Type:         Char(r.char("typtype")),
+			Align:        Char(r.char("typalign")),
+			Storage:      Char(r.char("typstorage")),
+			ElemOid:      r.uint32("typelem"),
+			RelId:        r.uint32("typrelid"),
+			BaseTypeOid:  r.uint32("typbasetype"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if seen.newest(t.Oid, r.tuple.Header.THeap.TXmin) {
+			types[t.Oid] = t
+		}
+		return nil
+	})
+	for _, t := range types {
+		db.Types = append(db.Types, t)
+	}
+	return err
+}
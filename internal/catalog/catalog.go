@@ -0,0 +1,365 @@
+// Package catalog reads the system catalogs of a cluster from its data files
+// and models the databases, schemas, relations, columns and types in them.
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/decoder"
+)
+
+// OIDs of the system catalogs read by Bootstrap
+const (
+	TypeRelationId      uint32 = 1247 // pg_type
+	AttributeRelationId uint32 = 1249 // pg_attribute
+	RelationRelationId  uint32 = 1259 // pg_class
+	DatabaseRelationId  uint32 = 1262 // pg_database
+	NamespaceRelationId uint32 = 2615 // pg_namespace
+)
+
+// OIDs of the schemas every database has
+const (
+	PG_CATALOG_NAMESPACE uint32 = 11   // pg_catalog
+	PG_TOAST_NAMESPACE   uint32 = 99   // pg_toast
+	PG_PUBLIC_NAMESPACE  uint32 = 2200 // public
+)
+
+// FirstNormalObjectId is the first OID assigned to objects created after initdb.
+const FirstNormalObjectId uint32 = 16384
+
+// Relation kinds (pg_class.relkind)
+const (
+	RELKIND_RELATION          = 'r' // ordinary table
+	RELKIND_INDEX             = 'i' // secondary index
+	RELKIND_SEQUENCE          = 'S' // sequence object
+	RELKIND_TOASTVALUE        = 't' // for out-of-line values
+	RELKIND_VIEW              = 'v' // view
+	RELKIND_MATVIEW           = 'm' // materialized view
+	RELKIND_COMPOSITE_TYPE    = 'c' // composite type
+	RELKIND_FOREIGN_TABLE     = 'f' // foreign table
+	RELKIND_PARTITIONED_TABLE = 'p' // partitioned table
+	RELKIND_PARTITIONED_INDEX = 'I' // partitioned index
+)
+
+// Catalog is the metadata of all databases of a cluster. It is saved as the
+// metadata dictionary, so its fields carry the dictionary's JSON names.
+type Catalog struct {
+	// Dictionary format, DictionaryVersion when written by this version of PDU
+	FormatVersion int `json:"format_version"`
+
+	// Cluster the catalogs were read from, and when
+	PGData           string    `json:"pgdata"`
+	PGVersion        string    `json:"pg_version"`                  // major version, as in PG_VERSION
+	SystemIdentifier uint64    `json:"system_identifier,omitempty"` // from pg_control, if readable
+	Created          time.Time `json:"created"`
+
+	// Databases ordered by OID
+	Databases []*Database `json:"databases"`
+
+	// Problems found while reading the catalogs
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Database returns the database with the given name, or nil.
+func (c *Catalog) Database(name string) *Database {
+	for _, db := range c.Databases {
+		if db.Name == name {
+			return db
+		}
+	}
+	return nil
+}
+
+// DatabaseByOid returns the database with the given OID, or nil.
+func (c *Catalog) DatabaseByOid(oid uint32) *Database {
+	for _, db := range c.Databases {
+		if db.Oid == oid {
+			return db
+		}
+	}
+	return nil
+}
+
+// Database is the metadata of one database, from pg_database and the
+// database's own pg_namespace, pg_class, pg_attribute and pg_type.
+type Database struct {
+	Oid           uint32 `json:"oid"`            // pg_database.oid
+	Name          string `json:"name"`           // datname
+	Encoding      int32  `json:"encoding"`       // encoding, as a pg_enc number
+	TablespaceOid uint32 `json:"tablespace_oid"` // dattablespace, where the database's files are
+	IsTemplate    bool   `json:"is_template"`    // datistemplate
+	AllowConn     bool   `json:"allow_conn"`     // datallowconn
+
+	// Schemas, relations and types, ordered by OID
+	Namespaces []*Namespace `json:"schemas"`
+	Relations  []*Relation  `json:"relations"`
+	Types      []*Type      `json:"types"`
+
+	// Lookup tables, built by index
+	namespaces map[uint32]*Namespace
+	relations  map[uint32]*Relation
+	fileNodes  map[fileNode]*Relation
+	types      map[uint32]*Type
+}
+
+// fileNode identifies the files of a relation: relfilenodes are only unique
+// within a tablespace.
+type fileNode struct {
+	tablespace uint32
+	fileNode   uint32
+}
+
+// index sorts the database's objects by OID, builds the lookup tables and
+// resolves the schema and type names of relations and columns.
+func (d *Database) index() {
+	sort.Slice(d.Namespaces, func(i, j int) bool { return d.Namespaces[i].Oid < d.Namespaces[j].Oid })
+	sort.Slice(d.Relations, func(i, j int) bool { return d.Relations[i].Oid < d.Relations[j].Oid })
+	sort.Slice(d.Types, func(i, j int) bool { return d.Types[i].Oid < d.Types[j].Oid })
+
+	d.namespaces = make(map[uint32]*Namespace, len(d.Namespaces))
+	for _, ns := range d.Namespaces {
+		d.namespaces[ns.Oid] = ns
+	}
+	d.types = make(map[uint32]*Type, len(d.Types))
+	for _, t := range d.Types {
+		d.types[t.Oid] = t
+	}
+	d.relations = make(map[uint32]*Relation, len(d.Relations))
+	d.fileNodes = make(map[fileNode]*Relation, len(d.Relations))
+	for _, rel := range d.Relations {
+		d.relations[rel.Oid] = rel
+		if rel.FileNode != 0 {
+			d.fileNodes[fileNode{d.Tablespace(rel.TablespaceOid), rel.FileNode}] = rel
+		}
+		if ns := d.namespaces[rel.NamespaceOid]; ns != nil {
+			rel.Schema = ns.Name
+		}
+		for i := range rel.Columns {
+			if t := d.types[rel.Columns[i].TypeOid]; t != nil {
+				rel.Columns[i].TypeName = t.Name
+			}
+		}
+	}
+}
+
+// Namespace returns the schema with the given name, or nil.
+func (d *Database) Namespace(name string) *Namespace {
+	for _, ns := range d.Namespaces {
+		if ns.Name == name {
+			return ns
+		}
+	}
+	return nil
+}
+
+// Relation returns the relation with the given schema and name, or nil. An
+// empty schema searches public, then pg_catalog.
+func (d *Database) Relation(schema, name string) *Relation {
+	if schema == "" {
+		if rel := d.Relation("public", name); rel != nil {
+			return rel
+		}
+		return d.Relation("pg_catalog", name)
+	}
+	for _, rel := range d.Relations {
+		if rel.Name == name && rel.Schema == schema {
+			return rel
+		}
+	}
+	return nil
+}
+
+// RelationByOid returns the relation with the given OID, or nil.
+func (d *Database) RelationByOid(oid uint32) *Relation {
+	return d.relations[oid]
+}
+
+// RelationByFileNode returns the relation stored in the given relfilenode of
+// a tablespace, or nil. Tablespace 0 is the database's tablespace.
+func (d *Database) RelationByFileNode(tablespace, node uint32) *Relation {
+	return d.fileNodes[fileNode{d.Tablespace(tablespace), node}]
+}
+
+// Type returns the type with the given OID, or nil.
+func (d *Database) Type(oid uint32) *Type {
+	return d.types[oid]
+}
+
+// UserTables returns the tables, materialized views and their TOAST tables
+// that hold user data, leaving out the system catalogs.
+func (d *Database) UserTables() []*Relation {
+	var tables []*Relation
+	for _, rel := range d.Relations {
+		if rel.IsSystem() {
+			continue
+		}
+		switch rel.Kind {
+		case RELKIND_RELATION, RELKIND_MATVIEW, RELKIND_TOASTVALUE:
+			tables = append(tables, rel)
+		}
+	}
+	return tables
+}
+
+// ToastRelation returns the TOAST table of a relation, or nil if it has none.
+func (d *Database) ToastRelation(rel *Relation) *Relation {
+	if rel.ToastRelid == 0 {
+		return nil
+	}
+	return d.relations[rel.ToastRelid]
+}
+
+// Tablespace resolves a reltablespace of the database to the OID of the
+// tablespace: 0 is the database's tablespace.
+func (d *Database) Tablespace(oid uint32) uint32 {
+	if oid == 0 {
+		oid = d.TablespaceOid
+	}
+	if oid == 0 {
+		oid = cluster.DefaultTablespaceOid
+	}
+	return oid
+}
+
+// RelationPath returns the relfilenode path of a relation of the database.
+func (d *Database) RelationPath(c *cluster.Cluster, rel *Relation) string {
+	return c.RelationPath(d.Tablespace(rel.TablespaceOid), d.Oid, rel.FileNode)
+}
+
+// Namespace is a schema, from pg_namespace.
+type Namespace struct {
+	Oid  uint32 `json:"oid"`  // pg_namespace.oid
+	Name string `json:"name"` // nspname
+}
+
+// Relation is a table, index, sequence, view or other pg_class entry.
+type Relation struct {
+	Oid           uint32 `json:"oid"`                 // pg_class.oid
+	Name          string `json:"name"`                // relname
+	NamespaceOid  uint32 `json:"schema_oid"`          // relnamespace
+	Schema        string `json:"schema"`              // name of the schema
+	FileNode      uint32 `json:"relfilenode"`         // relfilenode, resolved for mapped relations
+	Mapped        bool   `json:"mapped,omitempty"`    // relfilenode is 0 and the file is found through the relation map
+	TablespaceOid uint32 `json:"tablespace_oid"`      // reltablespace, 0 for the database's tablespace
+	Kind          Char   `json:"kind"`                // relkind (RELKIND_*)
+	Persistence   Char   `json:"persistence"`         // relpersistence: 'p', 'u' or 't'
+	IsShared      bool   `json:"shared,omitempty"`    // relisshared
+	NAtts         int16  `json:"natts"`               // relnatts
+	ToastRelid    uint32 `json:"toast_oid,omitempty"` // reltoastrelid, 0 if the relation has no TOAST table
+
+	// User columns in attnum order, including dropped ones
+	Columns []Column `json:"columns,omitempty"`
+}
+
+// QualifiedName returns the relation name qualified with its schema.
+func (r *Relation) QualifiedName() string {
+	if r.Schema == "" {
+		return r.Name
+	}
+	return r.Schema + "." + r.Name
+}
+
+// IsSystem reports whether the relation belongs to the system catalogs,
+// information_schema or the TOAST tables of the system catalogs.
+func (r *Relation) IsSystem() bool {
+	return r.NamespaceOid == PG_CATALOG_NAMESPACE || r.Schema == "information_schema" ||
+		(r.NamespaceOid == PG_TOAST_NAMESPACE && r.Oid < FirstNormalObjectId)
+}
+
+// Column returns the column with the given name, or nil.
+func (r *Relation) Column(name string) *Column {
+	for i := range r.Columns {
+		if r.Columns[i].Name == name && !r.Columns[i].IsDropped {
+			return &r.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Attributes returns the column descriptors the decoder needs to deform the
+// relation's tuples.
+func (r *Relation) Attributes() []decoder.Attribute {
+	attrs := make([]decoder.Attribute, len(r.Columns))
+	for i, col := range r.Columns {
+		attrs[i] = col.Attribute()
+	}
+	return attrs
+}
+
+// Column is a column of a relation, from pg_attribute.
+type Column struct {
+	Name       string `json:"name"`                  // attname
+	AttNum     int16  `json:"attnum"`                // attnum, starting at 1
+	TypeOid    uint32 `json:"type_oid"`              // atttypid, 0 for dropped columns
+	TypeName   string `json:"type,omitempty"`        // name of the type, if known
+	Len        int16  `json:"len"`                   // attlen
+	Align      Char   `json:"align"`                 // attalign
+	ByVal      bool   `json:"byval"`                 // attbyval
+	Storage    Char   `json:"storage"`               // attstorage
+	TypMod     int32  `json:"typmod"`                // atttypmod
+	NotNull    bool   `json:"not_null,omitempty"`    // attnotnull
+	IsDropped  bool   `json:"dropped,omitempty"`     // attisdropped
+	HasMissing bool   `json:"has_missing,omitempty"` // atthasmissing
+
+	// attmissingval: value of the column in rows written before it was
+	// added, as a tuple stores it; nil for null
+	MissingValue []byte `json:"missing_value,omitempty"`
+}
+
+// Attribute returns the column descriptor of the column.
+func (c Column) Attribute() decoder.Attribute {
+	return decoder.Attribute{
+		Name:         c.Name,
+		AttNum:       int(c.AttNum),
+		TypeOid:      c.TypeOid,
+		AttLen:       c.Len,
+		AttAlign:     byte(c.Align),
+		AttByVal:     c.ByVal,
+		AttStorage:   byte(c.Storage),
+		AttIsDropped: c.IsDropped,
+		Missing:      c.MissingValue,
+	}
+}
+
+// Type is a data type, from pg_type.
+type Type struct {
+	Oid          uint32 `json:"oid"`                     // pg_type.oid
+	Name         string `json:"name"`                    // typname
+	NamespaceOid uint32 `json:"schema_oid"`              // typnamespace
+	Len          int16  `json:"len"`                     // typlen
+	ByVal        bool   `json:"byval"`                   // typbyval
+	Type         Char   `json:"type"`                    // typtype: 'b', 'c', 'd', 'e', 'm', 'p' or 'r'
+	Align        Char   `json:"align"`                   // typalign
+	Storage      Char   `json:"storage"`                 // typstorage
+	ElemOid      uint32 `json:"elem_oid,omitempty"`      // typelem, the element type of arrays
+	RelId        uint32 `json:"relation_oid,omitempty"`  // typrelid, the relation of composite types
+	BaseTypeOid  uint32 `json:"base_type_oid,omitempty"` // typbasetype, the base type of domains
+}
+
+// Char is a "char" catalog value such as relkind or attalign. The dictionary
+// holds it as a one-character string, so that it can be read and edited.
+type Char byte
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Char) MarshalText() ([]byte, error) {
+	if c == 0 {
+		return []byte{}, nil
+	}
+	return []byte{byte(c)}, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Char) UnmarshalText(text []byte) error {
+	switch len(text) {
+	case 0:
+		*c = 0
+	case 1:
+		*c = Char(text[0])
+	default:
+		return fmt.Errorf("invalid char %q: must be a single character", text)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+)
+
+func TestRelationByFileNode(t *testing.T) {
+	// Relfilenodes are only unique within a tablespace
+	db := &Database{
+		Oid:           5,
+		TablespaceOid: cluster.DefaultTablespaceOid,
+		Relations: []*Relation{
+			{Oid: 16384, Name: "a", FileNode: 16384},
+			{Oid: 16390, Name: "b", FileNode: 16384, TablespaceOid: 16400},
+			{Oid: 1262, Name: "pg_database", FileNode: 1262, TablespaceOid: cluster.GlobalTablespaceOid, IsShared: true},
+		},
+	}
+	db.index()
+
+	tests := []struct {
+		tablespace uint32
+		fileNode   uint32
+		want       string
+	}{
+		{0, 16384, "a"},
+		{cluster.DefaultTablespaceOid, 16384, "a"},
+		{16400, 16384, "b"},
+		{16401, 16384, ""},
+		{cluster.GlobalTablespaceOid, 1262, "pg_database"},
+		{0, 1262, ""},
+	}
+	for _, test := range tests {
+		name := ""
+		if rel := db.RelationByFileNode(test.tablespace, test.fileNode); rel != nil {
+			name = rel.Name
+		}
+		if name != test.want {
+			t.Errorf("tablespace %d, relfilenode %d: %q, want %q", test.tablespace, test.fileNode, name, test.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// descriptor returns the hard-wired column descriptors of a system catalog
+// for the cluster's version. The catalogs cannot describe themselves until
+// they have been read, so their layout comes from the version profile.
+func descriptor(profile *pgtypes.Profile, name string) ([]decoder.Attribute, error) {
+	columns, ok := profile.Catalog(name)
+	if !ok {
+		return nil, fmt.Errorf("no descriptor for %s in %s", name, profile)
+	}
+
+	attrs := make([]decoder.Attribute, len(columns))
+	for i, col := range columns {
+		t, ok := profile.LookupType(col.Type)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: unknown type %s", name, col.Name, col.Type)
+		}
+		attrs[i] = decoder.Attribute{
+			Name:       col.Name,
+			AttNum:     i + 1,
+			TypeOid:    t.Oid,
+			AttLen:     t.Len,
+			AttAlign:   t.Align,
+			AttByVal:   t.ByVal,
+			AttStorage: t.Storage,
+		}
+	}
+	return attrs, nil
+}
+
+// row gives access to the columns of a deformed catalog tuple by name. The
+// accessors record the first error, so that a row can be read field by field
+// and checked once.
+type row struct {
+	tuple  *pager.Tuple
+	datums map[string]*decoder.Datum
+	order  binary.ByteOrder
+
+	// Catalog OIDs are in the tuple header instead of a column (before 12)
+	headerOid bool
+
+	err error
+}
+
+// newRow wraps the datums of a deformed catalog tuple.
+func newRow(tuple *pager.Tuple, datums []decoder.Datum, order binary.ByteOrder, headerOid bool) *row {
+	r := &row{
+		tuple:     tuple,
+		datums:    make(map[string]*decoder.Datum, len(datums)),
+		order:     order,
+		headerOid: headerOid,
+	}
+	for i := range datums {
+		r.datums[datums[i].Attr.Name] = &datums[i]
+	}
+	return r
+}
+
+// value returns the data of a non-null fixed-length column of at least size bytes.
+func (r *row) value(name string, size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	datum, ok := r.datums[name]
+	switch {
+	case !ok:
+		r.err = fmt.Errorf("no column %s", name)
+	case datum.IsNull:
+		r.err = fmt.Errorf("column %s is null", name)
+	case len(datum.Data) < size:
+		r.err = fmt.Errorf("column %s: %d bytes, expected %d", name, len(datum.Data), size)
+	default:
+		return datum.Data
+	}
+	return nil
+}
+
+// nullable returns the data of a column, or nil if it is null.
+func (r *row) nullable(name string) []byte {
+	if r.err != nil {
+		return nil
+	}
+	datum, ok := r.datums[name]
+	if !ok {
+		r.err = fmt.Errorf("no column %s", name)
+		return nil
+	}
+	return datum.Data
+}
+
+// oid returns the row's own OID.
+func (r *row) oid() uint32 {
+	if r.headerOid {
+		if r.tuple.Header.TInfomask&pgtypes.HEAP_HASOID == 0 && r.err == nil {
+			r.err = fmt.Errorf("tuple has no OID")
+		}
+		return r.tuple.Oid
+	}
+	return r.uint32("oid")
+}
+
+// uint32 returns the value of an oid, xid or similar column.
+func (r *row) uint32(name string) uint32 {
+	if data := r.value(name, 4); data != nil {
+		return r.order.Uint32(data)
+	}
+	return 0
+}
+
+// int32 returns the value of an int4 column.
+func (r *row) int32(name string) int32 {
+	return int32(r.uint32(name))
+}
+
+// int16 returns the value of an int2 column.
+func (r *row) int16(name string) int16 {
+	if data := r.value(name, 2); data != nil {
+		return int16(r.order.Uint16(data))
+	}
+	return 0
+}
+
+// char returns the value of a "char" column.
+func (r *row) char(name string) byte {
+	if data := r.value(name, 1); data != nil {
+		return data[0]
+	}
+	return 0
+}
+
+// bool returns the value of a bool column.
+func (r *row) bool(name string) bool {
+	return r.char(name) != 0
+}
+
+// name returns the value of a name column, which is NUL-padded to NAMEDATALEN.
+func (r *row) name(name string) string {
+	data := r.value(name, 1)
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	return string(data)
+}
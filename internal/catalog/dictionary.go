@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+)
+
+// DictionaryVersion is the format version of the metadata dictionary written
+// by this version of PDU. It is increased whenever the format changes in a
+// way older versions cannot read.
+const DictionaryVersion = 1
+
+// Save writes the catalog to path as the metadata dictionary, an indented
+// JSON document that can be edited by hand. The file is replaced atomically;
+// if that fails, the file is left as it was.
+func (c *Catalog) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, append(data, '\n'), 0644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Load reads a metadata dictionary written by Save and possibly edited
+// since. Duplicate or missing OIDs and names are errors; column descriptors
+// the decoder cannot use are reported by Problems.
+func Load(path string) (*Catalog, error) {
+	data, err := fileio.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if c.FormatVersion < 1 || c.FormatVersion > DictionaryVersion {
+		return nil, fmt.Errorf("%s: unsupported format version %d (this version of PDU reads up to %d)",
+			path, c.FormatVersion, DictionaryVersion)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, db := range c.Databases {
+		db.index()
+	}
+	return c, nil
+}
+
+// LoadDictionary loads the dictionary at path for cluster cl. It returns nil
+// and no error if there is no dictionary, and as warnings its Problems and
+// the ways in which it does not seem to describe the cluster.
+func LoadDictionary(path string, cl *cluster.Cluster) (*Catalog, []string, error) {
+	c, err := Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warnings := c.Problems()
+	if cl.Control != nil && c.SystemIdentifier != 0 && c.SystemIdentifier != cl.Control.SystemIdentifier {
+		warnings = append(warnings, fmt.Sprintf("dictionary %s was written for system identifier %d, not %d",
+			path, c.SystemIdentifier, cl.Control.SystemIdentifier))
+	}
+	if c.PGVersion != cl.Params.Profile.Name {
+		warnings = append(warnings, fmt.Sprintf("dictionary %s was written for PostgreSQL %s, not %s",
+			path, c.PGVersion, cl.Params.Profile.Name))
+	}
+	return c, warnings, nil
+}
+
+// OpenDictionary loads the dictionary configured with DICTIONARY for cluster
+// cl and writes its warnings to w. It returns nil and no error, after a
+// warning, if bootstrap has not written the dictionary yet.
+func OpenDictionary(cl *cluster.Cluster, w io.Writer) (*Catalog, error) {
+	path := viper.GetString("DICTIONARY")
+	c, warnings, err := LoadDictionary(path, cl)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	if c == nil {
+		fmt.Fprintf(w, "Warning: no metadata dictionary at %s; run pdu bootstrap to create it\n", path)
+	}
+	return c, nil
+}
+
+// String returns a one-line summary of the catalog.
+func (c *Catalog) String() string {
+	relations := 0
+	for _, db := range c.Databases {
+		relations += len(db.Relations)
+	}
+	return fmt.Sprintf("%d databases, %d relations, read from %s on %s",
+		len(c.Databases), relations, c.PGData, c.Created.Format("2006-01-02 15:04:05"))
+}
+
+// RelationForPath returns the database and relation a relation file of
+// cluster cl belongs to, or nils if the catalog does not know the file.
+func (c *Catalog) RelationForPath(cl *cluster.Cluster, path string) (*Database, *Relation) {
+	file, ok := fileio.ParseRelationFileName(filepath.Base(path))
+	if !ok {
+		return nil, nil
+	}
+	dir := filepath.Dir(path)
+	tablespace, ok := cl.Tablespace(dir)
+	if !ok {
+		return nil, nil
+	}
+
+	// Shared relations are in every database's pg_class
+	if tablespace == cluster.GlobalTablespaceOid {
+		for _, db := range c.Databases {
+			if rel := db.RelationByFileNode(tablespace, file.RelFileNode); rel != nil && rel.IsShared {
+				return db, rel
+			}
+		}
+		return nil, nil
+	}
+
+	oid, err := strconv.ParseUint(filepath.Base(dir), 10, 32)
+	if err != nil {
+		return nil, nil
+	}
+	db := c.DatabaseByOid(uint32(oid))
+	if db == nil {
+		return nil, nil
+	}
+	if rel := db.RelationByFileNode(tablespace, file.RelFileNode); rel != nil && !rel.IsShared {
+		return db, rel
+	}
+	return nil, nil
+}
+
+// validate checks that every database and relation has a name and a unique OID.
+func (c *Catalog) validate() error {
+	databases := make(map[uint32]bool)
+	for i, db := range c.Databases {
+		if db == nil || db.Oid == 0 || db.Name == "" {
+			return fmt.Errorf("databases[%d]: missing oid or name", i)
+		}
+		if databases[db.Oid] {
+			return fmt.Errorf("database %s: duplicate oid %d", db.Name, db.Oid)
+		}
+		databases[db.Oid] = true
+
+		relations := make(map[uint32]bool)
+		for j, rel := range db.Relations {
+			if rel == nil || rel.Oid == 0 || rel.Name == "" {
+				return fmt.Errorf("database %s: relations[%d]: missing oid or name", db.Name, j)
+			}
+			if relations[rel.Oid] {
+				return fmt.Errorf("database %s: relation %s: duplicate oid %d", db.Name, rel.QualifiedName(), rel.Oid)
+			}
+			relations[rel.Oid] = true
+		}
+	}
+	return nil
+}
+
+// Problems returns the relations whose column descriptors the decoder cannot
+// use, which need fixing in the dictionary before they can be unloaded.
+func (c *Catalog) Problems() []string {
+	var problems []string
+	for _, db := range c.Databases {
+		for _, rel := range db.Relations {
+			if err := rel.validateColumns(); err != nil {
+				problems = append(problems, fmt.Sprintf("database %s: relation %s: %v", db.Name, rel.QualifiedName(), err))
+			}
+		}
+	}
+	return problems
+}
+
+// validateColumns checks that the columns are numbered from 1 without gaps
+// and that their length, alignment and storage are valid.
+func (r *Relation) validateColumns() error {
+	for i, col := range r.Columns {
+		if col.AttNum != int16(i+1) {
+			return fmt.Errorf("column %s: attnum %d, expected %d", col.Name, col.AttNum, i+1)
+		}
+		if col.Len == 0 || col.Len < -2 {
+			return fmt.Errorf("column %s: invalid len %d", col.Name, col.Len)
+		}
+		switch col.Align {
+		case 'c', 's', 'i', 'd':
+		default:
+			return fmt.Errorf("column %s: invalid align %q", col.Name, byte(col.Align))
+		}
+		switch col.Storage {
+		case 'p', 'e', 'm', 'x':
+		default:
+			return fmt.Errorf("column %s: invalid storage %q", col.Name, byte(col.Storage))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	c := &Catalog{FormatVersion: DictionaryVersion}
+
+	path := filepath.Join(dir, "meta", "dictionary.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	if loaded, err := Load(path); err != nil || loaded.FormatVersion != DictionaryVersion {
+		t.Errorf("loaded %+v (%v)", loaded, err)
+	}
+
+	// A failed replacement removes the temporary file and leaves path alone
+	path = filepath.Join(dir, "taken")
+	if err := os.MkdirAll(filepath.Join(path, "file"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(path); err == nil {
+		t.Error("saved over a directory")
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("directory replaced: %v", err)
+	}
+}
@@ -0,0 +1,534 @@
+package catalog
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// ReadTableDefinitions reads table definitions supplied by the user for
+// files the catalog cannot describe. The file holds either CREATE TABLE
+// statements or a column list: one "name type" pair per line, with blank
+// lines and lines starting with # or -- ignored, describing a table named
+// after the file.
+func ReadTableDefinitions(path string, profile *pgtypes.Profile) ([]*Relation, error) {
+	data, err := fileio.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(data)
+	var relations []*Relation
+	if containsCreateTable(text) {
+		relations, err = ParseCreateTable(text, profile)
+	} else {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		var rel *Relation
+		rel, err = ParseColumnList(name, text, profile)
+		relations = []*Relation{rel}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(relations) == 0 {
+		return nil, fmt.Errorf("%s: no table definitions", path)
+	}
+	return relations, nil
+}
+
+// containsCreateTable reports whether text holds a CREATE TABLE statement.
+func containsCreateTable(text string) bool {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return false
+	}
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].keyword("create") && (tokens[i+1].keyword("table") || tokens[i+1].keyword("unlogged") ||
+			tokens[i+1].keyword("temp") || tokens[i+1].keyword("temporary")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseColumnList parses a column list, one "name type" pair per line, into
+// the definition of a table.
+func ParseColumnList(name, text string, profile *pgtypes.Profile) (*Relation, error) {
+	rel := newUserRelation(name)
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "--") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a column name and a type", n+1)
+		}
+		col, err := newColumn(fields[0], strings.Join(fields[1:], " "), profile)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		rel.addColumn(col)
+	}
+	if len(rel.Columns) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+	return rel, nil
+}
+
+// ParseCreateTable parses the CREATE TABLE statements in text into table
+// definitions. Other statements, column constraints, defaults and table
+// constraints are ignored, as they do not change how rows are stored. Tables
+// that take columns from others, with LIKE, PARTITION OF or INHERITS, are
+// rejected, as those columns are unknown.
+func ParseCreateTable(text string, profile *pgtypes.Profile) ([]*Relation, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var relations []*Relation
+	p := &tableParser{tokens: tokens, profile: profile}
+	for !p.done() {
+		if !p.peek().keyword("create") {
+			p.skipStatement()
+			continue
+		}
+		p.next()
+		for p.peek().keyword("unlogged") || p.peek().keyword("temp") || p.peek().keyword("temporary") {
+			p.next()
+		}
+		if !p.peek().keyword("table") {
+			p.skipStatement()
+			continue
+		}
+		p.next()
+		rel, err := p.table()
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, rel)
+	}
+	return relations, nil
+}
+
+// newUserRelation creates the definition of a table from a possibly
+// schema-qualified name.
+func newUserRelation(name string) *Relation {
+	rel := &Relation{Name: name, Kind: RELKIND_RELATION, Persistence: 'p'}
+	if dot := strings.LastIndexByte(name, '.'); dot > 0 {
+		rel.Schema, rel.Name = name[:dot], name[dot+1:]
+	}
+	return rel
+}
+
+// addColumn appends a column, numbering it.
+func (r *Relation) addColumn(col Column) {
+	col.AttNum = int16(len(r.Columns) + 1)
+	r.Columns = append(r.Columns, col)
+	r.NAtts = int16(len(r.Columns))
+}
+
+// newColumn creates a column of a built-in type given as in SQL, such as
+// "integer", "varchar(20)" or "numeric(10,2)[]".
+func newColumn(name, typeName string, profile *pgtypes.Profile) (Column, error) {
+	base, mods, err := splitTypeModifiers(typeName)
+	if err == nil {
+		base, mods, err = sqlTypeName(base, mods)
+	}
+	if err != nil {
+		return Column{}, fmt.Errorf("column %s: %v", name, err)
+	}
+	t, ok := profile.LookupType(base)
+	if !ok {
+		return Column{}, fmt.Errorf("column %s: unknown type %q", name, typeName)
+	}
+	return Column{
+		Name:     name,
+		TypeOid:  t.Oid,
+		TypeName: t.Name,
+		Len:      t.Len,
+		Align:    Char(t.Align),
+		ByVal:    t.ByVal,
+		Storage:  Char(t.Storage),
+		TypMod:   typmod(t, mods),
+	}, nil
+}
+
+// splitTypeModifiers separates the modifiers in parentheses from a type
+// name, e.g. "timestamp(3) with time zone" into "timestamp with time zone"
+// and [3]. "x array" is turned into "x[]".
+func splitTypeModifiers(typeName string) (string, []int, error) {
+	var mods []int
+	open := strings.IndexByte(typeName, '(')
+	if open >= 0 {
+		close := strings.IndexByte(typeName[open:], ')')
+		if close < 0 {
+			return "", nil, fmt.Errorf("unbalanced parentheses in type %q", typeName)
+		}
+		for _, mod := range strings.Split(typeName[open+1:open+close], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(mod))
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid type modifier %q in type %q", mod, typeName)
+			}
+			mods = append(mods, n)
+		}
+		typeName = typeName[:open] + " " + typeName[open+close+1:]
+	}
+
+	fields := strings.Fields(strings.ToLower(typeName))
+	if n := len(fields); n > 1 && fields[n-1] == "array" {
+		fields = fields[:n-1]
+		fields[n-2] += "[]"
+	}
+	base := strings.Join(fields, " ")
+	base = strings.Replace(base, " []", "[]", -1)
+	return base, mods, nil
+}
+
+// sqlTypeName resolves the SQL spellings that differ from the pg_type name
+// they look like: char and character are char(n), bpchar, while the
+// single-byte internal type must be quoted as "char"; and float(p) is float4
+// up to 24 bits of precision and float8 up to 53.
+func sqlTypeName(base string, mods []int) (string, []int, error) {
+	elem := strings.TrimRight(base, "[]")
+	dims := base[len(elem):]
+	switch elem {
+	case "char", "character":
+		return "bpchar" + dims, mods, nil
+	case "char varying":
+		return "varchar" + dims, mods, nil
+	case "float":
+		if len(mods) == 0 {
+			return "float8" + dims, nil, nil
+		}
+		switch p := mods[0]; {
+		case len(mods) > 1 || p < 1 || p > 53:
+			return "", nil, fmt.Errorf("invalid precision for type float")
+		case p <= 24:
+			return "float4" + dims, nil, nil
+		default:
+			return "float8" + dims, nil, nil
+		}
+	}
+	return base, mods, nil
+}
+
+// typmod computes atttypmod from type modifiers as the type's typmodin would.
+func typmod(t pgtypes.TypeInfo, mods []int) int32 {
+	const VARHDRSZ = 4
+	switch t.Oid {
+	case pgtypes.BPCHAROID:
+		if len(mods) == 0 {
+			return 1 + VARHDRSZ // character means character(1)
+		}
+		return int32(mods[0] + VARHDRSZ)
+	case pgtypes.VARCHAROID:
+		if len(mods) > 0 {
+			return int32(mods[0] + VARHDRSZ)
+		}
+	case pgtypes.NUMERICOID:
+		switch len(mods) {
+		case 1:
+			return int32(mods[0]<<16 + VARHDRSZ)
+		case 2:
+			return int32((mods[0]<<16 | mods[1]&0x7FF) + VARHDRSZ)
+		}
+	case pgtypes.TIMEOID, pgtypes.TIMETZOID, pgtypes.TIMESTAMPOID, pgtypes.TIMESTAMPTZOID,
+		pgtypes.INTERVALOID, pgtypes.BITOID, pgtypes.VARBITOID:
+		if len(mods) > 0 {
+			return int32(mods[0])
+		}
+	}
+	return -1
+}
+
+// token is a lexical token of SQL text.
+type token struct {
+	text   string
+	quoted bool // a quoted identifier
+}
+
+// keyword reports whether the token is the given keyword.
+func (t token) keyword(word string) bool {
+	return !t.quoted && strings.EqualFold(t.text, word)
+}
+
+// punct reports whether the token is the given punctuation.
+func (t token) punct(p string) bool {
+	return !t.quoted && t.text == p
+}
+
+// tokenize splits SQL text into identifiers, keywords, numbers, string
+// literals and punctuation, dropping comments.
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(text[i:], "--"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			// Quoted identifier or string literal; a doubled quote is a quote
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(text) {
+					return nil, fmt.Errorf("unterminated quote")
+				}
+				if text[j] == c {
+					if j+1 < len(text) && text[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(text[j])
+				j++
+			}
+			if c == '"' {
+				tokens = append(tokens, token{text: b.String(), quoted: true})
+			} else {
+				tokens = append(tokens, token{text: "'" + b.String() + "'"})
+			}
+			i = j + 1
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(text) && (text[j] == '_' || text[j] == '$' ||
+				unicode.IsLetter(rune(text[j])) || unicode.IsDigit(rune(text[j])) || text[j] >= 0x80) {
+				j++
+			}
+			tokens = append(tokens, token{text: text[i:j]})
+			i = j
+		case strings.HasPrefix(text[i:], "::"):
+			tokens = append(tokens, token{text: "::"})
+			i += 2
+		default:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// tableParser parses CREATE TABLE statements from tokens.
+type tableParser struct {
+	tokens  []token
+	pos     int
+	profile *pgtypes.Profile
+}
+
+// done reports whether all tokens are consumed.
+func (p *tableParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// peek returns the next token without consuming it.
+func (p *tableParser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *tableParser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// keywords consumes the next tokens if they are the given keywords, and
+// reports whether they were.
+func (p *tableParser) keywords(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, word := range words {
+		if !p.tokens[p.pos+i].keyword(word) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+// skipStatement skips to the token after the next semicolon.
+func (p *tableParser) skipStatement() {
+	for !p.done() && !p.next().punct(";") {
+	}
+}
+
+// name parses a possibly schema-qualified name. Unquoted names are folded to
+// lower case, as PostgreSQL does.
+func (p *tableParser) name() (string, error) {
+	var parts []string
+	for {
+		t := p.next()
+		switch {
+		case t.quoted:
+			parts = append(parts, t.text)
+		case t.text != "" && (t.text[0] == '_' || unicode.IsLetter(rune(t.text[0])) || t.text[0] >= 0x80):
+			// Unquoted names may have been tokenized together with their schema
+			parts = append(parts, strings.ToLower(t.text))
+		default:
+			return "", fmt.Errorf("expected a name, found %q", t.text)
+		}
+		if !p.peek().punct(".") {
+			return strings.Join(parts, "."), nil
+		}
+		p.next()
+	}
+}
+
+// table parses the rest of a CREATE TABLE statement after TABLE.
+func (p *tableParser) table() (*Relation, error) {
+	// IF NOT EXISTS, unless IF is the table name
+	p.keywords("if", "not", "exists")
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	rel := newUserRelation(name)
+	if p.keywords("partition", "of") {
+		return nil, fmt.Errorf("table %s: PARTITION OF is not supported; list the columns explicitly", name)
+	}
+	if !p.next().punct("(") {
+		return nil, fmt.Errorf("table %s: expected a column list", name)
+	}
+
+	for {
+		if p.done() {
+			return nil, fmt.Errorf("table %s: unterminated column list", name)
+		}
+		elem := p.element()
+		if len(elem) == 0 {
+			return nil, fmt.Errorf("table %s: empty column definition", name)
+		}
+		if elem[0].keyword("like") {
+			return nil, fmt.Errorf("table %s: LIKE is not supported; list the columns explicitly", name)
+		}
+		if !isTableConstraint(elem[0]) {
+			col, err := p.column(elem)
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %v", name, err)
+			}
+			rel.addColumn(col)
+		}
+		if p.next().punct(")") {
+			break
+		}
+	}
+	if p.peek().keyword("inherits") {
+		return nil, fmt.Errorf("table %s: INHERITS is not supported; list the columns explicitly, inherited ones first", name)
+	}
+	p.skipStatement()
+
+	if len(rel.Columns) == 0 {
+		return nil, fmt.Errorf("table %s: no columns", name)
+	}
+	return rel, nil
+}
+
+// element returns the tokens of one column or table constraint definition,
+// up to the comma or closing parenthesis that ends it.
+func (p *tableParser) element() []token {
+	start, depth := p.pos, 0
+	for !p.done() {
+		t := p.peek()
+		switch {
+		case t.punct("("), t.punct("["):
+			depth++
+		case t.punct(")"), t.punct("]"):
+			if depth == 0 {
+				return p.tokens[start:p.pos]
+			}
+			depth--
+		case t.punct(",") && depth == 0:
+			return p.tokens[start:p.pos]
+		}
+		p.pos++
+	}
+	return p.tokens[start:p.pos]
+}
+
+// isTableConstraint reports whether a table element starting with t is a
+// table constraint rather than a column.
+func isTableConstraint(t token) bool {
+	for _, word := range []string{"constraint", "primary", "unique", "check", "foreign", "exclude"} {
+		if t.keyword(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// columnConstraintKeywords end the type of a column definition.
+var columnConstraintKeywords = []string{
+	"not", "null", "default", "constraint", "primary", "unique", "check", "references",
+	"collate", "generated", "compression", "storage",
+}
+
+// column parses a column definition: a name, a type and constraints.
+func (p *tableParser) column(elem []token) (Column, error) {
+	name := elem[0].text
+	if !elem[0].quoted {
+		name = strings.ToLower(name)
+	}
+
+	// Quoted names keep their quotes, so that "char" is told from char
+	var typeName strings.Builder
+	for _, t := range elem[1:] {
+		if isColumnConstraint(t) {
+			break
+		}
+		text := t.text
+		if t.quoted {
+			text = `"` + strings.Replace(text, `"`, `""`, -1) + `"`
+		}
+		if !t.punct("(") && !t.punct(")") && !t.punct(",") && !t.punct("[") && !t.punct("]") &&
+			typeName.Len() > 0 {
+			typeName.WriteByte(' ')
+		}
+		typeName.WriteString(text)
+	}
+	if typeName.Len() == 0 {
+		return Column{}, fmt.Errorf("column %s: missing type", name)
+	}
+	col, err := newColumn(name, typeName.String(), p.profile)
+	if err != nil {
+		return Column{}, err
+	}
+	for i := 1; i+1 < len(elem); i++ {
+		if elem[i].keyword("not") && elem[i+1].keyword("null") {
+			col.NotNull = true
+		}
+	}
+	return col, nil
+}
+
+// isColumnConstraint reports whether t starts a column constraint.
+func isColumnConstraint(t token) bool {
+	for _, word := range columnConstraintKeywords {
+		if t.keyword(word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+func testProfile(t *testing.T) *pgtypes.Profile {
+	t.Helper()
+	profile, err := pgtypes.ProfileFor(150000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+func TestParseCreateTableTypes(t *testing.T) {
+	tests := []struct {
+		typeName string
+		oid      uint32
+		len      int16
+		typmod   int32
+	}{
+		{"integer", pgtypes.INT4OID, 4, -1},
+		{"int", pgtypes.INT4OID, 4, -1},
+		{"bigint", pgtypes.INT8OID, 8, -1},
+		{"smallint", pgtypes.INT2OID, 2, -1},
+		{"boolean", pgtypes.BOOLOID, 1, -1},
+		{"char", pgtypes.BPCHAROID, -1, 5},
+		{"char(10)", pgtypes.BPCHAROID, -1, 14},
+		{"CHAR(3)", pgtypes.BPCHAROID, -1, 7},
+		{"character(10)", pgtypes.BPCHAROID, -1, 14},
+		{"character", pgtypes.BPCHAROID, -1, 5},
+		{"char varying(20)", pgtypes.VARCHAROID, -1, 24},
+		{"character varying(20)", pgtypes.VARCHAROID, -1, 24},
+		{"varchar", pgtypes.VARCHAROID, -1, -1},
+		{`"char"`, pgtypes.CHAROID, 1, -1},
+		{"bpchar", pgtypes.BPCHAROID, -1, 5},
+		{"float", pgtypes.FLOAT8OID, 8, -1},
+		{"float(1)", pgtypes.FLOAT4OID, 4, -1},
+		{"float(24)", pgtypes.FLOAT4OID, 4, -1},
+		{"float(25)", pgtypes.FLOAT8OID, 8, -1},
+		{"float(53)", pgtypes.FLOAT8OID, 8, -1},
+		{"real", pgtypes.FLOAT4OID, 4, -1},
+		{"double precision", pgtypes.FLOAT8OID, 8, -1},
+		{"numeric(10,2)", pgtypes.NUMERICOID, -1, 10<<16 | 2 + 4},
+		{"numeric", pgtypes.NUMERICOID, -1, -1},
+		{"decimal(5)", pgtypes.NUMERICOID, -1, 5<<16 + 4},
+		{"timestamp(3) with time zone", pgtypes.TIMESTAMPTZOID, 8, 3},
+		{"timestamp without time zone", pgtypes.TIMESTAMPOID, 8, -1},
+		{"text", pgtypes.TEXTOID, -1, -1},
+		{"text[]", pgtypes.TEXTARRAYOID, -1, -1},
+		{"integer array", pgtypes.INT4ARRAYOID, -1, -1},
+		{"uuid", pgtypes.UUIDOID, 16, -1},
+	}
+
+	profile := testProfile(t)
+	for _, test := range tests {
+		rels, err := ParseCreateTable("CREATE TABLE t (c "+test.typeName+", d int);", profile)
+		if err != nil {
+			t.Errorf("%s: %v", test.typeName, err)
+			continue
+		}
+		col := rels[0].Columns[0]
+		if col.TypeOid != test.oid || col.Len != test.len || col.TypMod != test.typmod {
+			t.Errorf("%s: oid %d len %d typmod %d, want oid %d len %d typmod %d",
+				test.typeName, col.TypeOid, col.Len, col.TypMod, test.oid, test.len, test.typmod)
+		}
+		if next := rels[0].Columns[1]; next.Name != "d" || next.TypeOid != pgtypes.INT4OID {
+			t.Errorf("%s: next column %s of type %d", test.typeName, next.Name, next.TypeOid)
+		}
+	}
+}
+
+func TestParseCreateTableInvalidTypes(t *testing.T) {
+	profile := testProfile(t)
+	for _, typeName := range []string{"float(0)", "float(54)", "nosuchtype", "varchar(x)", "numeric(10"} {
+		if _, err := ParseCreateTable("CREATE TABLE t (c "+typeName+");", profile); err == nil {
+			t.Errorf("%s: no error", typeName)
+		}
+	}
+}
+
+func TestParseCreateTableBorrowedColumns(t *testing.T) {
+	profile := testProfile(t)
+	for _, sql := range []string{
+		"CREATE TABLE t (LIKE s INCLUDING ALL);",
+		"CREATE TABLE t (a int, LIKE s);",
+		"CREATE TABLE t PARTITION OF s FOR VALUES IN (1);",
+		"CREATE TABLE t (a int) INHERITS (s);",
+	} {
+		if _, err := ParseCreateTable(sql, profile); err == nil || !strings.Contains(err.Error(), "list the columns explicitly") {
+			t.Errorf("%s: %v", sql, err)
+		}
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	tests := []struct {
+		sql     string
+		name    string
+		columns []string
+		notNull []bool
+	}{
+		{
+			sql:     `CREATE TABLE public.orders (id bigint PRIMARY KEY, "Name" text NOT NULL, d date DEFAULT now());`,
+			name:    "public.orders",
+			columns: []string{"id", "Name", "d"},
+			notNull: []bool{false, true, false},
+		},
+		{
+			sql:     `CREATE UNLOGGED TABLE "My Table" (a int, CONSTRAINT pk PRIMARY KEY (a), b numeric(5, 2) CHECK (b > 0))`,
+			name:    "My Table",
+			columns: []string{"a", "b"},
+			notNull: []bool{false, false},
+		},
+		{
+			sql:     `CREATE TABLE IF NOT EXISTS s.t (a int)`,
+			name:    "s.t",
+			columns: []string{"a"},
+			notNull: []bool{false},
+		},
+		{
+			sql:     `CREATE TABLE IF (a int)`,
+			name:    "if",
+			columns: []string{"a"},
+			notNull: []bool{false},
+		},
+		{
+			sql:     "-- comment\nCREATE INDEX i ON t (a);\n/* CREATE TABLE x (y int); */ CREATE TEMP TABLE t2 (x int);",
+			name:    "t2",
+			columns: []string{"x"},
+			notNull: []bool{false},
+		},
+	}
+
+	profile := testProfile(t)
+	for _, test := range tests {
+		rels, err := ParseCreateTable(test.sql, profile)
+		if err != nil {
+			t.Errorf("%s: %v", test.sql, err)
+			continue
+		}
+		if len(rels) != 1 {
+			t.Errorf("%s: %d tables", test.sql, len(rels))
+			continue
+		}
+		rel := rels[0]
+		if rel.QualifiedName() != test.name || len(rel.Columns) != len(test.columns) {
+			t.Errorf("%s: table %s with %d columns", test.sql, rel.QualifiedName(), len(rel.Columns))
+			continue
+		}
+		for i, col := range rel.Columns {
+			if col.Name != test.columns[i] || col.NotNull != test.notNull[i] || col.AttNum != int16(i+1) {
+				t.Errorf("%s: column %d is %s (attnum %d, not null %v)", test.sql, i+1, col.Name, col.AttNum, col.NotNull)
+			}
+		}
+	}
+}
+
+func TestParseColumnList(t *testing.T) {
+	rel, err := ParseColumnList("sales.orders", "# comment\nid int4\n\nflag \"char\"\ncode char(2)\n", testProfile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{pgtypes.INT4OID, pgtypes.CHAROID, pgtypes.BPCHAROID}
+	if rel.Schema != "sales" || rel.Name != "orders" || len(rel.Columns) != len(want) {
+		t.Fatalf("table %s with %d columns", rel.QualifiedName(), len(rel.Columns))
+	}
+	for i, col := range rel.Columns {
+		if col.TypeOid != want[i] {
+			t.Errorf("column %s: type %d, want %d", col.Name, col.TypeOid, want[i])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package catalog
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Transaction status recorded in the commit log
+const (
+	TRANSACTION_STATUS_IN_PROGRESS   = 0x00
+	TRANSACTION_STATUS_COMMITTED     = 0x01
+	TRANSACTION_STATUS_ABORTED       = 0x02
+	TRANSACTION_STATUS_SUB_COMMITTED = 0x03
+)
+
+// Special transaction IDs
+const (
+	BootstrapTransactionId   uint32 = 1 // xmin of tuples created by initdb
+	FrozenTransactionId      uint32 = 2 // xmin of tuples frozen before 9.4
+	FirstNormalTransactionId uint32 = 3
+)
+
+// Commit log layout: two status bits per transaction in SLRU pages of
+// BLCKSZ bytes, 32 pages per segment file
+const (
+	clogXactsPerByte    = 4
+	slruPagesPerSegment = 32
+)
+
+// CommitLog reads transaction status from pg_xact (pg_clog before 10).
+// Segments are read once and kept; a CommitLog is not safe for concurrent use.
+type CommitLog struct {
+	dir       string
+	blockSize int
+	segments  map[uint32][]byte // nil for segments that cannot be read
+}
+
+// NewCommitLog creates a CommitLog for the cluster.
+func NewCommitLog(c *cluster.Cluster) *CommitLog {
+	dir := "pg_xact"
+	if c.Params.Profile.VersionNum < 100000 {
+		dir = "pg_clog"
+	}
+	return &CommitLog{
+		dir:       filepath.Join(c.PGData, dir),
+		blockSize: c.Params.BlockSize,
+		segments:  make(map[uint32][]byte),
+	}
+}
+
+// status returns the status of a transaction, and false if it is not in the
+// commit log, for example because the log was truncated or lost.
+func (l *CommitLog) status(xid uint32) (int, bool) {
+	xactsPerPage := uint32(l.blockSize * clogXactsPerByte)
+	page := xid / xactsPerPage
+	segno := page / slruPagesPerSegment
+
+	data, ok := l.segments[segno]
+	if !ok {
+		data = l.readSegment(segno)
+		l.segments[segno] = data
+	}
+
+	offset := int(page%slruPagesPerSegment)*l.blockSize + int(xid%xactsPerPage/clogXactsPerByte)
+	if offset >= len(data) {
+		return 0, false
+	}
+	return int(data[offset]>>(xid%clogXactsPerByte*2)) & 0x03, true
+}
+
+// readSegment reads a commit log segment, named with four hex digits or,
+// since 17, possibly with fifteen.
+func (l *CommitLog) readSegment(segno uint32) []byte {
+	for _, name := range []string{fmt.Sprintf("%04X", segno), fmt.Sprintf("%015X", segno)} {
+		if data, err := fileio.ReadFile(filepath.Join(l.dir, name)); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// aborted reports whether a transaction is known to have aborted, or did not
+// finish before the cluster stopped.
+func (l *CommitLog) aborted(xid uint32) bool {
+	if xid < FirstNormalTransactionId {
+		return false
+	}
+	status, ok := l.status(xid)
+	return ok && (status == TRANSACTION_STATUS_ABORTED || status == TRANSACTION_STATUS_IN_PROGRESS)
+}
+
+// Visible reports whether a tuple is the current version of its row:
+// its inserting transaction committed and no committed transaction deleted or
+// updated it. Hint bits are used where set, the commit log otherwise. Rows
+// updated or deleted by a multixact are taken as deleted, as pg_multixact is
+// not read to find whether its updating member committed.
+func (l *CommitLog) Visible(header pgtypes.HeapTupleHeaderData) bool {
+	infomask := header.TInfomask
+
+	// Inserted by a transaction that committed
+	switch {
+	case infomask&pgtypes.HEAP_XMIN_FROZEN == pgtypes.HEAP_XMIN_FROZEN:
+	case infomask&pgtypes.HEAP_XMIN_INVALID != 0:
+		return false
+	case infomask&pgtypes.HEAP_XMIN_COMMITTED != 0:
+	case l.aborted(header.THeap.TXmin):
+		return false
+	}
+
+	// Not deleted or updated by a transaction that committed
+	xmax := header.THeap.TXmax
+	switch {
+	case xmax == 0, infomask&pgtypes.HEAP_XMAX_INVALID != 0:
+		return true
+	case pgtypes.HeapXmaxIsLockedOnly(infomask), pgtypes.HeapLockedUpgraded(infomask):
+		// Row locks, such as the key-share locks taken by foreign keys
+		return true
+	case infomask&pgtypes.HEAP_XMAX_IS_MULTI != 0:
+		// A multixact that is not only locking has an updating member
+		return false
+	case infomask&pgtypes.HEAP_XMAX_COMMITTED != 0:
+		return false
+	}
+	// A deleter the commit log does not know is assumed to have committed
+	status, ok := l.status(xmax)
+	return ok && status != TRANSACTION_STATUS_COMMITTED
+}
+
+// newer reports whether a tuple inserted by xid a is newer than one inserted
+// by xid b, comparing modulo 2^32 as PostgreSQL does.
+func newer(a, b uint32) bool {
+	return int32(a-b) > 0
+}
@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// testCommitLog returns a CommitLog whose first pg_xact page records the
+// status of transactions 1000 to 1003: committed, aborted, committed and in
+// progress.
+func testCommitLog(t *testing.T) *CommitLog {
+	t.Helper()
+	c := &cluster.Cluster{PGData: t.TempDir(), Params: pgtypes.DefaultStorageParams()}
+	dir := filepath.Join(c.PGData, "pg_xact")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := make([]byte, c.Params.BlockSize)
+	page[1000/clogXactsPerByte] = TRANSACTION_STATUS_COMMITTED | TRANSACTION_STATUS_ABORTED<<2 |
+		TRANSACTION_STATUS_COMMITTED<<4 | TRANSACTION_STATUS_IN_PROGRESS<<6
+	if err := os.WriteFile(filepath.Join(dir, "0000"), page, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewCommitLog(c)
+}
+
+func TestVisible(t *testing.T) {
+	const (
+		committed = pgtypes.HEAP_XMIN_COMMITTED
+		unhinted  = 0
+	)
+	tests := []struct {
+		name       string
+		xmin, xmax uint32
+		infomask   uint16
+		want       bool
+	}{
+		{"live", 1000, 0, committed | pgtypes.HEAP_XMAX_INVALID, true},
+		{"frozen", 1001, 0, pgtypes.HEAP_XMIN_FROZEN, true},
+		{"inserter aborted", 1000, 0, pgtypes.HEAP_XMIN_INVALID, false},
+		{"inserter committed in commit log", 1000, 0, unhinted, true},
+		{"inserter aborted in commit log", 1001, 0, unhinted, false},
+		{"inserter in progress", 1003, 0, unhinted, false},
+		{"inserted by initdb", BootstrapTransactionId, 0, unhinted, true},
+
+		{"deleter committed", 1000, 1002, committed | pgtypes.HEAP_XMAX_COMMITTED, false},
+		{"deleter committed in commit log", 1000, 1002, committed, false},
+		{"deleter aborted in commit log", 1000, 1001, committed, true},
+		{"deleter in progress", 1000, 1003, committed, true},
+		{"deleter not in commit log", 1000, 5000000, committed, false},
+
+		{"locked", 1000, 1002, committed | pgtypes.HEAP_XMAX_LOCK_ONLY | pgtypes.HEAP_XMAX_EXCL_LOCK, true},
+		{"locked before 9.3", 1000, 1002, committed | pgtypes.HEAP_XMAX_EXCL_LOCK, true},
+		{"key-share locked by a multixact", 1000, 7, committed | pgtypes.HEAP_XMAX_IS_MULTI |
+			pgtypes.HEAP_XMAX_LOCK_ONLY | pgtypes.HEAP_XMAX_KEYSHR_LOCK, true},
+		{"share locked by a multixact before 9.3", 1000, 7, committed | pgtypes.HEAP_XMAX_IS_MULTI | pgtypes.HEAP_XMAX_LOCK_ONLY, true},
+		{"updated by a multixact", 1000, 7, committed | pgtypes.HEAP_XMAX_IS_MULTI | pgtypes.HEAP_XMAX_KEYSHR_LOCK, false},
+		{"deleted by a multixact", 1000, 7, committed | pgtypes.HEAP_XMAX_IS_MULTI, false},
+	}
+
+	clog := testCommitLog(t)
+	for _, test := range tests {
+		var header pgtypes.HeapTupleHeaderData
+		header.THeap.TXmin = test.xmin
+		header.THeap.TXmax = test.xmax
+		header.TInfomask = test.infomask
+		if got := clog.Visible(header); got != test.want {
+			t.Errorf("%s: visible %v, want %v", test.name, got, test.want)
+		}
+	}
+}
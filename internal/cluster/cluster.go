@@ -0,0 +1,398 @@
+// Package cluster collects what PDU knows about the PostgreSQL cluster a data
+// directory belongs to, so that every command reads files the same way.
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/controlfile"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Options holds user overrides for values normally read from pg_control.
+// Zero values mean "detect".
+type Options struct {
+	Version       string // major version such as "9.6" or "15", or "auto"
+	ByteOrder     string // "auto", "little" or "big"
+	BlockSize     int    // BLCKSZ in bytes
+	RelSegSize    int    // blocks per segment file
+	XLogBlockSize int    // WAL page size in bytes
+	WalSegSize    int    // WAL segment size in bytes
+	IOMode        string // "pread" or "mmap"
+	ReadAhead     int    // blocks read per system call with pread
+	MaxReadRate   int64  // bytes read per second, 0 for no limit
+	MaxIOPS       int64  // read operations per second, 0 for no limit
+}
+
+// OptionsFromConfig reads the overrides from the PDU configuration.
+func OptionsFromConfig() Options {
+	return Options{
+		Version:       viper.GetString("PG_VERSION"),
+		ByteOrder:     viper.GetString("BYTE_ORDER"),
+		BlockSize:     viper.GetInt("BLOCK_SIZE"),
+		RelSegSize:    viper.GetInt("RELSEG_SIZE"),
+		XLogBlockSize: viper.GetInt("XLOG_BLOCK_SIZE"),
+		WalSegSize:    viper.GetInt("WAL_SEGMENT_SIZE"),
+		IOMode:        viper.GetString("IO_MODE"),
+		ReadAhead:     viper.GetInt("READ_AHEAD"),
+		MaxReadRate:   int64(viper.GetSizeInBytes("MAX_READ_RATE")),
+		MaxIOPS:       viper.GetInt64("MAX_IOPS"),
+	}
+}
+
+// Cluster describes a PostgreSQL data directory.
+type Cluster struct {
+	// Path to the data directory
+	PGData string
+
+	// Decoded pg_control, or nil if it could not be read
+	Control *controlfile.ControlFileData
+
+	// Storage parameters used to read the data files
+	Params pgtypes.StorageParams
+
+	// How data files are read, and the counters of what was read
+	IO fileio.IOOptions
+
+	// Contents of postmaster.pid, or nil if there is none
+	Postmaster *Postmaster
+
+	// Problems that did not prevent opening the cluster
+	Warnings []string
+}
+
+// Open inspects a data directory. The storage parameters come from the
+// options where given, then from pg_control, then from PostgreSQL's defaults,
+// so that a cluster with a lost or damaged pg_control can still be read.
+func Open(pgData string, opts Options) (*Cluster, error) {
+	c := &Cluster{
+		PGData: pgData,
+		Params: pgtypes.DefaultStorageParams(),
+	}
+
+	// Read pg_control
+	control, err := controlfile.ReadControlFile(pgData)
+	if err != nil {
+		c.warnf("cannot read %s: %v; using configured or default storage parameters", controlfile.Path(pgData), err)
+	} else {
+		c.Control = control
+		c.Params = control.StorageParams()
+		if !control.CRCValid {
+			c.warnf("%s: CRC mismatch, contents may be unreliable", controlfile.Path(pgData))
+		}
+	}
+
+	// Look for a running server
+	c.detectLive()
+
+	// Detect the PostgreSQL version
+	if err := c.detectVersion(opts.Version); err != nil {
+		return nil, err
+	}
+
+	// Apply overrides
+	if err := c.applyOptions(opts); err != nil {
+		return nil, err
+	}
+
+	// Make sure the result is usable
+	if err := c.Params.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Set up I/O
+	ioMode, err := fileio.ParseIOMode(opts.IOMode)
+	if err != nil {
+		return nil, err
+	}
+	if ioMode == fileio.IOModeMmap && c.Live() {
+		// Files truncated under a mapping kill the process with SIGBUS
+		c.warnf("cluster is running; reading files with pread instead of mmap")
+		ioMode = fileio.IOModePread
+	}
+	if opts.ReadAhead < 0 {
+		return nil, fmt.Errorf("invalid read-ahead %d: must not be negative", opts.ReadAhead)
+	}
+	if opts.MaxReadRate < 0 || opts.MaxIOPS < 0 {
+		return nil, fmt.Errorf("invalid I/O limits %d bytes/s, %d IOPS: must not be negative", opts.MaxReadRate, opts.MaxIOPS)
+	}
+	stats := &fileio.IOStats{}
+	c.IO = fileio.IOOptions{
+		Mode:      ioMode,
+		ReadAhead: opts.ReadAhead,
+		Pool:      fileio.NewPagePool(c.Params.BlockSize, stats),
+		Stats:     stats,
+		Throttle:  fileio.NewThrottle(opts.MaxReadRate, opts.MaxIOPS),
+	}
+
+	return c, nil
+}
+
+// detectVersion selects the version profile. A configured version wins;
+// otherwise PG_VERSION is used, cross-checked against the catalog version in
+// pg_control, which is also the fallback when PG_VERSION is missing.
+func (c *Cluster) detectVersion(configured string) error {
+	// Version recorded in pg_control
+	var fromControl *pgtypes.Profile
+	if c.Control != nil {
+		profile, err := pgtypes.ProfileForCatalogVersion(c.Control.CatalogVersionNo)
+		if err != nil {
+			c.warnf("pg_control: %v", err)
+		} else {
+			fromControl = profile
+		}
+	}
+
+	// Version recorded in PG_VERSION
+	var fromFile *pgtypes.Profile
+	versionFile := filepath.Join(c.PGData, "PG_VERSION")
+	if data, err := fileio.ReadFile(versionFile); err != nil {
+		c.warnf("cannot read %s: %v", versionFile, err)
+	} else if profile, err := profileForName(string(data)); err != nil {
+		c.warnf("%s: %v", versionFile, err)
+	} else {
+		fromFile = profile
+	}
+
+	switch name := strings.TrimSpace(configured); {
+	case name != "" && name != "auto":
+		profile, err := profileForName(name)
+		if err != nil {
+			return err
+		}
+		if fromFile != nil && fromFile != profile {
+			c.warnf("configured version %s differs from PG_VERSION (%s)", profile.Name, fromFile.Name)
+		}
+		c.Params.Profile = profile
+	case fromFile != nil:
+		if fromControl != nil && fromControl != fromFile {
+			c.warnf("PG_VERSION (%s) does not match the catalog version in pg_control (%s); using %s",
+				fromFile.Name, fromControl.Name, fromFile.Name)
+		}
+		c.Params.Profile = fromFile
+	case fromControl != nil:
+		c.Params.Profile = fromControl
+	default:
+		c.warnf("cannot detect the PostgreSQL version; assuming %s", pgtypes.DefaultProfile())
+		c.Params.Profile = pgtypes.DefaultProfile()
+	}
+
+	return nil
+}
+
+// profileForName returns the profile of a major version written as in PG_VERSION.
+func profileForName(name string) (*pgtypes.Profile, error) {
+	versionNum, err := pgtypes.ParseVersionName(name)
+	if err != nil {
+		return nil, err
+	}
+	return pgtypes.ProfileFor(versionNum)
+}
+
+// applyOptions overrides detected storage parameters with user-supplied ones.
+func (c *Cluster) applyOptions(opts Options) error {
+	// Byte order
+	if name := strings.ToLower(strings.TrimSpace(opts.ByteOrder)); name != "" && name != "auto" {
+		order, err := pgtypes.ParseByteOrder(name)
+		if err != nil {
+			return err
+		}
+		if c.Control != nil && c.Control.ByteOrder != order {
+			c.warnf("configured byte order %s differs from pg_control (%s)", order, c.Control.ByteOrder)
+		}
+		c.Params.ByteOrder = order
+	}
+
+	// Sizes
+	c.override("block size", opts.BlockSize, &c.Params.BlockSize)
+	c.override("segment size", opts.RelSegSize, &c.Params.RelSegSize)
+	c.override("WAL block size", opts.XLogBlockSize, &c.Params.XLogBlockSize)
+	c.override("WAL segment size", opts.WalSegSize, &c.Params.WalSegSize)
+
+	return nil
+}
+
+// override replaces a detected value with a configured one, warning when they disagree.
+func (c *Cluster) override(name string, configured int, value *int) {
+	if configured == 0 {
+		return
+	}
+	if c.Control != nil && configured != *value {
+		c.warnf("configured %s %d differs from pg_control (%d)", name, configured, *value)
+	}
+	*value = configured
+}
+
+// DataDirectories returns the directories that hold relation files: global,
+// base and the version directory of every tablespace in pg_tblspc, with
+// tablespace symlinks resolved.
+func (c *Cluster) DataDirectories() []string {
+	dirs := []string{
+		filepath.Join(c.PGData, "global"),
+		filepath.Join(c.PGData, "base"),
+	}
+
+	// Tablespaces are symlinks to directories holding PG_<version>_<catversion>
+	links, _ := filepath.Glob(filepath.Join(c.PGData, "pg_tblspc", "*"))
+	for _, link := range links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			c.warnf("tablespace %s: %v", link, err)
+			continue
+		}
+		versionDirs, _ := filepath.Glob(filepath.Join(target, "PG_*"))
+		dirs = append(dirs, versionDirs...)
+	}
+
+	return dirs
+}
+
+// OIDs of the tablespaces every cluster has
+const (
+	DefaultTablespaceOid uint32 = 1663 // pg_default, in base
+	GlobalTablespaceOid  uint32 = 1664 // pg_global, in global
+)
+
+// RelationPath returns the relfilenode path of a relation in a tablespace
+// and database. Relations in pg_global ignore the database.
+func (c *Cluster) RelationPath(tablespace, database, fileNode uint32) string {
+	return filepath.Join(c.DatabasePath(tablespace, database), strconv.FormatUint(uint64(fileNode), 10))
+}
+
+// DatabasePath returns the directory holding the files of a database in a
+// tablespace: base/<database>, the database directory within the tablespace,
+// or global for pg_global.
+func (c *Cluster) DatabasePath(tablespace, database uint32) string {
+	switch tablespace {
+	case GlobalTablespaceOid:
+		return filepath.Join(c.PGData, "global")
+	case DefaultTablespaceOid, 0:
+		return filepath.Join(c.PGData, "base", strconv.FormatUint(uint64(database), 10))
+	}
+	return filepath.Join(c.tablespaceDirectory(tablespace), strconv.FormatUint(uint64(database), 10))
+}
+
+// Tablespace returns the tablespace of a directory holding relation files:
+// global, base/<database>, or a database directory within a tablespace,
+// whether reached through pg_tblspc or at its resolved location. It returns
+// false for a directory in none of the cluster's tablespaces.
+func (c *Cluster) Tablespace(dir string) (uint32, bool) {
+	dir = filepath.Clean(dir)
+	parent := filepath.Dir(dir)
+	switch {
+	case filepath.Base(dir) == "global":
+		return GlobalTablespaceOid, true
+	case filepath.Base(parent) == "base":
+		return DefaultTablespaceOid, true
+	case filepath.Base(filepath.Dir(filepath.Dir(parent))) == "pg_tblspc":
+		// pg_tblspc/<tablespace>/PG_<version>_<catversion>/<database>
+		if oid, err := strconv.ParseUint(filepath.Base(filepath.Dir(parent)), 10, 32); err == nil {
+			return uint32(oid), true
+		}
+	}
+
+	// Resolved tablespace locations
+	resolved, err := filepath.EvalSymlinks(filepath.Dir(parent))
+	if err != nil {
+		return 0, false
+	}
+	links, _ := filepath.Glob(filepath.Join(c.PGData, "pg_tblspc", "*"))
+	for _, link := range links {
+		oid, err := strconv.ParseUint(filepath.Base(link), 10, 32)
+		if err != nil {
+			continue
+		}
+		if target, err := filepath.EvalSymlinks(link); err == nil && target == resolved {
+			return uint32(oid), true
+		}
+	}
+	return 0, false
+}
+
+// tablespaceDirectory returns the version directory of a tablespace,
+// pg_tblspc/<oid>/PG_<version>_<catversion>.
+func (c *Cluster) tablespaceDirectory(tablespace uint32) string {
+	link := filepath.Join(c.PGData, "pg_tblspc", strconv.FormatUint(uint64(tablespace), 10))
+	if dirs, _ := filepath.Glob(filepath.Join(link, "PG_"+c.Params.Profile.Name+"_*")); len(dirs) > 0 {
+		return dirs[0]
+	}
+	catalogVersion := c.Params.Profile.CatalogVersion
+	if c.Control != nil {
+		catalogVersion = c.Control.CatalogVersionNo
+	}
+	return filepath.Join(link, fmt.Sprintf("PG_%s_%d", c.Params.Profile.Name, catalogVersion))
+}
+
+// FindRelations returns the relfilenode paths of all relations in the cluster.
+func (c *Cluster) FindRelations() ([]string, error) {
+	var relations []string
+	for _, dir := range c.DataDirectories() {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		found, err := fileio.FindRelations(dir)
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, found...)
+	}
+	return relations, nil
+}
+
+// EnableChecksumVerification makes page parsers verify checksums even though
+// pg_control is unavailable and cannot say whether checksums are enabled.
+func (c *Cluster) EnableChecksumVerification() {
+	if c.Control == nil && !c.Params.DataChecksums {
+		c.warnf("pg_control unavailable; verifying checksums assuming they are enabled")
+		c.Params.DataChecksums = true
+	}
+}
+
+// NewRelationReader creates a RelationReader for the relations of the cluster.
+func (c *Cluster) NewRelationReader() *fileio.RelationReader {
+	relation := fileio.NewRelationReader(c.Params)
+	relation.SetIOOptions(c.IO)
+	return relation
+}
+
+// VerifyRelationChecksums verifies the data checksums of every fork of a
+// relation and returns one report per fork together with any segment problems.
+func (c *Cluster) VerifyRelationChecksums(path string) ([]*pager.ChecksumReport, []fileio.SegmentProblem, error) {
+	relation := c.NewRelationReader()
+	if err := relation.Open(path); err != nil {
+		return nil, nil, err
+	}
+	defer relation.Close()
+
+	parser := pager.NewPageParser(c.Params)
+	var reports []*pager.ChecksumReport
+	for _, fork := range fileio.AllForks {
+		reader := relation.Fork(fork)
+		if reader == nil {
+			continue
+		}
+		reports = append(reports, pager.VerifyChecksums(fileio.ForkPath(path, fork), reader, parser))
+	}
+
+	return reports, relation.Problems(), nil
+}
+
+// PrintWarnings writes the recorded warnings to w.
+func (c *Cluster) PrintWarnings(w io.Writer) {
+	for _, warning := range c.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+}
+
+// warnf records a warning.
+func (c *Cluster) warnf(format string, args ...interface{}) {
+	c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
+}
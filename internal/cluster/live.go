@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wublabdubdub/pdu/internal/controlfile"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+)
+
+// Errors returned by CheckLive
+var (
+	ErrClusterLive      = errors.New("cluster is running")     // its postmaster is running
+	ErrClusterMayBeLive = errors.New("cluster may be running") // its postmaster.pid cannot be read
+)
+
+// Postmaster describes the postmaster.pid file of a data directory.
+type Postmaster struct {
+	PID       int       // process ID of the postmaster
+	DataDir   string    // data directory as seen by the postmaster
+	StartTime time.Time // when the postmaster started
+	Port      int       // port the postmaster listens on
+	Alive     bool      // a process with the PID is running on this host, or it is unknown
+	ReadErr   error     // postmaster.pid exists but is unreadable; nothing else is known
+}
+
+// readPostmaster reads postmaster.pid from a data directory. It returns nil
+// if there is none, which is the case when the cluster was shut down cleanly.
+func readPostmaster(pgData string) (*Postmaster, error) {
+	data, err := fileio.ReadFile(filepath.Join(pgData, "postmaster.pid"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines are PID, data directory, start time, port, socket directory,
+	// listen address, shared memory key and, since PostgreSQL 10, status
+	lines := strings.Split(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PID %q", lines[0])
+	}
+
+	// A negative PID marks a standalone backend
+	if pid < 0 {
+		pid = -pid
+	}
+	pm := &Postmaster{PID: pid}
+	if len(lines) > 1 {
+		pm.DataDir = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		if start, err := strconv.ParseInt(strings.TrimSpace(lines[2]), 10, 64); err == nil {
+			pm.StartTime = time.Unix(start, 0)
+		}
+	}
+	if len(lines) > 3 {
+		pm.Port, _ = strconv.Atoi(strings.TrimSpace(lines[3]))
+	}
+	pm.Alive = processAlive(pid)
+
+	return pm, nil
+}
+
+// detectLive looks for a running postmaster and warns about anything that
+// makes the data files inconsistent.
+func (c *Cluster) detectLive() {
+	pm, err := readPostmaster(c.PGData)
+	if err != nil {
+		pm = &Postmaster{Alive: true, ReadErr: err}
+	}
+	c.Postmaster = pm
+
+	switch {
+	case pm != nil && pm.ReadErr != nil:
+		c.warnf("cluster may be running (postmaster.pid unreadable: %v); data files may change while they are read and pages may be torn", pm.ReadErr)
+	case pm != nil && pm.Alive:
+		c.warnf("postmaster PID %d is running; data files may change while they are read and pages may be torn", pm.PID)
+	case pm != nil:
+		c.warnf("postmaster.pid names PID %d, which is not running; the cluster was not shut down cleanly", pm.PID)
+	case c.Control != nil && c.Control.State != controlfile.DB_SHUTDOWNED &&
+		c.Control.State != controlfile.DB_SHUTDOWNED_IN_RECOVERY:
+		c.warnf("pg_control reports state %q; the cluster was not shut down cleanly", c.Control.State)
+	}
+}
+
+// Live reports whether a postmaster appears to be running on the cluster.
+func (c *Cluster) Live() bool {
+	return c.Postmaster != nil && c.Postmaster.Alive
+}
+
+// TornPageRisk reports whether pages may be torn: either the server is
+// running and writing pages while PDU reads them, or it crashed and the
+// pages have not been repaired by WAL replay.
+func (c *Cluster) TornPageRisk() bool {
+	if c.Postmaster != nil {
+		return true
+	}
+	return c.Control != nil && c.Control.State != controlfile.DB_SHUTDOWNED &&
+		c.Control.State != controlfile.DB_SHUTDOWNED_IN_RECOVERY
+}
+
+// CheckLive returns ErrClusterLive if a postmaster is running on the cluster,
+// or ErrClusterMayBeLive if its postmaster.pid cannot be read, and reading a
+// live cluster was not allowed.
+func (c *Cluster) CheckLive(allowLive bool) error {
+	if !c.Live() || allowLive {
+		return nil
+	}
+	if c.Postmaster.ReadErr != nil {
+		return fmt.Errorf("%s: %w (postmaster.pid unreadable: %v); stop the server, or use --allow-live to read it anyway",
+			c.PGData, ErrClusterMayBeLive, c.Postmaster.ReadErr)
+	}
+	return fmt.Errorf("%s: %w (postmaster PID %d); stop the server, or use --allow-live to read it anyway",
+		c.PGData, ErrClusterLive, c.Postmaster.PID)
+}
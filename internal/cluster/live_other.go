@@ -0,0 +1,9 @@
+//go:build !unix
+
+package cluster
+
+// processAlive cannot tell on this platform whether a process exists, so it
+// assumes that it does.
+func processAlive(pid int) bool {
+	return pid > 0
+}
@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wublabdubdub/pdu/internal/controlfile"
+)
+
+// writePostmaster writes a postmaster.pid as PostgreSQL 15 does.
+func writePostmaster(t *testing.T, pgData string, pid int) {
+	t.Helper()
+	data := fmt.Sprintf("%d\n%s\n1700000000\n5432\n/var/run/postgresql\n*\n  5432001     32768\nready   \n", pid, pgData)
+	if err := os.WriteFile(filepath.Join(pgData, "postmaster.pid"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPostmaster(t *testing.T) {
+	pgData := t.TempDir()
+
+	// A cluster shut down cleanly has no postmaster.pid
+	if pm, err := readPostmaster(pgData); pm != nil || err != nil {
+		t.Errorf("no postmaster.pid: %+v (%v)", pm, err)
+	}
+
+	writePostmaster(t, pgData, os.Getpid())
+	pm, err := readPostmaster(pgData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm.PID != os.Getpid() || pm.DataDir != pgData || !pm.StartTime.Equal(time.Unix(1700000000, 0)) ||
+		pm.Port != 5432 || !pm.Alive {
+		t.Errorf("postmaster %+v", pm)
+	}
+
+	// A standalone backend writes its PID negated
+	writePostmaster(t, pgData, -os.Getpid())
+	if pm, err := readPostmaster(pgData); err != nil || pm.PID != os.Getpid() || !pm.Alive {
+		t.Errorf("standalone backend: %+v (%v)", pm, err)
+	}
+
+	// PIDs beyond any pid_max do not exist
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+		writePostmaster(t, pgData, 1<<30)
+		if pm, err := readPostmaster(pgData); err != nil || pm.PID != 1<<30 || pm.Alive {
+			t.Errorf("stale postmaster.pid: %+v (%v)", pm, err)
+		}
+	}
+
+	// Only the PID is needed
+	if err := os.WriteFile(filepath.Join(pgData, "postmaster.pid"), []byte("42"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pm, err := readPostmaster(pgData); err != nil || pm.PID != 42 || pm.DataDir != "" || pm.Port != 0 {
+		t.Errorf("PID only: %+v (%v)", pm, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(pgData, "postmaster.pid"), []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pm, err := readPostmaster(pgData); err == nil {
+		t.Errorf("empty postmaster.pid: %+v", pm)
+	}
+}
+
+func TestCheckLive(t *testing.T) {
+	tests := []struct {
+		name       string
+		postmaster *Postmaster
+		state      controlfile.DBState
+		live, torn bool
+	}{
+		{"shut down", nil, controlfile.DB_SHUTDOWNED, false, false},
+		{"crashed", nil, controlfile.DB_IN_PRODUCTION, false, true},
+		{"stale postmaster.pid", &Postmaster{PID: 42}, controlfile.DB_IN_PRODUCTION, false, true},
+		{"running", &Postmaster{PID: 42, Alive: true}, controlfile.DB_IN_PRODUCTION, true, true},
+	}
+
+	for _, test := range tests {
+		c := &Cluster{
+			PGData:     "/data",
+			Control:    &controlfile.ControlFileData{State: test.state},
+			Postmaster: test.postmaster,
+		}
+		if c.Live() != test.live || c.TornPageRisk() != test.torn {
+			t.Errorf("%s: live %v, torn page risk %v", test.name, c.Live(), c.TornPageRisk())
+		}
+
+		err := c.CheckLive(false)
+		if test.live != errors.Is(err, ErrClusterLive) || (!test.live && err != nil) {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if test.live && !strings.Contains(err.Error(), "PID 42") {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if err := c.CheckLive(true); err != nil {
+			t.Errorf("%s: live cluster allowed: %v", test.name, err)
+		}
+	}
+}
+
+func TestDetectLive(t *testing.T) {
+	c := &Cluster{PGData: t.TempDir()}
+	c.detectLive()
+	if c.Postmaster != nil || len(c.Warnings) != 0 {
+		t.Errorf("no postmaster.pid: %+v, warnings %q", c.Postmaster, c.Warnings)
+	}
+
+	writePostmaster(t, c.PGData, os.Getpid())
+	c.detectLive()
+	if !c.Live() || len(c.Warnings) != 1 || !strings.Contains(c.Warnings[0], "is running") {
+		t.Errorf("running postmaster: %+v, warnings %q", c.Postmaster, c.Warnings)
+	}
+
+	// A postmaster.pid that cannot be parsed may belong to a running server
+	c = &Cluster{PGData: c.PGData}
+	if err := os.WriteFile(filepath.Join(c.PGData, "postmaster.pid"), []byte("garbage\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c.detectLive()
+	if !c.Live() || c.Postmaster.ReadErr == nil || len(c.Warnings) != 1 || !strings.Contains(c.Warnings[0], "invalid PID") {
+		t.Errorf("unreadable postmaster.pid: %+v, warnings %q", c.Postmaster, c.Warnings)
+	}
+	if err := c.CheckLive(false); !errors.Is(err, ErrClusterMayBeLive) || strings.Contains(err.Error(), "PID 0") {
+		t.Errorf("unreadable postmaster.pid: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package cluster
+
+import "syscall"
+
+// processAlive reports whether a process with the PID exists. A process
+// owned by another user still counts as alive.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress lines.
+const progressInterval = 5 * time.Second
+
+// Progress reports how far a pass over the relations of a cluster has got,
+// together with the current read rate.
+type Progress struct {
+	c     *Cluster
+	w     io.Writer
+	total int
+	last  time.Time
+}
+
+// NewProgress creates a Progress for a pass over total relations. Progress
+// lines go to standard error, so they do not mix with reports.
+func (c *Cluster) NewProgress(total int) *Progress {
+	return &Progress{
+		c:     c,
+		w:     os.Stderr,
+		total: total,
+		last:  time.Now(),
+	}
+}
+
+// Update reports that done relations are finished, printing a progress line
+// if the last one is long enough ago.
+func (p *Progress) Update(done int) {
+	if time.Since(p.last) < progressInterval {
+		return
+	}
+	p.last = time.Now()
+	fmt.Fprintf(p.w, "Progress: %d/%d relations, reading %s\n", done, p.total, p.c.IO.Throttle)
+}
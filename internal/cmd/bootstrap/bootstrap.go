@@ -2,10 +2,15 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
 )
 
 // AddCommand adds the bootstrap command to the root command.
@@ -16,26 +21,79 @@ func AddCommand(rootCmd *cobra.Command) {
 		Short: "Bootstrap metadata from PGDATA",
 		Long:  `Bootstrap metadata from PostgreSQL data files. This command reads PostgreSQL catalog files to build metadata about databases, schemas, tables, and attributes.`,
 		Aliases: []string{"b"},
-		Run: func(cmd *cobra.Command, args []string) {
-			bootstrap()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("verbose", cmd.Flags().Lookup("verbose"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+			viper.BindPFlag("DICTIONARY", cmd.Flags().Lookup("dictionary"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return bootstrap()
 		},
 	}
 
+	// Add flags
+	bootstrapCmd.Flags().BoolP("verbose", "v", false, "List the tables of every database")
+	bootstrapCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
+	bootstrapCmd.Flags().String("dictionary", "./pdu_dictionary.json", "Metadata dictionary file to write")
+
 	// Add the command to the root command
 	rootCmd.AddCommand(bootstrapCmd)
 }
 
 // bootstrap executes the bootstrap process.
-func bootstrap() {
+func bootstrap() error {
 	// Get PGDATA from configuration
 	pgData := viper.GetString("PGDATA")
 	fmt.Printf("Starting bootstrap from PGDATA: %s\n", pgData)
 
-	// TODO: Implement the actual bootstrap logic
-	// 1. Read catalog files (pg_database, pg_namespace, pg_class, pg_attribute, etc.)
-	// 2. Parse the data files to extract metadata
-	// 3. Build the metadata structures
-	// 4. Write the metadata to files for later use
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
+	// Read the system catalogs
+	cat, err := catalog.Bootstrap(context.Background(), c)
+	if err != nil {
+		return err
+	}
+	for _, warning := range cat.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	printCatalog(cat, viper.GetBool("verbose"))
+
+	// Write the metadata for the other commands, and for fixing up by hand
+	for _, problem := range cat.Problems() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+	}
+	path := viper.GetString("DICTIONARY")
+	if err := cat.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Metadata dictionary written to %s\n", path)
 
 	fmt.Println("Bootstrap completed successfully!")
+	return nil
+}
+
+// printCatalog prints a summary of every database and, if verbose, its tables.
+func printCatalog(cat *catalog.Catalog, verbose bool) {
+	for _, db := range cat.Databases {
+		tables := db.UserTables()
+		fmt.Printf("Database %s (oid %d, %s): %d schemas, %d relations, %d user tables, %d types\n",
+			db.Name, db.Oid, pgtypes.Encoding(db.Encoding), len(db.Namespaces), len(db.Relations), len(tables), len(db.Types))
+		if !verbose {
+			continue
+		}
+		for _, rel := range tables {
+			fmt.Printf("  %s (oid %d, relfilenode %d, %d columns)\n",
+				rel.QualifiedName(), rel.Oid, rel.FileNode, len(rel.Columns))
+		}
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/wublabdubdub/pdu/internal/cmd/bootstrap"
 	"github.com/wublabdubdub/pdu/internal/cmd/dropscan"
+	"github.com/wublabdubdub/pdu/internal/cmd/guessschema"
 	"github.com/wublabdubdub/pdu/internal/cmd/info"
 	"github.com/wublabdubdub/pdu/internal/cmd/restore"
 	"github.com/wublabdubdub/pdu/internal/cmd/scan"
@@ -28,6 +29,9 @@ func AddCommands(rootCmd *cobra.Command) {
 	// Add dropscan command
 	dropscan.AddCommand(rootCmd)
 
+	// Add guess-schema command
+	guessschema.AddCommand(rootCmd)
+
 	// Add info command
 	info.AddCommand(rootCmd)
 }
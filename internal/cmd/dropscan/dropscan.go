@@ -3,9 +3,11 @@ package dropscan
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/cluster"
 )
 
 // AddCommand adds the dropscan command to the root command.
@@ -16,29 +18,49 @@ func AddCommand(rootCmd *cobra.Command) {
 		Short: "Scan for dropped tables and data",
 		Long:  `Scan PostgreSQL data files for dropped tables and recoverable data. This command can identify and extract data from dropped tables.`,
 		Aliases: []string{"ds"},
-		Run: func(cmd *cobra.Command, args []string) {
-			dropscan()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+			viper.BindPFlag("DISK_PATH", cmd.Flags().Lookup("disk-path"))
+			viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dropscan()
 		},
 	}
 
 	// Add flags
+	dropscanCmd.Flags().StringP("pgdata", "p", ".", "Path to PostgreSQL data directory")
 	dropscanCmd.Flags().StringP("disk-path", "d", ".", "Path to scan for dropped data")
 	dropscanCmd.Flags().StringP("output", "o", "./dropscan_output", "Output directory for recovered data")
-	viper.BindPFlag("DISK_PATH", dropscanCmd.Flags().Lookup("disk-path"))
+	dropscanCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
 
 	// Add the command to the root command
 	rootCmd.AddCommand(dropscanCmd)
 }
 
 // dropscan executes the dropscan process.
-func dropscan() {
+func dropscan() error {
 	// Get parameters from configuration
+	pgData := viper.GetString("PGDATA")
 	diskPath := viper.GetString("DISK_PATH")
 	outputDir := viper.GetString("output")
 	
 	fmt.Printf("Starting dropscan from disk path: %s\n", diskPath)
 	fmt.Printf("Output directory: %s\n", outputDir)
 
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
 	// TODO: Implement the actual dropscan logic
 	// 1. Scan PostgreSQL data files for dropped tables
 	// 2. Identify recoverable data blocks
@@ -46,4 +68,5 @@ func dropscan() {
 	// 4. Write recovered data to output directory
 
 	fmt.Println("Dropscan completed successfully!")
+	return nil
 }
\ No newline at end of file
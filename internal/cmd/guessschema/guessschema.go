@@ -0,0 +1,131 @@
+// Package guessschema provides the guess-schema command for PDU.
+package guessschema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/guess"
+	"github.com/wublabdubdub/pdu/internal/pager"
+)
+
+// AddCommand adds the guess-schema command to the root command.
+func AddCommand(rootCmd *cobra.Command) {
+	// Create guess-schema command
+	guessCmd := &cobra.Command{
+		Use:     "guess-schema",
+		Short:   "Guess the columns of a data file without a catalog",
+		Long:    `Guess the column types of a relation data file whose catalog entries are lost. Tuples are sampled from the file and the column types are inferred from the number of columns, null bitmaps, alignment, varlena headers and the plausibility of the values. The candidate table definitions are written as CREATE TABLE statements for unload --table-def.`,
+		Aliases: []string{"gs"},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+			viper.BindPFlag("relfile", cmd.Flags().Lookup("relfile"))
+			viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+			viper.BindPFlag("samples", cmd.Flags().Lookup("samples"))
+			viper.BindPFlag("candidates", cmd.Flags().Lookup("candidates"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return guessSchema()
+		},
+	}
+
+	// Add flags
+	guessCmd.Flags().StringP("pgdata", "p", ".", "Path to PostgreSQL data directory")
+	guessCmd.Flags().String("relfile", "", "Relation data file to guess the columns of, e.g. base/16384/16385")
+	guessCmd.Flags().StringP("output", "o", "", "File to write the candidate CREATE TABLE statements to (default <relfile name>_guess.sql)")
+	guessCmd.Flags().Int("samples", 2000, "Number of tuples to sample")
+	guessCmd.Flags().Int("candidates", 5, "Number of candidate table definitions to list")
+	guessCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
+
+	// Add the command to the root command
+	rootCmd.AddCommand(guessCmd)
+}
+
+// guessSchema executes the guess-schema process.
+func guessSchema() error {
+	// Get parameters from configuration
+	pgData := viper.GetString("PGDATA")
+	relFile := viper.GetString("relfile")
+	outputFile := viper.GetString("output")
+	samples := viper.GetInt("samples")
+	if relFile == "" {
+		return fmt.Errorf("--relfile is required")
+	}
+	if samples <= 0 {
+		return fmt.Errorf("--samples must be positive")
+	}
+	name := strings.TrimSuffix(filepath.Base(relFile), filepath.Ext(relFile))
+	if outputFile == "" {
+		outputFile = name + "_guess.sql"
+	}
+
+	fmt.Printf("Guessing the columns of: %s\n", relFile)
+
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
+	// Sample tuples from across the file
+	reader := fileio.NewSegmentedFileReader(fileio.MAIN_FORKNUM, c.Params)
+	reader.SetIOOptions(c.IO)
+	processor := pager.NewPageProcessor(reader, pager.NewPageParser(c.Params))
+	if err := processor.Open(relFile); err != nil {
+		return err
+	}
+	tuples := guess.Sample(processor, samples)
+	blocks := processor.PageCount()
+	processor.Close()
+	if len(tuples) == 0 {
+		return fmt.Errorf("%s: no tuples found", relFile)
+	}
+	fmt.Printf("Sampled tuples: %d (file of %d blocks)\n", len(tuples), blocks)
+
+	candidates := guess.Infer(tuples, c.Params.ByteOrder, viper.GetInt("candidates"))
+
+	// List the candidates and write them for unload
+	var statements strings.Builder
+	statements.WriteString(fmt.Sprintf("-- Candidate definitions of %s, best first\n", relFile))
+	for i, candidate := range candidates {
+		table := fmt.Sprintf("rel_%s_%d", sanitize(name), i+1)
+		summary := fmt.Sprintf("%s: %d columns, score %.2f: %d of %d tuples decode, plausibility %.2f",
+			table, len(candidate.Columns), candidate.Score(), candidate.Decoded, candidate.Tuples, candidate.Plausibility)
+		fmt.Printf("%d. %s\n", i+1, summary)
+		for j, col := range candidate.Columns {
+			fmt.Printf("     col%d %s\n", j+1, col.Type.Name)
+		}
+		statements.WriteString("\n-- " + summary + "\n")
+		statements.WriteString(candidate.CreateTable(table))
+	}
+	if err := os.WriteFile(outputFile, []byte(statements.String()), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Candidate definitions written to %s\n", outputFile)
+	fmt.Printf("Unload with: pdu unload --table-def %s --table rel_%s_1 --relfile %s\n", outputFile, sanitize(name), relFile)
+	return nil
+}
+
+// sanitize makes a file name usable in an unquoted table name.
+func sanitize(name string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name))
+}
@@ -2,10 +2,15 @@
 package info
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/controlfile"
 )
 
 // AddCommand adds the info command to the root command.
@@ -14,33 +19,196 @@ func AddCommand(rootCmd *cobra.Command) {
 	infoCmd := &cobra.Command{
 		Use:   "info",
 		Short: "Show information about PostgreSQL data files",
-		Long:  `Display detailed information about PostgreSQL data files, including database structure, table statistics, and file system information.`,
+		Long:  `Display detailed information about PostgreSQL data files, including the contents of global/pg_control. Unlike pg_controldata, this works on a damaged or dead cluster and reports a CRC mismatch instead of giving up.`,
 		Aliases: []string{"i"},
-		Run: func(cmd *cobra.Command, args []string) {
-			info()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			return info(format)
 		},
 	}
 
 	// Add flags
 	infoCmd.Flags().StringP("pgdata", "p", ".", "Path to PostgreSQL data directory")
-	viper.BindPFlag("PGDATA", infoCmd.Flags().Lookup("pgdata"))
+	infoCmd.Flags().StringP("format", "f", "text", "Output format (text, json)")
 
 	// Add the command to the root command
 	rootCmd.AddCommand(infoCmd)
 }
 
+// report is the JSON form of the info output.
+type report struct {
+	PGData    string `json:"pgdata"`
+	Version   string `json:"version"`
+	ByteOrder string `json:"byte_order"`
+	*controlfile.ControlFileData
+}
+
 // info executes the info process.
-func info() {
+func info(format string) error {
 	// Get PGDATA from configuration
 	pgData := viper.GetString("PGDATA")
-	
-	fmt.Printf("Getting information from PGDATA: %s\n", pgData)
 
-	// TODO: Implement the actual info logic
-	// 1. Read PostgreSQL data directory structure
-	// 2. Parse database and table metadata
-	// 3. Display information about tables, indexes, and data files
-	// 4. Show file system statistics and disk usage
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	c.PrintWarnings(os.Stderr)
+	if c.Control == nil {
+		return fmt.Errorf("no usable pg_control found in %s", pgData)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report{
+			PGData:          pgData,
+			Version:         c.Params.Profile.Name,
+			ByteOrder:       c.Control.ByteOrder.String(),
+			ControlFileData: c.Control,
+		})
+	case "text", "":
+		printControlFile(pgData, c)
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q: expected text or json", format)
+	}
+}
+
+// printControlFile prints pg_control in the style of pg_controldata.
+func printControlFile(pgData string, c *cluster.Cluster) {
+	control := c.Control
+	cp := control.CheckPointCopy
+	row := func(label string, value interface{}) {
+		fmt.Printf("%-42s%v\n", label+":", value)
+	}
 
-	fmt.Println("Info command completed successfully!")
-}
\ No newline at end of file
+	row("PGDATA", pgData)
+	row("PostgreSQL version", c.Params.Profile.Name)
+	row("Byte order", control.ByteOrder)
+	row("CRC", crcStatus(control))
+	row("pg_control version number", control.PgControlVersion)
+	row("Catalog version number", control.CatalogVersionNo)
+	row("Database system identifier", control.SystemIdentifier)
+	row("Database cluster state", control.State)
+	row("pg_control last modified", formatTime(control.Time))
+	row("Latest checkpoint location", control.CheckPoint)
+	if control.PgControlVersion < controlfile.PG_CONTROL_VERSION_11 {
+		row("Prior checkpoint location", control.PrevCheckPoint)
+	}
+	row("Latest checkpoint's REDO location", cp.Redo)
+	row("Latest checkpoint's TimeLineID", cp.ThisTimeLineID)
+	row("Latest checkpoint's PrevTimeLineID", cp.PrevTimeLineID)
+	row("Latest checkpoint's full_page_writes", onOff(cp.FullPageWrites))
+	row("Latest checkpoint's NextXID", fmt.Sprintf("%d:%d", uint32(cp.NextXid>>32), uint32(cp.NextXid)))
+	row("Latest checkpoint's NextOID", cp.NextOid)
+	row("Latest checkpoint's NextMultiXactId", cp.NextMulti)
+	row("Latest checkpoint's NextMultiOffset", cp.NextMultiOffset)
+	row("Latest checkpoint's oldestXID", cp.OldestXid)
+	row("Latest checkpoint's oldestXID's DB", cp.OldestXidDB)
+	row("Latest checkpoint's oldestActiveXID", cp.OldestActiveXid)
+	row("Latest checkpoint's oldestMultiXid", cp.OldestMulti)
+	row("Latest checkpoint's oldestMulti's DB", cp.OldestMultiDB)
+	row("Latest checkpoint's oldestCommitTsXid", cp.OldestCommitTsXid)
+	row("Latest checkpoint's newestCommitTsXid", cp.NewestCommitTsXid)
+	row("Time of latest checkpoint", formatTime(cp.Time))
+	row("Fake LSN counter for unlogged rels", control.UnloggedLSN)
+	row("Minimum recovery ending location", control.MinRecoveryPoint)
+	row("Min recovery ending loc's timeline", control.MinRecoveryPointTLI)
+	row("Backup start location", control.BackupStartPoint)
+	row("Backup end location", control.BackupEndPoint)
+	row("End-of-backup record required", yesNo(control.BackupEndRequired))
+	row("wal_level setting", control.WalLevel)
+	row("wal_log_hints setting", onOff(control.WalLogHints))
+	row("max_connections setting", control.MaxConnections)
+	row("max_worker_processes setting", control.MaxWorkerProcesses)
+	if control.PgControlVersion >= controlfile.PG_CONTROL_VERSION_12 {
+		row("max_wal_senders setting", control.MaxWalSenders)
+	}
+	row("max_prepared_xacts setting", control.MaxPreparedXacts)
+	row("max_locks_per_xact setting", control.MaxLocksPerXact)
+	row("track_commit_timestamp setting", onOff(control.TrackCommitTimestamp))
+	row("Maximum data alignment", control.MaxAlign)
+	row("Float format", floatFormat(control))
+	row("Database block size", control.BlckSz)
+	row("Blocks per segment of large relation", control.RelSegSize)
+	row("WAL block size", control.XLogBlcksz)
+	row("Bytes per WAL segment", control.XLogSegSize)
+	row("Maximum length of identifiers", control.NameDataLen)
+	row("Maximum columns in an index", control.IndexMaxKeys)
+	row("Maximum size of a TOAST chunk", control.ToastMaxChunkSize)
+	row("Size of a large-object chunk", control.LoBlkSize)
+	row("Date/time type storage", dateTimeStorage(control.EnableIntTimes))
+	row("Float4 argument passing", byValue(control.Float4ByVal))
+	row("Float8 argument passing", byValue(control.Float8ByVal))
+	row("Data page checksum version", control.DataChecksumVersion)
+	if control.PgControlVersion >= controlfile.PG_CONTROL_VERSION_18 {
+		row("Default char data signedness", signedness(control.DefaultCharSignedness))
+	}
+}
+
+// crcStatus describes whether the stored CRC matches.
+func crcStatus(control *controlfile.ControlFileData) string {
+	if control.CRCValid {
+		return fmt.Sprintf("%08X (ok)", control.CRC)
+	}
+	return fmt.Sprintf("%08X (MISMATCH)", control.CRC)
+}
+
+// floatFormat describes the float format check value.
+func floatFormat(control *controlfile.ControlFileData) string {
+	if control.FloatFormatValid() {
+		return "IEEE 754"
+	}
+	return fmt.Sprintf("unexpected (%g)", control.FloatFormat)
+}
+
+// formatTime formats a pg_time_t value.
+func formatTime(t int64) string {
+	return time.Unix(t, 0).Format("Mon Jan 2 15:04:05 2006")
+}
+
+// onOff formats a boolean setting.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// yesNo formats a boolean flag.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// byValue formats an argument passing mode.
+func byValue(b bool) string {
+	if b {
+		return "by value"
+	}
+	return "by reference"
+}
+
+// dateTimeStorage formats the date/time storage mode.
+func dateTimeStorage(intTimes bool) string {
+	if intTimes {
+		return "64-bit integers"
+	}
+	return "floating-point numbers"
+}
+
+// signedness formats the default char signedness.
+func signedness(signed bool) string {
+	if signed {
+		return "signed"
+	}
+	return "unsigned"
+}
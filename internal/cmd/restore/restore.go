@@ -3,9 +3,12 @@ package restore
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/internal/cluster"
 )
 
 // AddCommand adds the restore command to the root command.
@@ -16,8 +19,16 @@ func AddCommand(rootCmd *cobra.Command) {
 		Short: "Restore data from PostgreSQL data files",
 		Long:  `Restore data from PostgreSQL data files to a running PostgreSQL database. This command can restore tables, indexes, and other database objects.`,
 		Aliases: []string{"r"},
-		Run: func(cmd *cobra.Command, args []string) {
-			restore()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+			viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+			viper.BindPFlag("dbname", cmd.Flags().Lookup("dbname"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+			viper.BindPFlag("DICTIONARY", cmd.Flags().Lookup("dictionary"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return restore()
 		},
 	}
 
@@ -25,14 +36,15 @@ func AddCommand(rootCmd *cobra.Command) {
 	restoreCmd.Flags().StringP("pgdata", "p", ".", "Path to PostgreSQL data directory")
 	restoreCmd.Flags().StringP("output", "o", "./restore_output", "Output directory for restore scripts")
 	restoreCmd.Flags().StringP("dbname", "d", "postgres", "Target database name")
-	viper.BindPFlag("PGDATA", restoreCmd.Flags().Lookup("pgdata"))
+	restoreCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
+	restoreCmd.Flags().String("dictionary", "./pdu_dictionary.json", "Metadata dictionary file written by bootstrap")
 
 	// Add the command to the root command
 	rootCmd.AddCommand(restoreCmd)
 }
 
 // restore executes the restore process.
-func restore() {
+func restore() error {
 	// Get parameters from configuration
 	pgData := viper.GetString("PGDATA")
 	outputDir := viper.GetString("output")
@@ -42,6 +54,26 @@ func restore() {
 	fmt.Printf("Output directory: %s\n", outputDir)
 	fmt.Printf("Target database: %s\n", dbname)
 
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
+	// Load the metadata written by bootstrap
+	dict, err := catalog.OpenDictionary(c, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if dict != nil {
+		fmt.Printf("Dictionary: %s\n", dict)
+	}
+
 	// TODO: Implement the actual restore logic
 	// 1. Read PostgreSQL data files
 	// 2. Generate SQL restore scripts
@@ -49,4 +81,5 @@ func restore() {
 	// 4. Verify restored data integrity
 
 	fmt.Println("Restore command completed successfully!")
+	return nil
 }
\ No newline at end of file
@@ -3,9 +3,16 @@ package scan
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/guess"
+	"github.com/wublabdubdub/pdu/internal/pager"
 )
 
 // AddCommand adds the scan command to the root command.
@@ -16,8 +23,20 @@ func AddCommand(rootCmd *cobra.Command) {
 		Short: "Scan PostgreSQL data files",
 		Long:  `Scan PostgreSQL data files to identify tables, indexes, and other database objects. This command can detect structural issues and provide detailed information about database objects.`,
 		Aliases: []string{"s"},
-		Run: func(cmd *cobra.Command, args []string) {
-			scan()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+			viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+			viper.BindPFlag("verbose", cmd.Flags().Lookup("verbose"))
+			viper.BindPFlag("VERIFY_CHECKSUMS", cmd.Flags().Lookup("verify-checksums"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+			viper.BindPFlag("DICTIONARY", cmd.Flags().Lookup("dictionary"))
+			viper.BindPFlag("orphans", cmd.Flags().Lookup("orphans"))
+			viper.BindPFlag("samples", cmd.Flags().Lookup("samples"))
+			viper.BindPFlag("matches", cmd.Flags().Lookup("matches"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scan()
 		},
 	}
 
@@ -25,14 +44,19 @@ func AddCommand(rootCmd *cobra.Command) {
 	scanCmd.Flags().StringP("pgdata", "p", ".", "Path to PostgreSQL data directory")
 	scanCmd.Flags().StringP("output", "o", "./scan_output", "Output directory for scan results")
 	scanCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
-	viper.BindPFlag("PGDATA", scanCmd.Flags().Lookup("pgdata"))
+	scanCmd.Flags().Bool("verify-checksums", false, "Verify data page checksums and list failing blocks")
+	scanCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
+	scanCmd.Flags().String("dictionary", "./pdu_dictionary.json", "Metadata dictionary file written by bootstrap")
+	scanCmd.Flags().Bool("orphans", false, "Match data files that no relation in the dictionary owns to known table definitions")
+	scanCmd.Flags().Int("samples", 200, "Number of tuples to sample from each orphan file")
+	scanCmd.Flags().Int("matches", 3, "Number of likely matches to list for each orphan file")
 
 	// Add the command to the root command
 	rootCmd.AddCommand(scanCmd)
 }
 
 // scan executes the scan process.
-func scan() {
+func scan() error {
 	// Get parameters from configuration
 	pgData := viper.GetString("PGDATA")
 	outputDir := viper.GetString("output")
@@ -42,11 +66,232 @@ func scan() {
 	fmt.Printf("Output directory: %s\n", outputDir)
 	fmt.Printf("Verbose mode: %v\n", verbose)
 
-	// TODO: Implement the actual scan logic
-	// 1. Scan PostgreSQL data directory structure
-	// 2. Identify and parse database objects (tables, indexes, etc.)
-	// 3. Check for structural issues and inconsistencies
-	// 4. Generate detailed scan reports
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	verifyChecksums := viper.GetBool("VERIFY_CHECKSUMS")
+	if verifyChecksums {
+		c.EnableChecksumVerification()
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
+	// Load the metadata written by bootstrap
+	dict, err := catalog.OpenDictionary(c, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if dict != nil {
+		fmt.Printf("Dictionary: %s\n", dict)
+	}
+
+	// Match orphan files instead of checking relations
+	if viper.GetBool("orphans") {
+		if dict == nil {
+			return fmt.Errorf("matching orphan files needs the metadata dictionary; run pdu bootstrap first")
+		}
+		if err := scanOrphans(c, dict, verbose); err != nil {
+			return err
+		}
+		fmt.Println("Scan command completed successfully!")
+		return nil
+	}
+
+	// Check every relation for missing segments and, if requested, checksum failures
+	relations, err := c.FindRelations()
+	if err != nil {
+		return err
+	}
+	var failures, damaged int
+	progress := c.NewProgress(len(relations))
+	for i, path := range relations {
+		progress.Update(i)
+		if !verifyChecksums {
+			reader := c.NewRelationReader()
+			if err := reader.Open(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			problems := reader.Problems()
+			if verbose {
+				fmt.Printf("%s%s: %d blocks\n", path, describe(c, dict, path), reader.Main().GetPageCount())
+			}
+			reader.Close()
+			printProblems(problems)
+			if len(problems) > 0 {
+				damaged++
+			}
+			continue
+		}
+
+		reports, problems, err := c.VerifyRelationChecksums(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		printProblems(problems)
+		relationFailures := 0
+		for _, report := range reports {
+			relationFailures += len(report.Failures)
+			if verbose || len(report.Failures) > 0 || len(report.Unreadable) > 0 {
+				report.Print(os.Stdout)
+			}
+		}
+		failures += relationFailures
+		if relationFailures > 0 || len(problems) > 0 {
+			damaged++
+		}
+	}
+
+	fmt.Printf("Relations scanned: %d, with problems: %d\n", len(relations), damaged)
+	if verifyChecksums {
+		fmt.Printf("Checksum failures: %d\n", failures)
+		fmt.Printf("I/O: %s\n", c.IO.Stats.Snapshot())
+		fmt.Printf("Read rate: %s\n", c.IO.Throttle.Summary())
+	}
+
+	// TODO: Write the scan report to the output directory
 
 	fmt.Println("Scan command completed successfully!")
-}
\ No newline at end of file
+	return nil
+}
+
+// describe returns the name of the relation stored in path, in parentheses,
+// or "" if the dictionary does not know it.
+func describe(c *cluster.Cluster, dict *catalog.Catalog, path string) string {
+	if dict == nil {
+		return ""
+	}
+	db, rel := dict.RelationForPath(c, path)
+	if rel == nil {
+		return ""
+	}
+	if rel.IsShared {
+		return fmt.Sprintf(" (%s)", rel.QualifiedName())
+	}
+	return fmt.Sprintf(" (%s.%s)", db.Name, rel.QualifiedName())
+}
+
+// scanOrphans finds the data files in each database directory that no
+// relation in the dictionary owns, fingerprints them and lists the table
+// definitions that decode them best. Tables whose own file is missing are
+// marked, as their mapping was likely lost.
+func scanOrphans(c *cluster.Cluster, dict *catalog.Catalog, verbose bool) error {
+	samples := viper.GetInt("samples")
+	maxMatches := viper.GetInt("matches")
+
+	total := 0
+	for _, db := range dict.Databases {
+		orphans, err := findOrphans(c, db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database %s: %d orphan files\n", db.Name, len(orphans))
+		total += len(orphans)
+
+		// Tables with storage are the candidates
+		var relations []*catalog.Relation
+		for _, rel := range db.Relations {
+			switch rel.Kind {
+			case catalog.RELKIND_RELATION, catalog.RELKIND_MATVIEW, catalog.RELKIND_TOASTVALUE:
+				relations = append(relations, rel)
+			}
+		}
+
+		for _, path := range orphans {
+			tuples, blocks, err := sampleFile(c, path, samples)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			if len(tuples) == 0 {
+				fmt.Printf("%s: %d blocks, no heap tuples (an index, sequence or empty table)\n", path, blocks)
+				continue
+			}
+			fmt.Printf("%s: %d blocks, %d tuples sampled, %s\n", path, blocks, len(tuples), guess.NewFingerprint(tuples))
+
+			matches := guess.MatchRelations(relations, tuples, c.Params.ByteOrder)
+			if len(matches) == 0 {
+				fmt.Printf("  no known table decodes it; try pdu guess-schema --relfile %s\n", path)
+				continue
+			}
+			for i, match := range matches {
+				if i == maxMatches {
+					break
+				}
+				note := ""
+				if _, err := os.Stat(db.RelationPath(c, match.Relation)); os.IsNotExist(err) {
+					note = ", its own file is missing"
+				}
+				fmt.Printf("  %d. %s (oid %d, relfilenode %d): score %.2f, %d of %d tuples decode, plausibility %.2f%s\n",
+					i+1, match.Relation.QualifiedName(), match.Relation.Oid, match.Relation.FileNode,
+					match.Score(), match.Decoded, match.Tuples, match.Plausibility, note)
+			}
+			if verbose {
+				best := matches[0].Relation
+				fmt.Printf("  unload with: pdu unload -d %s --table %s --relfile %s\n", db.Name, best.QualifiedName(), path)
+			}
+		}
+	}
+
+	fmt.Printf("Orphan files: %d\n", total)
+	return nil
+}
+
+// findOrphans returns the data files in the directories of a database, in
+// each tablespace it uses, whose relfilenode belongs to no relation of the
+// dictionary in that tablespace.
+func findOrphans(c *cluster.Cluster, db *catalog.Database) ([]string, error) {
+	// The database's own tablespace, and those of its relations
+	tablespaces := []uint32{db.Tablespace(0)}
+	seen := map[uint32]bool{tablespaces[0]: true}
+	for _, rel := range db.Relations {
+		if tablespace := db.Tablespace(rel.TablespaceOid); !rel.IsShared && !seen[tablespace] {
+			seen[tablespace] = true
+			tablespaces = append(tablespaces, tablespace)
+		}
+	}
+
+	var orphans []string
+	for _, tablespace := range tablespaces {
+		dir := c.DatabasePath(tablespace, db.Oid)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		paths, err := fileio.FindRelations(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			file, ok := fileio.ParseRelationFileName(filepath.Base(path))
+			if ok && db.RelationByFileNode(tablespace, file.RelFileNode) == nil {
+				orphans = append(orphans, path)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// sampleFile samples the tuples of a data file, and returns its size in blocks.
+func sampleFile(c *cluster.Cluster, path string, samples int) ([]*pager.Tuple, int64, error) {
+	reader := fileio.NewSegmentedFileReader(fileio.MAIN_FORKNUM, c.Params)
+	reader.SetIOOptions(c.IO)
+	processor := pager.NewPageProcessor(reader, pager.NewPageParser(c.Params))
+	if err := processor.Open(path); err != nil {
+		return nil, 0, err
+	}
+	defer processor.Close()
+	return guess.Sample(processor, samples), processor.PageCount(), nil
+}
+
+// printProblems prints missing or short segment files.
+func printProblems(problems []fileio.SegmentProblem) {
+	for _, problem := range problems {
+		fmt.Printf("%s\n", problem)
+	}
+}
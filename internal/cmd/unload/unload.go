@@ -2,10 +2,25 @@
 package unload
 
 import (
+	"context"
+	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/internal/cluster"
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/manifest"
+	"github.com/wublabdubdub/pdu/internal/output"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/internal/pipeline"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
 )
 
 // AddCommand adds the unload command to the root command.
@@ -16,8 +31,27 @@ func AddCommand(rootCmd *cobra.Command) {
 		Short: "Unload data from PostgreSQL data files",
 		Long:  `Unload data from PostgreSQL data files to SQL, CSV, or other formats. This command can extract data from tables without requiring a running database instance.`,
 		Aliases: []string{"u"},
-		Run: func(cmd *cobra.Command, args []string) {
-			unload()
+		PreRun: func(cmd *cobra.Command, args []string) {
+			// Bind flags here so that only the command being run sets the configuration
+			viper.BindPFlag("PGDATA", cmd.Flags().Lookup("pgdata"))
+			viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+			viper.BindPFlag("format", cmd.Flags().Lookup("format"))
+			viper.BindPFlag("dbname", cmd.Flags().Lookup("dbname"))
+			viper.BindPFlag("VERIFY_CHECKSUMS", cmd.Flags().Lookup("verify-checksums"))
+			viper.BindPFlag("ERROR_POLICY", cmd.Flags().Lookup("on-error"))
+			viper.BindPFlag("MAX_ERRORS", cmd.Flags().Lookup("max-errors"))
+			viper.BindPFlag("WORKERS", cmd.Flags().Lookup("workers"))
+			viper.BindPFlag("ORDERED_OUTPUT", cmd.Flags().Lookup("ordered"))
+			viper.BindPFlag("ALLOW_LIVE", cmd.Flags().Lookup("allow-live"))
+			viper.BindPFlag("DICTIONARY", cmd.Flags().Lookup("dictionary"))
+			viper.BindPFlag("table", cmd.Flags().Lookup("table"))
+			viper.BindPFlag("table_def", cmd.Flags().Lookup("table-def"))
+			viper.BindPFlag("relfile", cmd.Flags().Lookup("relfile"))
+			viper.BindPFlag("dropped_columns", cmd.Flags().Lookup("dropped-columns"))
+			viper.BindPFlag("encoding", cmd.Flags().Lookup("encoding"))
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return unload()
 		},
 	}
 
@@ -26,30 +60,394 @@ func AddCommand(rootCmd *cobra.Command) {
 	unloadCmd.Flags().StringP("output", "o", "./unload_output", "Output directory for unloaded data")
 	unloadCmd.Flags().StringP("format", "f", "sql", "Output format (sql, csv, json)")
 	unloadCmd.Flags().StringP("dbname", "d", "postgres", "Database name to unload")
-	viper.BindPFlag("PGDATA", unloadCmd.Flags().Lookup("pgdata"))
+	unloadCmd.Flags().Bool("verify-checksums", false, "Verify data page checksums and list failing blocks")
+	unloadCmd.Flags().String("on-error", "skip-tuple", "What to do with damaged pages and tuples (fail-fast, skip-page, skip-tuple, salvage)")
+	unloadCmd.Flags().Int("max-errors", 0, "Give up on a relation after skipping this many pages and tuples (0 for no limit)")
+	unloadCmd.Flags().Int("workers", 0, "Number of page decoding workers (0 for one per CPU)")
+	unloadCmd.Flags().Bool("ordered", false, "Write rows in the order they are stored in each relation")
+	unloadCmd.Flags().Bool("allow-live", false, "Read the cluster even if its server is running")
+	unloadCmd.Flags().String("dictionary", "./pdu_dictionary.json", "Metadata dictionary file written by bootstrap")
+	unloadCmd.Flags().StringP("table", "t", "", "Table to unload, optionally schema-qualified (default all user tables)")
+	unloadCmd.Flags().String("table-def", "", "File with CREATE TABLE statements or a column list describing --relfile")
+	unloadCmd.Flags().String("relfile", "", "Relation data file to unload, e.g. base/16384/16385; with --table, read it as that table")
+	unloadCmd.Flags().Bool("dropped-columns", false, "Also write the raw bytes of dropped columns, in hex, as columns dropped_<attnum>")
+	unloadCmd.Flags().String("encoding", "", "Encoding of the text in the data files, e.g. LATIN1 (default the database's, from the dictionary, or UTF8)")
 
 	// Add the command to the root command
 	rootCmd.AddCommand(unloadCmd)
 }
 
 // unload executes the unload process.
-func unload() {
+func unload() error {
 	// Get parameters from configuration
 	pgData := viper.GetString("PGDATA")
 	outputDir := viper.GetString("output")
-	format := viper.GetString("format")
 	dbname := viper.GetString("dbname")
-	
+	format, err := output.ParseFormat(viper.GetString("format"))
+	if err != nil {
+		return err
+	}
+	if name := viper.GetString("encoding"); name != "" {
+		if _, err := pgtypes.ParseEncoding(name); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("Starting unload from PGDATA: %s\n", pgData)
 	fmt.Printf("Output directory: %s\n", outputDir)
 	fmt.Printf("Output format: %s\n", format)
 	fmt.Printf("Database: %s\n", dbname)
 
-	// TODO: Implement the actual unload logic
-	// 1. Read PostgreSQL data files
-	// 2. Parse table structures and data
-	// 3. Extract tuples from heap pages
-	// 4. Write data to output files in specified format
+	// Decide how to handle damaged pages
+	policy, err := pager.ParseErrorPolicy(viper.GetString("ERROR_POLICY"))
+	if err != nil {
+		return err
+	}
+	maxErrors := viper.GetInt("MAX_ERRORS")
+	if maxErrors > 0 {
+		fmt.Printf("Error policy: %s (at most %d errors per relation)\n", policy, maxErrors)
+	} else {
+		fmt.Printf("Error policy: %s\n", policy)
+	}
+	pipelineOpts := pipeline.OptionsFromConfig()
+	if pipelineOpts.Workers > 0 {
+		fmt.Printf("Workers: %d\n", pipelineOpts.Workers)
+	} else {
+		fmt.Printf("Workers: %d (one per CPU)\n", runtime.NumCPU())
+	}
+
+	// Detect storage parameters of the cluster
+	c, err := cluster.Open(pgData, cluster.OptionsFromConfig())
+	if err != nil {
+		return err
+	}
+	verifyChecksums := viper.GetBool("VERIFY_CHECKSUMS")
+	if verifyChecksums {
+		c.EnableChecksumVerification()
+	}
+	c.PrintWarnings(os.Stderr)
+	if err := c.CheckLive(viper.GetBool("ALLOW_LIVE")); err != nil {
+		return err
+	}
+	fmt.Printf("Storage parameters: %s\n", c.Params)
+
+	// Load the metadata written by bootstrap
+	dict, err := catalog.OpenDictionary(c, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if dict != nil {
+		fmt.Printf("Dictionary: %s\n", dict)
+	}
+
+	// Decide what to unload
+	targets, err := selectTargets(c, dict, dbname)
+	if err != nil {
+		return err
+	}
+
+	// Record where the output comes from, and whether pages may be torn
+	if err := manifest.New("unload", c).Write(outputDir); err != nil {
+		return err
+	}
+
+	// Verify checksums before unloading, so damaged blocks are known up front
+	if verifyChecksums {
+		if err := verifyRelationChecksums(c); err != nil {
+			return err
+		}
+	}
+
+	// Unload each table to its own file
+	u := &unloader{
+		ctx:          context.Background(),
+		cluster:      c,
+		clog:         catalog.NewCommitLog(c),
+		format:       format,
+		outputDir:    outputDir,
+		policy:       policy,
+		maxErrors:    maxErrors,
+		verify:       verifyChecksums,
+		dropped:      viper.GetBool("dropped_columns"),
+		pipelineOpts: pipelineOpts,
+	}
+	failed := 0
+	for _, target := range targets {
+		if err := u.unloadRelation(target); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", target.rel.QualifiedName(), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tables could not be unloaded", failed, len(targets))
+	}
 
 	fmt.Println("Unload command completed successfully!")
-}
\ No newline at end of file
+	return nil
+}
+
+// target is a table to unload and the file holding its data.
+type target struct {
+	rel      *catalog.Relation
+	path     string
+	encoding pgtypes.Encoding // encoding of its text
+}
+
+// selectTargets returns the tables to unload: the one described by
+// --table-def, the dictionary's table stored in --relfile, the table named by
+// --table read from --relfile, or the tables of the database, all user tables
+// or the one named by --table.
+func selectTargets(c *cluster.Cluster, dict *catalog.Catalog, dbname string) ([]target, error) {
+	table := viper.GetString("table")
+	tableDef := viper.GetString("table_def")
+	relFile := viper.GetString("relfile")
+
+	// A table the user describes, for files the catalog cannot
+	if tableDef != "" {
+		if relFile == "" {
+			return nil, fmt.Errorf("--table-def needs --relfile, the data file it describes")
+		}
+		relations, err := catalog.ReadTableDefinitions(tableDef, c.Params.Profile)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := chooseDefinition(relations, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tableDef, err)
+		}
+		fmt.Printf("Table definition: %s (%d columns) from %s\n", rel.QualifiedName(), len(rel.Columns), tableDef)
+		var db *catalog.Database
+		if dict != nil {
+			db = dict.Database(dbname)
+		}
+		return []target{{rel: rel, path: relFile, encoding: textEncoding(db)}}, nil
+	}
+
+	if dict == nil {
+		return nil, fmt.Errorf("no table definitions; run pdu bootstrap, or describe the table with --table-def and --relfile")
+	}
+
+	// A single file the dictionary knows
+	if relFile != "" && table == "" {
+		db, rel := dict.RelationForPath(c, relFile)
+		if rel == nil {
+			return nil, fmt.Errorf("%s is not in the metadata dictionary; describe its table with --table-def", relFile)
+		}
+		return []target{{rel: rel, path: relFile, encoding: textEncoding(db)}}, nil
+	}
+
+	db := dict.Database(dbname)
+	if db == nil {
+		return nil, fmt.Errorf("database %s is not in the metadata dictionary", dbname)
+	}
+	if table != "" {
+		schema, name := splitName(table)
+		rel := db.Relation(schema, name)
+		if rel == nil {
+			return nil, fmt.Errorf("table %s is not in database %s", table, dbname)
+		}
+		// A file whose mapping was lost, read with a known table's definition
+		if relFile != "" {
+			fmt.Printf("Table definition: %s (%d columns) from the dictionary\n", rel.QualifiedName(), len(rel.Columns))
+			return []target{{rel: rel, path: relFile, encoding: textEncoding(db)}}, nil
+		}
+		return []target{{rel: rel, path: db.RelationPath(c, rel), encoding: textEncoding(db)}}, nil
+	}
+
+	var targets []target
+	for _, rel := range db.UserTables() {
+		if rel.Kind != catalog.RELKIND_TOASTVALUE {
+			targets = append(targets, target{rel: rel, path: db.RelationPath(c, rel), encoding: textEncoding(db)})
+		}
+	}
+	fmt.Printf("Tables: %d\n", len(targets))
+	return targets, nil
+}
+
+// textEncoding returns the encoding of the text in a database's files: the
+// one given with --encoding, or else the database's, or UTF8 if the database
+// is not known.
+func textEncoding(db *catalog.Database) pgtypes.Encoding {
+	if name := viper.GetString("encoding"); name != "" {
+		enc, _ := pgtypes.ParseEncoding(name) // checked by unload
+		return enc
+	}
+	if db == nil {
+		return pgtypes.PG_UTF8
+	}
+	return pgtypes.Encoding(db.Encoding)
+}
+
+// chooseDefinition picks the definition of the table named by --table, or
+// the only one if there is just one.
+func chooseDefinition(relations []*catalog.Relation, table string) (*catalog.Relation, error) {
+	if table == "" {
+		if len(relations) > 1 {
+			return nil, fmt.Errorf("%d tables defined; choose one with --table", len(relations))
+		}
+		return relations[0], nil
+	}
+	schema, name := splitName(table)
+	for _, rel := range relations {
+		if rel.Name == name && (schema == "" || rel.Schema == schema) {
+			return rel, nil
+		}
+	}
+	return nil, fmt.Errorf("table %s is not defined", table)
+}
+
+// splitName splits a possibly schema-qualified table name.
+func splitName(table string) (string, string) {
+	if dot := strings.LastIndexByte(table, '.'); dot > 0 {
+		return table[:dot], table[dot+1:]
+	}
+	return "", table
+}
+
+// unloader unloads tables with the same settings.
+type unloader struct {
+	ctx          context.Context
+	cluster      *cluster.Cluster
+	clog         *catalog.CommitLog
+	format       output.Format
+	outputDir    string
+	policy       pager.ErrorPolicy
+	maxErrors    int
+	verify       bool
+	dropped      bool // write dropped columns as raw hex
+	pipelineOpts pipeline.Options
+}
+
+// unloadRelation writes the current rows of a table to a file named after it
+// in the output directory. Values that cannot be converted to text are
+// written as the hex of their raw bytes, with a warning, and so are the
+// values of dropped columns if they are asked for.
+func (u *unloader) unloadRelation(t target) error {
+	reader := fileio.NewSegmentedFileReader(fileio.MAIN_FORKNUM, u.cluster.Params)
+	reader.SetIOOptions(u.cluster.IO)
+	processor := pager.NewPageProcessor(reader, pager.NewPageParser(u.cluster.Params))
+	processor.SetVerifyChecksums(u.verify)
+	processor.SetErrorPolicy(u.policy, u.maxErrors)
+	if err := processor.Open(t.path); err != nil {
+		return err
+	}
+	defer processor.Close()
+
+	// Dropped columns are decoded to be skipped, but only written on request
+	attrs := t.rel.Attributes()
+	var columns, dropped []string
+	for _, attr := range attrs {
+		switch {
+		case !attr.AttIsDropped:
+			columns = append(columns, attr.Name)
+		case u.dropped:
+			columns = append(columns, fmt.Sprintf("dropped_%d", attr.AttNum))
+			dropped = append(dropped, columns[len(columns)-1])
+		}
+	}
+
+	name := t.rel.QualifiedName()
+	path := filepath.Join(u.outputDir, name+"."+u.format.Extension())
+	w, err := output.Create(path, u.format, t.rel.Schema, t.rel.Name, columns)
+	if err != nil {
+		return err
+	}
+
+	order := u.cluster.Params.ByteOrder
+	deformer := decoder.NewDeformer(attrs, order)
+	unconverted := make(map[string]error) // first error per column
+	counts := make(map[string]int)
+	err = pipeline.Run(u.ctx, processor, deformer, u.pipelineOpts, func(row *pipeline.Row) error {
+		if !u.clog.Visible(row.Tuple.Header) {
+			return nil
+		}
+		values := make([]*string, 0, len(columns))
+		for i := range row.Datums {
+			datum := &row.Datums[i]
+			if datum.Attr.AttIsDropped && !u.dropped {
+				continue
+			}
+			if datum.IsNull {
+				values = append(values, nil)
+				continue
+			}
+			if datum.Attr.AttIsDropped {
+				value := `\x` + hex.EncodeToString(decoder.Raw(datum, order))
+				values = append(values, &value)
+				continue
+			}
+			value, err := decoder.Output(datum, order, t.encoding)
+			if err != nil {
+				value = `\x` + hex.EncodeToString(datum.Data)
+				if counts[datum.Attr.Name] == 0 {
+					unconverted[datum.Attr.Name] = err
+				}
+				counts[datum.Attr.Name]++
+			}
+			values = append(values, &value)
+		}
+		return w.WriteRow(values)
+	})
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d rows written to %s\n", name, w.Rows(), path)
+	switch t.encoding {
+	case pgtypes.PG_UTF8:
+	case pgtypes.PG_SQL_ASCII:
+		fmt.Printf("%s: text written as stored (SQL_ASCII)\n", name)
+	default:
+		fmt.Printf("%s: text converted from %s to UTF8\n", name, t.encoding)
+	}
+	if len(dropped) > 0 {
+		fmt.Printf("%s: dropped columns written as raw hex: %s\n", name, strings.Join(dropped, ", "))
+	}
+	for _, column := range columns {
+		if counts[column] > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s: column %s: %d values written as raw hex: %v\n",
+				name, column, counts[column], unconverted[column])
+		}
+	}
+	if damage := processor.Damage(); damage.Damaged() {
+		damage.Print(os.Stdout)
+	}
+	return nil
+}
+
+// verifyRelationChecksums verifies the checksums of every relation and
+// prints the failing blocks per relation.
+func verifyRelationChecksums(c *cluster.Cluster) error {
+	relations, err := c.FindRelations()
+	if err != nil {
+		return err
+	}
+
+	failures := 0
+	progress := c.NewProgress(len(relations))
+	for i, path := range relations {
+		progress.Update(i)
+		reports, problems, err := c.VerifyRelationChecksums(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s\n", problem)
+		}
+		for _, report := range reports {
+			if len(report.Failures) > 0 || len(report.Unreadable) > 0 {
+				report.Print(os.Stdout)
+			}
+			failures += len(report.Failures)
+		}
+	}
+
+	fmt.Printf("Checksum failures: %d (%d relations checked)\n", failures, len(relations))
+	fmt.Printf("I/O: %s\n", c.IO.Stats.Snapshot())
+	fmt.Printf("Read rate: %s\n", c.IO.Throttle.Summary())
+	return nil
+}
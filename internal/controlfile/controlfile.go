@@ -0,0 +1,461 @@
+// Package controlfile reads the PostgreSQL control file (global/pg_control).
+package controlfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"path/filepath"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// PG_CONTROL_FILE_SIZE is the size of global/pg_control on disk. Only the
+// first part of the file holds ControlFileData; the rest is zero padding.
+const PG_CONTROL_FILE_SIZE = 8192
+
+// pg_control_version values at which the layout of ControlFileData changed
+const (
+	PG_CONTROL_VERSION_96 = 960  // PostgreSQL 9.6
+	PG_CONTROL_VERSION_10 = 1002 // PostgreSQL 10: no enableIntTimes, mock authentication nonce added
+	PG_CONTROL_VERSION_11 = 1100 // PostgreSQL 11: prevCheckPoint removed
+	PG_CONTROL_VERSION_12 = 1201 // PostgreSQL 12: 64-bit nextXid, max_wal_senders added
+	PG_CONTROL_VERSION_13 = 1300 // PostgreSQL 13: float4ByVal removed
+	PG_CONTROL_VERSION_18 = 1800 // PostgreSQL 18: default_char_signedness added
+)
+
+// MOCK_AUTH_NONCE_LEN is the length of the mock authentication nonce.
+const MOCK_AUTH_NONCE_LEN = 32
+
+// FLOATFORMAT_VALUE is stored in pg_control to check the float format.
+const FLOATFORMAT_VALUE = 1234567.0
+
+// DBState is the state of the cluster recorded in pg_control.
+type DBState uint32
+
+// Cluster states
+const (
+	DB_STARTUP DBState = iota
+	DB_SHUTDOWNED
+	DB_SHUTDOWNED_IN_RECOVERY
+	DB_SHUTDOWNING
+	DB_IN_CRASH_RECOVERY
+	DB_IN_ARCHIVE_RECOVERY
+	DB_IN_PRODUCTION
+)
+
+// dbStateNames are the descriptions pg_controldata prints for each state.
+var dbStateNames = []string{
+	"starting up",
+	"shut down",
+	"shut down in recovery",
+	"shutting down",
+	"in crash recovery",
+	"in archive recovery",
+	"in production",
+}
+
+// String returns the description pg_controldata uses for the state.
+func (s DBState) String() string {
+	if int(s) < len(dbStateNames) {
+		return dbStateNames[s]
+	}
+	return fmt.Sprintf("unrecognized status code %d", uint32(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s DBState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// WalLevel is the wal_level setting recorded in pg_control.
+type WalLevel uint32
+
+// WAL levels
+const (
+	WAL_LEVEL_MINIMAL WalLevel = iota
+	WAL_LEVEL_REPLICA
+	WAL_LEVEL_LOGICAL
+)
+
+// String returns the wal_level setting name.
+func (l WalLevel) String() string {
+	switch l {
+	case WAL_LEVEL_MINIMAL:
+		return "minimal"
+	case WAL_LEVEL_REPLICA:
+		return "replica"
+	case WAL_LEVEL_LOGICAL:
+		return "logical"
+	default:
+		return fmt.Sprintf("unrecognized wal_level %d", uint32(l))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l WalLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// CheckPoint holds the contents of the latest checkpoint record.
+type CheckPoint struct {
+	Redo              pgtypes.XLogRecPtr `json:"redo"`                 // next RecPtr available when we began to create CheckPoint
+	ThisTimeLineID    uint32             `json:"timeline_id"`          // current TLI
+	PrevTimeLineID    uint32             `json:"prev_timeline_id"`     // previous TLI, if this record begins a new timeline
+	FullPageWrites    bool               `json:"full_page_writes"`     // current full_page_writes
+	NextXid           uint64             `json:"next_xid"`             // next free transaction ID, with epoch in the high 32 bits
+	NextOid           uint32             `json:"next_oid"`             // next free OID
+	NextMulti         uint32             `json:"next_multi"`           // next free MultiXactId
+	NextMultiOffset   uint32             `json:"next_multi_offset"`    // next free MultiXact offset
+	OldestXid         uint32             `json:"oldest_xid"`           // cluster-wide minimum datfrozenxid
+	OldestXidDB       uint32             `json:"oldest_xid_db"`        // database with minimum datfrozenxid
+	OldestMulti       uint32             `json:"oldest_multi"`         // cluster-wide minimum datminmxid
+	OldestMultiDB     uint32             `json:"oldest_multi_db"`      // database with minimum datminmxid
+	Time              int64              `json:"time"`                 // time stamp of checkpoint
+	OldestCommitTsXid uint32             `json:"oldest_commit_ts_xid"` // oldest Xid with valid commit timestamp
+	NewestCommitTsXid uint32             `json:"newest_commit_ts_xid"` // newest Xid with valid commit timestamp
+	OldestActiveXid   uint32             `json:"oldest_active_xid"`    // oldest XID still running
+}
+
+// ControlFileData holds the decoded contents of pg_control.
+type ControlFileData struct {
+	SystemIdentifier uint64 `json:"system_identifier"`  // unique system identifier
+	PgControlVersion uint32 `json:"pg_control_version"` // PG_CONTROL_VERSION
+	CatalogVersionNo uint32 `json:"catalog_version_no"` // see catversion.h
+
+	State          DBState            `json:"state"`                     // cluster state
+	Time           int64              `json:"time"`                      // time stamp of last pg_control update
+	CheckPoint     pgtypes.XLogRecPtr `json:"checkpoint"`                // last check point record ptr
+	PrevCheckPoint pgtypes.XLogRecPtr `json:"prev_checkpoint,omitempty"` // previous check point record ptr (before PostgreSQL 11)
+	CheckPointCopy CheckPoint         `json:"checkpoint_copy"`           // copy of last check point record
+
+	UnloggedLSN         pgtypes.XLogRecPtr `json:"unlogged_lsn"`           // current fake LSN value, for unlogged rels
+	MinRecoveryPoint    pgtypes.XLogRecPtr `json:"min_recovery_point"`     // minimum recovery point
+	MinRecoveryPointTLI uint32             `json:"min_recovery_point_tli"` // timeline of the minimum recovery point
+	BackupStartPoint    pgtypes.XLogRecPtr `json:"backup_start_point"`     // start of an in-progress base backup
+	BackupEndPoint      pgtypes.XLogRecPtr `json:"backup_end_point"`       // end of a base backup being restored
+	BackupEndRequired   bool               `json:"backup_end_required"`    // end-of-backup record required
+
+	WalLevel             WalLevel `json:"wal_level"`              // wal_level setting
+	WalLogHints          bool     `json:"wal_log_hints"`          // wal_log_hints setting
+	MaxConnections       uint32   `json:"max_connections"`        // max_connections setting
+	MaxWorkerProcesses   uint32   `json:"max_worker_processes"`   // max_worker_processes setting
+	MaxWalSenders        uint32   `json:"max_wal_senders"`        // max_wal_senders setting (PostgreSQL 12 and later)
+	MaxPreparedXacts     uint32   `json:"max_prepared_xacts"`     // max_prepared_transactions setting
+	MaxLocksPerXact      uint32   `json:"max_locks_per_xact"`     // max_locks_per_transaction setting
+	TrackCommitTimestamp bool     `json:"track_commit_timestamp"` // track_commit_timestamp setting
+
+	MaxAlign          uint32  `json:"max_align"`            // alignment requirement for tuples
+	FloatFormat       float64 `json:"float_format"`         // constant 1234567.0 to check float format
+	BlckSz            uint32  `json:"blcksz"`               // data block size for this DB
+	RelSegSize        uint32  `json:"relseg_size"`          // blocks per segment of large relation
+	XLogBlcksz        uint32  `json:"xlog_blcksz"`          // block size within WAL files
+	XLogSegSize       uint32  `json:"xlog_seg_size"`        // size of each WAL segment
+	NameDataLen       uint32  `json:"name_data_len"`        // catalog name field width
+	IndexMaxKeys      uint32  `json:"index_max_keys"`       // max number of columns in an index
+	ToastMaxChunkSize uint32  `json:"toast_max_chunk_size"` // chunk size in TOAST tables
+	LoBlkSize         uint32  `json:"lo_blk_size"`          // chunk size in pg_largeobject
+
+	EnableIntTimes        bool   `json:"enable_int_times"`                  // int64 storage for date/time (always true since PostgreSQL 10)
+	Float4ByVal           bool   `json:"float4_by_val"`                     // float4 pass-by-value (always true since PostgreSQL 13)
+	Float8ByVal           bool   `json:"float8_by_val"`                     // float8, int8, etc pass-by-value
+	DataChecksumVersion   uint32 `json:"data_checksum_version"`             // zero if data pages are not checksummed
+	DefaultCharSignedness bool   `json:"default_char_signedness,omitempty"` // char is signed on the build platform (PostgreSQL 18 and later)
+
+	MockAuthenticationNonce [MOCK_AUTH_NONCE_LEN]byte `json:"-"` // random nonce used in SASL authentication
+
+	CRC      uint32 `json:"crc"`       // CRC-32C stored in the file
+	CRCValid bool   `json:"crc_valid"` // stored CRC matches the contents
+
+	// Layout the file was decoded with
+	ByteOrder binary.ByteOrder `json:"-"`
+	Align8    int              `json:"align8"` // alignment of 8-byte fields (8, or 4 on some 32-bit platforms)
+}
+
+// StorageParams returns the block and segment sizes recorded in pg_control,
+// with the version profile matching its catalog version.
+func (c *ControlFileData) StorageParams() pgtypes.StorageParams {
+	return pgtypes.StorageParams{
+		ByteOrder:     c.ByteOrder,
+		BlockSize:     int(c.BlckSz),
+		RelSegSize:    int(c.RelSegSize),
+		XLogBlockSize: int(c.XLogBlcksz),
+		WalSegSize:    int(c.XLogSegSize),
+		Profile:       c.Profile(),
+		DataChecksums: c.DataChecksumsEnabled(),
+		MaxAlign:      int(c.MaxAlign),
+	}
+}
+
+// Profile returns the version profile matching the catalog version, or the
+// default profile if the catalog version is not recognized.
+func (c *ControlFileData) Profile() *pgtypes.Profile {
+	profile, err := pgtypes.ProfileForCatalogVersion(c.CatalogVersionNo)
+	if err != nil {
+		return pgtypes.DefaultProfile()
+	}
+	return profile
+}
+
+// FloatFormatValid checks if the float format constant was read back correctly.
+func (c *ControlFileData) FloatFormatValid() bool {
+	return c.FloatFormat == FLOATFORMAT_VALUE
+}
+
+// DataChecksumsEnabled checks if the cluster was initialized with data checksums.
+func (c *ControlFileData) DataChecksumsEnabled() bool {
+	return c.DataChecksumVersion != 0
+}
+
+// Path returns the location of pg_control within a data directory.
+func Path(pgData string) string {
+	return filepath.Join(pgData, "global", "pg_control")
+}
+
+// ReadControlFile reads and decodes global/pg_control from a data directory.
+func ReadControlFile(pgData string) (*ControlFileData, error) {
+	data, err := fileio.ReadFile(Path(pgData))
+	if err != nil {
+		return nil, err
+	}
+	return ParseControlFile(data)
+}
+
+// ParseControlFile decodes the contents of a pg_control file. The byte order
+// is detected from pg_control_version. The alignment of 8-byte fields is
+// chosen so that the CRC matches or, failing that, so that the storage
+// parameters come out as valid values; in the latter case CRCValid is false
+// and the caller decides whether to trust the contents.
+func ParseControlFile(data []byte) (*ControlFileData, error) {
+	if len(data) < 16 {
+		return nil, fmt.Errorf("pg_control too short: %d bytes", len(data))
+	}
+
+	// Detect byte order
+	order, err := detectByteOrder(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try 8-byte alignment first, then the 4-byte alignment of 32-bit x86
+	var fallback *ControlFileData
+	var lastErr error
+	for _, align8 := range []int{8, 4} {
+		control, err := decode(data, order, align8)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if control.CRCValid {
+			return control, nil
+		}
+		if err := control.StorageParams().Validate(); err != nil {
+			lastErr = err
+			continue
+		}
+		if fallback == nil {
+			fallback = control
+		}
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+
+	return nil, fmt.Errorf("cannot decode pg_control: %v", lastErr)
+}
+
+// detectByteOrder guesses the byte order of pg_control from its version
+// field, which is a small number in the writer's native order.
+func detectByteOrder(data []byte) (binary.ByteOrder, error) {
+	little := binary.LittleEndian.Uint32(data[8:12])
+	big := binary.BigEndian.Uint32(data[8:12])
+
+	switch {
+	case little >= PG_CONTROL_VERSION_96 && little < 100000:
+		return binary.LittleEndian, nil
+	case big >= PG_CONTROL_VERSION_96 && big < 100000:
+		return binary.BigEndian, nil
+	default:
+		return nil, fmt.Errorf("unsupported or corrupt pg_control_version %d", little)
+	}
+}
+
+// decode walks ControlFileData with the given byte order and alignment.
+func decode(data []byte, order binary.ByteOrder, align8 int) (*ControlFileData, error) {
+	c := &ControlFileData{ByteOrder: order, Align8: align8}
+	r := &layoutReader{data: data, order: order, align8: align8}
+
+	c.SystemIdentifier = r.uint64()
+	c.PgControlVersion = r.uint32()
+	c.CatalogVersionNo = r.uint32()
+	version := c.PgControlVersion
+
+	c.State = DBState(r.uint32())
+	c.Time = r.int64()
+	c.CheckPoint = pgtypes.XLogRecPtr(r.uint64())
+	if version < PG_CONTROL_VERSION_11 {
+		c.PrevCheckPoint = pgtypes.XLogRecPtr(r.uint64())
+	}
+	c.CheckPointCopy = readCheckPoint(r, version)
+
+	c.UnloggedLSN = pgtypes.XLogRecPtr(r.uint64())
+	c.MinRecoveryPoint = pgtypes.XLogRecPtr(r.uint64())
+	c.MinRecoveryPointTLI = r.uint32()
+	c.BackupStartPoint = pgtypes.XLogRecPtr(r.uint64())
+	c.BackupEndPoint = pgtypes.XLogRecPtr(r.uint64())
+	c.BackupEndRequired = r.bool()
+
+	c.WalLevel = WalLevel(r.uint32())
+	c.WalLogHints = r.bool()
+	c.MaxConnections = r.uint32()
+	c.MaxWorkerProcesses = r.uint32()
+	if version >= PG_CONTROL_VERSION_12 {
+		c.MaxWalSenders = r.uint32()
+	}
+	c.MaxPreparedXacts = r.uint32()
+	c.MaxLocksPerXact = r.uint32()
+	c.TrackCommitTimestamp = r.bool()
+
+	c.MaxAlign = r.uint32()
+	c.FloatFormat = r.float64()
+	c.BlckSz = r.uint32()
+	c.RelSegSize = r.uint32()
+	c.XLogBlcksz = r.uint32()
+	c.XLogSegSize = r.uint32()
+	c.NameDataLen = r.uint32()
+	c.IndexMaxKeys = r.uint32()
+	c.ToastMaxChunkSize = r.uint32()
+	c.LoBlkSize = r.uint32()
+
+	c.EnableIntTimes = true
+	if version < PG_CONTROL_VERSION_10 {
+		c.EnableIntTimes = r.bool()
+	}
+	c.Float4ByVal = true
+	if version < PG_CONTROL_VERSION_13 {
+		c.Float4ByVal = r.bool()
+	}
+	c.Float8ByVal = r.bool()
+	c.DataChecksumVersion = r.uint32()
+	if version >= PG_CONTROL_VERSION_18 {
+		c.DefaultCharSignedness = r.bool()
+	}
+	if version >= PG_CONTROL_VERSION_10 {
+		copy(c.MockAuthenticationNonce[:], r.take(MOCK_AUTH_NONCE_LEN))
+	}
+
+	// The CRC covers everything before it
+	r.alignTo(4)
+	crcOffset := r.offset
+	c.CRC = r.uint32()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	c.CRCValid = crc32.Checksum(data[:crcOffset], crc32cTable) == c.CRC
+
+	return c, nil
+}
+
+// readCheckPoint reads the CheckPoint struct embedded in ControlFileData.
+func readCheckPoint(r *layoutReader, version uint32) CheckPoint {
+	var cp CheckPoint
+
+	r.alignTo(r.align8)
+	cp.Redo = pgtypes.XLogRecPtr(r.uint64())
+	cp.ThisTimeLineID = r.uint32()
+	cp.PrevTimeLineID = r.uint32()
+	cp.FullPageWrites = r.bool()
+	if version >= PG_CONTROL_VERSION_12 {
+		cp.NextXid = r.uint64()
+	} else {
+		epoch := r.uint32()
+		cp.NextXid = uint64(epoch)<<32 | uint64(r.uint32())
+	}
+	cp.NextOid = r.uint32()
+	cp.NextMulti = r.uint32()
+	cp.NextMultiOffset = r.uint32()
+	cp.OldestXid = r.uint32()
+	cp.OldestXidDB = r.uint32()
+	cp.OldestMulti = r.uint32()
+	cp.OldestMultiDB = r.uint32()
+	cp.Time = r.int64()
+	cp.OldestCommitTsXid = r.uint32()
+	cp.NewestCommitTsXid = r.uint32()
+	cp.OldestActiveXid = r.uint32()
+
+	// The struct size is padded to its alignment
+	r.alignTo(r.align8)
+
+	return cp
+}
+
+// crc32cTable is the CRC-32C (Castagnoli) table PostgreSQL's pg_crc32c uses.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// layoutReader reads C struct fields sequentially, applying the natural
+// alignment of each field type.
+type layoutReader struct {
+	data   []byte
+	order  binary.ByteOrder
+	align8 int // alignment of 8-byte fields
+	offset int
+	err    error
+}
+
+// alignTo advances the offset to a multiple of n.
+func (r *layoutReader) alignTo(n int) {
+	r.offset = (r.offset + n - 1) &^ (n - 1)
+}
+
+// take returns the next n bytes, or nil once the data is exhausted.
+func (r *layoutReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.offset+n > len(r.data) {
+		r.err = fmt.Errorf("pg_control truncated at offset %d", r.offset)
+		return nil
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b
+}
+
+// bool reads a one-byte C bool.
+func (r *layoutReader) bool() bool {
+	b := r.take(1)
+	return b != nil && b[0] != 0
+}
+
+// uint32 reads a 4-byte aligned 32-bit integer.
+func (r *layoutReader) uint32() uint32 {
+	r.alignTo(4)
+	b := r.take(4)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint32(b)
+}
+
+// uint64 reads an 8-byte integer aligned to align8.
+func (r *layoutReader) uint64() uint64 {
+	r.alignTo(r.align8)
+	b := r.take(8)
+	if b == nil {
+		return 0
+	}
+	return r.order.Uint64(b)
+}
+
+// int64 reads a signed 8-byte integer aligned to align8.
+func (r *layoutReader) int64() int64 {
+	return int64(r.uint64())
+}
+
+// float64 reads an 8-byte double aligned to align8.
+func (r *layoutReader) float64() float64 {
+	return math.Float64frombits(r.uint64())
+}
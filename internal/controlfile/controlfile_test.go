@@ -0,0 +1,142 @@
+package controlfile
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// testControlFile returns a pg_control of PostgreSQL 15 written in order on
+// a platform with 8-byte alignment, at the offsets of its ControlFileData.
+func testControlFile(order binary.ByteOrder) []byte {
+	data := make([]byte, PG_CONTROL_FILE_SIZE)
+	put32 := func(offset int, v uint32) { order.PutUint32(data[offset:], v) }
+	put64 := func(offset int, v uint64) { order.PutUint64(data[offset:], v) }
+
+	put64(0, 7123456789012345678) // system_identifier
+	put32(8, 1300)                // pg_control_version
+	put32(12, 202209061)          // catalog_version_no
+	put32(16, uint32(DB_SHUTDOWNED))
+	put64(24, 1700000000) // time
+	put64(32, 0x01000028) // checkPoint
+
+	// checkPointCopy
+	put64(40, 0x01000028) // redo
+	put32(48, 1)          // ThisTimeLineID
+	put32(52, 1)          // PrevTimeLineID
+	data[56] = 1          // fullPageWrites
+	put64(64, 2<<32|745)  // nextXid
+	put32(72, 16390)      // nextOid
+	put32(76, 1)          // nextMulti
+	put32(84, 716)        // oldestXid
+	put32(88, 1)          // oldestXidDB
+	put32(92, 1)          // oldestMulti
+	put32(96, 1)          // oldestMultiDB
+	put64(104, 1700000000)
+	put32(120, 745) // oldestActiveXid
+
+	put32(172, 1)   // wal_level replica
+	put32(180, 100) // MaxConnections
+	put32(184, 8)   // max_worker_processes
+	put32(188, 10)  // max_wal_senders
+	put32(196, 64)  // max_locks_per_xact
+	put32(204, 8)   // maxAlign
+	put64(208, math.Float64bits(FLOATFORMAT_VALUE))
+	put32(216, 8192)   // blcksz
+	put32(220, 131072) // relseg_size
+	put32(224, 8192)   // xlog_blcksz
+	put32(228, 16<<20) // xlog_seg_size
+	put32(232, 64)     // nameDataLen
+	put32(236, 32)     // indexMaxKeys
+	put32(240, 1996)   // toast_max_chunk_size
+	put32(244, 2048)   // loblksize
+	data[248] = 1      // float8ByVal
+	put32(252, 1)      // data_checksum_version
+	data[256] = 0xAA   // mock_authentication_nonce
+	put32(288, crc32.Checksum(data[:288], crc32.MakeTable(crc32.Castagnoli)))
+	return data
+}
+
+func TestParseControlFile(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		c, err := ParseControlFile(testControlFile(order))
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		if !c.CRCValid || c.ByteOrder != order || c.Align8 != 8 {
+			t.Errorf("%s: CRC valid %v, byte order %s, 8-byte alignment %d", order, c.CRCValid, c.ByteOrder, c.Align8)
+		}
+
+		checks := []struct {
+			name      string
+			got, want interface{}
+		}{
+			{"system_identifier", c.SystemIdentifier, uint64(7123456789012345678)},
+			{"catalog_version_no", c.CatalogVersionNo, uint32(202209061)},
+			{"state", c.State, DB_SHUTDOWNED},
+			{"checkPoint", c.CheckPoint, pgtypes.XLogRecPtr(0x01000028)},
+			{"nextXid", c.CheckPointCopy.NextXid, uint64(2<<32 | 745)},
+			{"nextOid", c.CheckPointCopy.NextOid, uint32(16390)},
+			{"oldestActiveXid", c.CheckPointCopy.OldestActiveXid, uint32(745)},
+			{"wal_level", c.WalLevel, WalLevel(1)},
+			{"max_wal_senders", c.MaxWalSenders, uint32(10)},
+			{"max_locks_per_xact", c.MaxLocksPerXact, uint32(64)},
+			{"floatFormat", c.FloatFormatValid(), true},
+			{"relseg_size", c.RelSegSize, uint32(131072)},
+			{"toast_max_chunk_size", c.ToastMaxChunkSize, uint32(1996)},
+			{"float4ByVal", c.Float4ByVal, true},
+			{"float8ByVal", c.Float8ByVal, true},
+			{"data_checksum_version", c.DataChecksumsEnabled(), true},
+			{"mock_authentication_nonce", c.MockAuthenticationNonce[0], byte(0xAA)},
+		}
+		for _, check := range checks {
+			if check.got != check.want {
+				t.Errorf("%s: %s is %v, want %v", order, check.name, check.got, check.want)
+			}
+		}
+
+		params := c.StorageParams()
+		if err := params.Validate(); err != nil || params.Profile.Name != "15" {
+			t.Errorf("%s: storage parameters %+v: %v", order, params, err)
+		}
+	}
+}
+
+func TestParseControlFileCRC(t *testing.T) {
+	// A damaged pg_control still decodes, but the CRC does not match
+	data := testControlFile(binary.LittleEndian)
+	data[72]++
+	c, err := ParseControlFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.CRCValid || c.CheckPointCopy.NextOid != 16391 || c.BlckSz != 8192 {
+		t.Errorf("CRC valid %v, nextOid %d, blcksz %d", c.CRCValid, c.CheckPointCopy.NextOid, c.BlckSz)
+	}
+
+	// The padding after ControlFileData is not covered
+	data = testControlFile(binary.LittleEndian)
+	data[300] = 0xFF
+	if c, err := ParseControlFile(data); err != nil || !c.CRCValid {
+		t.Errorf("damaged padding: CRC valid %v (%v)", c != nil && c.CRCValid, err)
+	}
+}
+
+func TestParseControlFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", make([]byte, 12)},
+		{"unknown version", make([]byte, PG_CONTROL_FILE_SIZE)},
+		{"truncated", testControlFile(binary.LittleEndian)[:200]},
+	}
+	for _, test := range tests {
+		if _, err := ParseControlFile(test.data); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Array header layout (ArrayType): vl_len_, ndim, dataoffset and elemtype,
+// followed by the dimensions and lower bounds, an optional null bitmap and
+// the elements
+const (
+	ARR_HEADER_SIZE = 16 // sizeof(ArrayType), including the 4-byte varlena header
+	MAXDIM          = 6  // maximum number of array dimensions
+)
+
+// Array is a decoded array value.
+type Array struct {
+	ElemType uint32  // element type OID
+	Dims     []int   // length of each dimension
+	Elements []Datum // elements in storage order, with Attr set to the element descriptor
+}
+
+// ArrayElements decodes an array value stored inline as is, with elements
+// described by elem. Offsets within the array are relative to its 4-byte
+// varlena header, which PostgreSQL gives every array once detoasted, even
+// when the tuple stores it with a short header. Elements are aligned
+// without the short varlena exception of tuples, as arrays never pack them.
+func ArrayElements(data []byte, elem *Attribute, order binary.ByteOrder) (*Array, error) {
+	if order == nil {
+		order = pgtypes.DefaultByteOrder
+	}
+	payload, err := VarData(data, order)
+	if err != nil {
+		return nil, err
+	}
+	// Index into the array as if its header were 4 bytes
+	array := make([]byte, VARHDRSZ+len(payload))
+	copy(array[VARHDRSZ:], payload)
+	if len(array) < ARR_HEADER_SIZE {
+		return nil, fmt.Errorf("array of %d bytes is shorter than its header", len(array))
+	}
+
+	ndim := int32(order.Uint32(array[4:]))
+	dataOffset := int(int32(order.Uint32(array[8:])))
+	a := &Array{ElemType: order.Uint32(array[12:])}
+	if ndim < 0 || ndim > MAXDIM {
+		return nil, fmt.Errorf("invalid number of array dimensions %d", ndim)
+	}
+	bounds := ARR_HEADER_SIZE + 8*int(ndim)
+	if len(array) < bounds {
+		return nil, fmt.Errorf("array dimensions beyond its %d bytes", len(array))
+	}
+	nitems := 0
+	if ndim > 0 {
+		nitems = 1
+	}
+	for i := 0; i < int(ndim); i++ {
+		dim := int(int32(order.Uint32(array[ARR_HEADER_SIZE+4*i:])))
+		if dim < 0 || dim > len(array) {
+			return nil, fmt.Errorf("invalid array dimension %d", dim)
+		}
+		a.Dims = append(a.Dims, dim)
+		nitems *= dim
+	}
+	if nitems > 8*len(array) {
+		return nil, fmt.Errorf("array of %d elements cannot fit in %d bytes", nitems, len(array))
+	}
+
+	// Elements follow the bounds, MAXALIGNed, or the null bitmap if
+	// dataoffset is set
+	var nulls []byte
+	offset := AlignOffset(bounds, TYPALIGN_DOUBLE)
+	if dataOffset != 0 {
+		if dataOffset < bounds+(nitems+7)/8 || dataOffset > len(array) {
+			return nil, fmt.Errorf("invalid array data offset %d", dataOffset)
+		}
+		nulls = array[bounds : bounds+(nitems+7)/8]
+		offset = dataOffset
+	}
+
+	d := &Deformer{order: order}
+	a.Elements = make([]Datum, nitems)
+	for i := range a.Elements {
+		a.Elements[i].Attr = elem
+		if nulls != nil && nulls[i/8]&(1<<(i%8)) == 0 {
+			a.Elements[i].IsNull = true
+			continue
+		}
+		offset = AlignOffset(offset, elem.AttAlign)
+		if offset > len(array) {
+			return nil, fmt.Errorf("element %d at offset %d beyond the array (%d bytes)", i+1, offset, len(array))
+		}
+		length, err := d.attrLength(elem, array, offset)
+		if err != nil {
+			return nil, fmt.Errorf("element %d at offset %d: %v", i+1, offset, err)
+		}
+		if offset+length > len(array) {
+			return nil, fmt.Errorf("element %d at offset %d: length %d exceeds the array (%d bytes)", i+1, offset, length, len(array))
+		}
+		a.Elements[i].Data = array[offset : offset+length]
+		a.Elements[i].Offset = offset
+		offset += length
+	}
+	return a, nil
+}
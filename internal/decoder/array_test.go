@@ -0,0 +1,91 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+var int4Attribute = Attribute{Name: "elem", TypeOid: pgtypes.INT4OID, AttLen: 4, AttAlign: TYPALIGN_INT, AttByVal: true}
+
+// int4Array returns the array '{1,NULL,3}' of int4 with a 4-byte header:
+// header, dimension and lower bound, null bitmap, padding and elements.
+func int4Array(order binary.ByteOrder) []byte {
+	var data []byte
+	for _, v := range []uint32{40, 1, 32, pgtypes.INT4OID, 3, 1} {
+		data = appendUint32(data, v, order)
+	}
+	if order == binary.LittleEndian {
+		order.PutUint32(data, 40<<2)
+	}
+	data = append(data, 0x05, 0, 0, 0, 0, 0, 0, 0)
+	data = appendUint32(data, 1, order)
+	return appendUint32(data, 3, order)
+}
+
+// appendUint32 appends v to data in order.
+func appendUint32(data []byte, v uint32, order binary.ByteOrder) []byte {
+	b := make([]byte, 4)
+	order.PutUint32(b, v)
+	return append(data, b...)
+}
+
+func TestArrayElements(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		array, err := ArrayElements(int4Array(order), &int4Attribute, order)
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		if array.ElemType != pgtypes.INT4OID || len(array.Dims) != 1 || array.Dims[0] != 3 || len(array.Elements) != 3 {
+			t.Fatalf("%s: array %+v", order, array)
+		}
+		if e := array.Elements[0]; e.IsNull || e.Offset != 32 || order.Uint32(e.Data) != 1 {
+			t.Errorf("%s: element 1 at offset %d: % x", order, e.Offset, e.Data)
+		}
+		if e := array.Elements[1]; !e.IsNull || e.Data != nil {
+			t.Errorf("%s: element 2 is not null: % x", order, e.Data)
+		}
+		if e := array.Elements[2]; e.IsNull || e.Offset != 36 || order.Uint32(e.Data) != 3 || e.Attr != &int4Attribute {
+			t.Errorf("%s: element 3 at offset %d: % x", order, e.Offset, e.Data)
+		}
+	}
+}
+
+func TestArrayElementsShortHeader(t *testing.T) {
+	// attmissingval '{42}' as a tuple stores it, with a 1-byte header
+	order := binary.LittleEndian
+	data := []byte{25<<1 | 1}
+	for _, v := range []uint32{1, 0, pgtypes.INT4OID, 1, 1, 42} {
+		data = appendUint32(data, v, order)
+	}
+	array, err := ArrayElements(data, &int4Attribute, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(array.Elements) != 1 || array.Elements[0].Offset != 24 || order.Uint32(array.Elements[0].Data) != 42 {
+		t.Errorf("array %+v", array)
+	}
+}
+
+func TestArrayElementsCorrupt(t *testing.T) {
+	order := binary.LittleEndian
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"shorter than header", func(data []byte) []byte { return []byte{7<<1 | 1, 1, 2, 3, 4, 5, 6} }},
+		{"too many dimensions", func(data []byte) []byte { order.PutUint32(data[4:], 7); return data }},
+		{"invalid data offset", func(data []byte) []byte { order.PutUint32(data[8:], 20); return data }},
+		{"too many elements", func(data []byte) []byte { order.PutUint32(data[16:], 1000); return data }},
+		{"element beyond array", func(data []byte) []byte {
+			order.PutUint32(data, 38<<2)
+			return data[:38]
+		}},
+	}
+	for _, test := range tests {
+		if array, err := ArrayElements(test.modify(int4Array(order)), &int4Attribute, order); err == nil {
+			t.Errorf("%s: no error, %+v", test.name, array)
+		}
+	}
+}
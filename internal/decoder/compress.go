@@ -0,0 +1,129 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCorruptCompressed is returned for compressed data that does not
+// decompress to its recorded size.
+var ErrCorruptCompressed = errors.New("compressed data is corrupt")
+
+// decompress decompresses the data of a compressed varlena, after its
+// va_tcinfo, to rawSize bytes.
+func decompress(method int, src []byte, rawSize int) ([]byte, error) {
+	switch method {
+	case TOAST_PGLZ_COMPRESSION_ID:
+		return pglzDecompress(src, rawSize)
+	case TOAST_LZ4_COMPRESSION_ID:
+		return lz4Decompress(src, rawSize)
+	}
+	return nil, fmt.Errorf("invalid compression method %d", method)
+}
+
+// pglzDecompress decompresses pglz data, as pglz_decompress does with
+// check_complete. Each control byte tells, from its lowest bit up, whether
+// the next 8 items are literal bytes or back-references: 2 bytes of 12-bit
+// offset and 4-bit length minus 3, and a third byte extending a length of
+// 18.
+func pglzDecompress(src []byte, rawSize int) ([]byte, error) {
+	dst := make([]byte, 0, rawSize)
+	sp := 0
+	for sp < len(src) && len(dst) < rawSize {
+		ctrl := src[sp]
+		sp++
+		for bit := 0; bit < 8 && sp < len(src) && len(dst) < rawSize; bit++ {
+			if ctrl&1 == 0 {
+				dst = append(dst, src[sp])
+				sp++
+				ctrl >>= 1
+				continue
+			}
+
+			if sp+1 >= len(src) {
+				return nil, ErrCorruptCompressed
+			}
+			length := int(src[sp]&0x0F) + 3
+			offset := int(src[sp]&0xF0)<<4 | int(src[sp+1])
+			sp += 2
+			if length == 18 {
+				if sp >= len(src) {
+					return nil, ErrCorruptCompressed
+				}
+				length += int(src[sp])
+				sp++
+			}
+			if offset == 0 || offset > len(dst) {
+				return nil, ErrCorruptCompressed
+			}
+			if length > rawSize-len(dst) {
+				length = rawSize - len(dst)
+			}
+			// The source may overlap what is being written
+			for from := len(dst) - offset; length > 0; length-- {
+				dst = append(dst, dst[from])
+				from++
+			}
+			ctrl >>= 1
+		}
+	}
+	if sp != len(src) || len(dst) != rawSize {
+		return nil, ErrCorruptCompressed
+	}
+	return dst, nil
+}
+
+// lz4Decompress decompresses an LZ4 block, as LZ4_decompress_safe does.
+// Each sequence is a token of literal and match length nibbles, extended by
+// bytes of 255, the literals, and a 2-byte little-endian offset; the last
+// sequence has no match.
+func lz4Decompress(src []byte, rawSize int) ([]byte, error) {
+	dst := make([]byte, 0, rawSize)
+	sp := 0
+	length := func(n int) (int, bool) {
+		if n != 15 {
+			return n, true
+		}
+		for sp < len(src) {
+			b := src[sp]
+			sp++
+			n += int(b)
+			if b != 255 {
+				return n, true
+			}
+		}
+		return 0, false
+	}
+
+	for sp < len(src) {
+		token := src[sp]
+		sp++
+		literals, ok := length(int(token >> 4))
+		if !ok || literals > len(src)-sp || literals > rawSize-len(dst) {
+			return nil, ErrCorruptCompressed
+		}
+		dst = append(dst, src[sp:sp+literals]...)
+		sp += literals
+		if sp == len(src) {
+			break
+		}
+
+		if sp+1 >= len(src) {
+			return nil, ErrCorruptCompressed
+		}
+		offset := int(src[sp]) | int(src[sp+1])<<8
+		sp += 2
+		match, ok := length(int(token & 0x0F))
+		if !ok || offset == 0 || offset > len(dst) || match+4 > rawSize-len(dst) {
+			return nil, ErrCorruptCompressed
+		}
+		for from, n := len(dst)-offset, match+4; n > 0; n-- {
+			dst = append(dst, dst[from])
+			from++
+		}
+	}
+	if len(dst) != rawSize {
+		return nil, ErrCorruptCompressed
+	}
+	return dst, nil
+}
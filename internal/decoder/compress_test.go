@@ -0,0 +1,110 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// pglzLiterals returns pglz data that stores data as literals.
+func pglzLiterals(data []byte) []byte {
+	var src []byte
+	for i, b := range data {
+		if i%8 == 0 {
+			src = append(src, 0x00)
+		}
+		src = append(src, b)
+	}
+	return src
+}
+
+func TestPglzDecompress(t *testing.T) {
+	bytes256 := make([]byte, 256)
+	for i := range bytes256 {
+		bytes256[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		src  []byte
+		want []byte
+	}{
+		{"literals", []byte{0x00, 'a', 'b', 'c'}, []byte("abc")},
+		{"two control bytes", pglzLiterals([]byte("123456789")), []byte("123456789")},
+		{"match", []byte{0x08, 'a', 'b', 'c', 0x06, 0x03}, []byte("abcabcabcabc")},
+		{"overlapping match", []byte{0x02, 'a', 0x01, 0x01}, []byte("aaaaa")},
+		{"extended length", []byte{0x02, 'a', 0x0F, 0x01, 0x15}, bytes.Repeat([]byte("a"), 40)},
+		// 4 bytes from 256 back: the high bits of the offset share a byte
+		// with the length
+		{"12-bit offset", append(pglzLiterals(bytes256), 0x01, 0x11, 0x00), append(bytes256, 0, 1, 2, 3)},
+	}
+
+	for _, test := range tests {
+		got, err := pglzDecompress(test.src, len(test.want))
+		if err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("%s: % x (%v), want % x", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestPglzDecompressCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     []byte
+		rawSize int
+	}{
+		{"short output", []byte{0x00, 'a', 'b'}, 3},
+		{"trailing input", []byte{0x00, 'a', 'b', 'c', 'd'}, 3},
+		{"offset beyond output", []byte{0x02, 'a', 0x01, 0x02}, 5},
+		{"zero offset", []byte{0x02, 'a', 0x01, 0x00}, 5},
+		{"truncated match", []byte{0x02, 'a', 0x01}, 5},
+		{"truncated extended length", []byte{0x02, 'a', 0x0F, 0x01}, 40},
+	}
+	for _, test := range tests {
+		if got, err := pglzDecompress(test.src, test.rawSize); !errors.Is(err, ErrCorruptCompressed) {
+			t.Errorf("%s: % x (%v), want ErrCorruptCompressed", test.name, got, err)
+		}
+	}
+}
+
+func TestLz4Decompress(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+		want []byte
+	}{
+		{"literals", []byte{0x30, 'a', 'b', 'c'}, []byte("abc")},
+		{"match", []byte{0x35, 'a', 'b', 'c', 0x03, 0x00, 0x50, 'x', 'y', 'z', 'w', 'v'}, []byte("abcabcabcabcxyzwv")},
+		{"extended match length", []byte{0x1F, 'a', 0x01, 0x00, 0x14, 0x10, 'b'}, append(bytes.Repeat([]byte("a"), 40), 'b')},
+		{"extended literal length", append([]byte{0xF0, 0x01}, bytes.Repeat([]byte("z"), 16)...), bytes.Repeat([]byte("z"), 16)},
+		{"literal length of 15+255+0", append([]byte{0xF0, 0xFF, 0x00}, bytes.Repeat([]byte("z"), 270)...), bytes.Repeat([]byte("z"), 270)},
+	}
+	for _, test := range tests {
+		got, err := lz4Decompress(test.src, len(test.want))
+		if err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("%s: %q (%v), want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestLz4DecompressCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     []byte
+		rawSize int
+	}{
+		{"short output", []byte{0x30, 'a', 'b', 'c'}, 4},
+		{"literals beyond input", []byte{0x40, 'a', 'b', 'c'}, 4},
+		{"literals beyond output", []byte{0x30, 'a', 'b', 'c'}, 2},
+		{"truncated offset", []byte{0x15, 'a', 0x01}, 6},
+		{"zero offset", []byte{0x15, 'a', 0x00, 0x00}, 10},
+		{"offset beyond output", []byte{0x15, 'a', 0x02, 0x00}, 10},
+		{"match beyond output", []byte{0x15, 'a', 0x01, 0x00}, 5},
+		{"unterminated length", []byte{0xF0, 0xFF}, 300},
+	}
+	for _, test := range tests {
+		if got, err := lz4Decompress(test.src, test.rawSize); !errors.Is(err, ErrCorruptCompressed) {
+			t.Errorf("%s: %q (%v), want ErrCorruptCompressed", test.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package decoder decodes PostgreSQL heap tuples into column values.
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Attribute alignment codes (pg_attribute.attalign)
+const (
+	TYPALIGN_CHAR   = 'c' // char alignment (no alignment needed)
+	TYPALIGN_SHORT  = 's' // short alignment (typically 2 bytes)
+	TYPALIGN_INT    = 'i' // int alignment (typically 4 bytes)
+	TYPALIGN_DOUBLE = 'd' // double alignment (often 8 bytes)
+)
+
+// Attribute storage codes (pg_attribute.attstorage)
+const (
+	TYPSTORAGE_PLAIN    = 'p' // type not prepared for toasting
+	TYPSTORAGE_EXTERNAL = 'e' // toastable, don't try to compress
+	TYPSTORAGE_EXTENDED = 'x' // fully toastable
+	TYPSTORAGE_MAIN     = 'm' // like 'x' but try to store inline
+)
+
+// Attribute describes one column of a relation. It carries the subset of
+// pg_attribute that is needed to walk a tuple's user data.
+type Attribute struct {
+	Name         string // column name
+	AttNum       int    // column number, starting at 1
+	TypeOid      uint32 // data type OID, 0 for dropped columns
+	AttLen       int16  // fixed length, -1 for varlena, -2 for cstring
+	AttAlign     byte   // alignment code (TYPALIGN_*)
+	AttByVal     bool   // passed by value
+	AttStorage   byte   // storage strategy (TYPSTORAGE_*)
+	AttIsDropped bool   // column has been dropped
+
+	// Value of the column in tuples written before it was added, from
+	// attmissingval, in the form the tuple would store it; nil for null
+	Missing []byte
+}
+
+// String describes the column for messages, e.g. "column 2 (name)". Dropped
+// columns are described by number, as their name is a placeholder.
+func (a *Attribute) String() string {
+	if a.AttIsDropped {
+		return fmt.Sprintf("dropped column %d", a.AttNum)
+	}
+	return fmt.Sprintf("column %d (%s)", a.AttNum, a.Name)
+}
+
+// Datum is the raw value of one column of a tuple.
+type Datum struct {
+	// Column the value belongs to
+	Attr *Attribute
+
+	// Value is null, either from the null bitmap or because it is missing
+	IsNull bool
+
+	// Column did not exist when the tuple was written (natts is smaller
+	// than the descriptor); the value is the column's missing value
+	IsMissing bool
+
+	// Raw bytes of the value, including the varlena header if any
+	Data []byte
+
+	// Offset of the value within the tuple's user data, 0 if it is missing
+	Offset int
+}
+
+// Deformer splits tuples into per-column datums according to a column descriptor list.
+type Deformer struct {
+	attrs []Attribute
+	order binary.ByteOrder
+}
+
+// NewDeformer creates a new Deformer for the given columns, in attnum order.
+// A nil order selects pgtypes.DefaultByteOrder.
+func NewDeformer(attrs []Attribute, order binary.ByteOrder) *Deformer {
+	if order == nil {
+		order = pgtypes.DefaultByteOrder
+	}
+	return &Deformer{
+		attrs: attrs,
+		order: order,
+	}
+}
+
+// Attributes returns the column descriptors of the deformer.
+func (d *Deformer) Attributes() []Attribute {
+	return d.attrs
+}
+
+// Deform splits a tuple's user data into one datum per descriptor column.
+// Columns beyond the tuple's natts are returned as missing, with the value
+// from attmissingval that SELECT would return, or null; attributes
+// stored in the tuple beyond the descriptor are ignored, as PostgreSQL does.
+// Dropped columns are walked with the length and alignment pg_attribute
+// retains for them, so the columns after them are found where they are.
+func (d *Deformer) Deform(tuple *pager.Tuple) ([]Datum, error) {
+	datums := make([]Datum, len(d.attrs))
+	data := tuple.Data
+
+	// Only walk the attributes that are physically present
+	natts := pgtypes.HeapTupleHeaderGetNatts(tuple.Header)
+	if natts > len(d.attrs) {
+		natts = len(d.attrs)
+	}
+
+	offset := 0
+	for i := range d.attrs {
+		attr := &d.attrs[i]
+		datums[i].Attr = attr
+
+		// Column added after the tuple was written
+		if i >= natts {
+			datums[i].IsMissing = true
+			datums[i].IsNull = attr.Missing == nil
+			datums[i].Data = attr.Missing
+			continue
+		}
+
+		// Null columns take no space
+		if pgtypes.HeapTupleHeaderAttIsNull(tuple.Header, i) {
+			datums[i].IsNull = true
+			continue
+		}
+
+		// Align, unless this is a varlena with a short header (which is never padded)
+		if attr.AttLen != -1 || offset >= len(data) || data[offset] == 0 {
+			offset = AlignOffset(offset, attr.AttAlign)
+		}
+
+		// Determine the length of the value
+		length, err := d.attrLength(attr, data, offset)
+		if err != nil {
+			return nil, fmt.Errorf("%s at offset %d: %v", attr, offset, err)
+		}
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("%s at offset %d: length %d exceeds tuple data (%d bytes)",
+				attr, offset, length, len(data))
+		}
+
+		datums[i].Data = data[offset : offset+length]
+		datums[i].Offset = offset
+		offset += length
+	}
+
+	return datums, nil
+}
+
+// attrLength returns the number of bytes occupied by the value at offset.
+func (d *Deformer) attrLength(attr *Attribute, data []byte, offset int) (int, error) {
+	switch {
+	case attr.AttLen > 0:
+		return int(attr.AttLen), nil
+
+	case attr.AttLen == -1:
+		if offset >= len(data) {
+			return 0, fmt.Errorf("varlena header beyond tuple data (%d bytes)", len(data))
+		}
+		return VarSizeAny(data[offset:], d.order)
+
+	case attr.AttLen == -2:
+		end := bytes.IndexByte(data[offset:], 0)
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated cstring")
+		}
+		return end + 1, nil
+
+	default:
+		return 0, fmt.Errorf("invalid attlen %d", attr.AttLen)
+	}
+}
+
+// AlignOffset rounds offset up to the boundary required by an alignment code.
+func AlignOffset(offset int, align byte) int {
+	var n int
+	switch align {
+	case TYPALIGN_SHORT:
+		n = 2
+	case TYPALIGN_INT:
+		n = 4
+	case TYPALIGN_DOUBLE:
+		n = 8
+	default:
+		n = 1
+	}
+	return (offset + n - 1) &^ (n - 1)
+}
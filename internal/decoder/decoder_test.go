@@ -0,0 +1,186 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// testAttributes are the columns of the tuples below: an int2, a text that
+// fits a short header, a text with a 4-byte header, an int8 and a text that
+// is always null.
+var testAttributes = []Attribute{
+	{Name: "a", AttNum: 1, TypeOid: pgtypes.INT2OID, AttLen: 2, AttAlign: TYPALIGN_SHORT, AttByVal: true},
+	{Name: "b", AttNum: 2, TypeOid: pgtypes.TEXTOID, AttLen: -1, AttAlign: TYPALIGN_INT},
+	{Name: "c", AttNum: 3, TypeOid: pgtypes.TEXTOID, AttLen: -1, AttAlign: TYPALIGN_INT},
+	{Name: "e", AttNum: 4, TypeOid: pgtypes.INT8OID, AttLen: 8, AttAlign: TYPALIGN_DOUBLE, AttByVal: true},
+	{Name: "f", AttNum: 5, TypeOid: pgtypes.TEXTOID, AttLen: -1, AttAlign: TYPALIGN_INT},
+}
+
+// testTuple returns a tuple of testAttributes with natts attributes, f null.
+func testTuple(data []byte, natts int) *pager.Tuple {
+	tuple := &pager.Tuple{Data: data}
+	tuple.Header.TInfomask2 = uint16(natts)
+	tuple.Header.TInfomask = pgtypes.HEAP_HASNULL
+	tuple.Header.TBits = []byte{0x0F}
+	return tuple
+}
+
+// testTupleData is the user data of a tuple (1, 'hi', 'xyz', 1, null) in
+// each byte order. The short varlena at offset 2 is not aligned; the 4-byte
+// varlena after it is, after 3 bytes of padding.
+var testTupleData = map[binary.ByteOrder][]byte{
+	binary.LittleEndian: {
+		0x01, 0x00, // a
+		0x07, 'h', 'i', // b, short header
+		0, 0, 0, // padding
+		0x1C, 0x00, 0x00, 0x00, 'x', 'y', 'z', // c, 4-byte header
+		0,                      // padding
+		1, 0, 0, 0, 0, 0, 0, 0, // e
+	},
+	binary.BigEndian: {
+		0x00, 0x01,
+		0x83, 'h', 'i',
+		0, 0, 0,
+		0x00, 0x00, 0x00, 0x07, 'x', 'y', 'z',
+		0,
+		0, 0, 0, 0, 0, 0, 0, 1,
+	},
+}
+
+func TestDeform(t *testing.T) {
+	want := []struct {
+		offset int
+		length int
+		null   bool
+	}{
+		{0, 2, false},
+		{2, 3, false},
+		{8, 7, false},
+		{16, 8, false},
+		{0, 0, true},
+	}
+
+	for order, data := range testTupleData {
+		datums, err := NewDeformer(testAttributes, order).Deform(testTuple(data, 5))
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		if len(datums) != len(want) {
+			t.Fatalf("%s: %d datums, want %d", order, len(datums), len(want))
+		}
+		for i, datum := range datums {
+			w := want[i]
+			if datum.Attr != &testAttributes[i] || datum.IsNull != w.null || datum.IsMissing ||
+				datum.Offset != w.offset || len(datum.Data) != w.length {
+				t.Errorf("%s: %s: offset %d, %d bytes, null %v, missing %v; want offset %d, %d bytes, null %v",
+					order, datum.Attr, datum.Offset, len(datum.Data), datum.IsNull, datum.IsMissing, w.offset, w.length, w.null)
+			}
+		}
+		if text, err := VarData(datums[1].Data, order); err != nil || string(text) != "hi" {
+			t.Errorf("%s: b is %q (%v)", order, text, err)
+		}
+		if text, err := VarData(datums[2].Data, order); err != nil || string(text) != "xyz" {
+			t.Errorf("%s: c is %q (%v)", order, text, err)
+		}
+		if v := order.Uint64(datums[3].Data); v != 1 {
+			t.Errorf("%s: e is %d", order, v)
+		}
+	}
+}
+
+func TestDeformMissingAttributes(t *testing.T) {
+	attrs := append([]Attribute(nil), testAttributes...)
+	attrs = append(attrs,
+		Attribute{Name: "g", AttNum: 6, TypeOid: pgtypes.INT4OID, AttLen: 4, AttAlign: TYPALIGN_INT, AttByVal: true, Missing: []byte{42, 0, 0, 0}},
+		Attribute{Name: "h", AttNum: 7, TypeOid: pgtypes.TEXTOID, AttLen: -1, AttAlign: TYPALIGN_INT},
+	)
+	order := binary.LittleEndian
+	datums, err := NewDeformer(attrs, order).Deform(testTuple(testTupleData[order], 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, datum := range datums[:5] {
+		if datum.IsMissing {
+			t.Errorf("%s is missing", datum.Attr)
+		}
+	}
+	if g := datums[5]; !g.IsMissing || g.IsNull || !bytes.Equal(g.Data, []byte{42, 0, 0, 0}) || g.Offset != 0 {
+		t.Errorf("g: missing %v, null %v, data % x, offset %d", g.IsMissing, g.IsNull, g.Data, g.Offset)
+	}
+	if h := datums[6]; !h.IsMissing || !h.IsNull || h.Data != nil {
+		t.Errorf("h: missing %v, null %v, data % x", h.IsMissing, h.IsNull, h.Data)
+	}
+
+	// Attributes beyond the descriptor are ignored
+	datums, err = NewDeformer(testAttributes[:2], order).Deform(testTuple(testTupleData[order], 5))
+	if err != nil || len(datums) != 2 {
+		t.Errorf("descriptor of 2 columns: %d datums (%v)", len(datums), err)
+	}
+}
+
+func TestDeformDroppedAttributes(t *testing.T) {
+	attrs := append([]Attribute(nil), testAttributes...)
+	attrs[1].Name = "........pg.dropped.2........"
+	attrs[1].AttIsDropped = true
+	order := binary.LittleEndian
+
+	// The dropped column keeps its place, so the columns after it are found
+	datums, err := NewDeformer(attrs, order).Deform(testTuple(testTupleData[order], 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, c := datums[1], datums[2]; b.Offset != 2 || len(b.Data) != 3 || c.Offset != 8 || string(c.Data[4:]) != "xyz" {
+		t.Errorf("b at offset %d, % x; c at offset %d, % x", b.Offset, b.Data, c.Offset, c.Data)
+	}
+
+	// Errors name it by number
+	data := []byte{0x01, 0x00, 0x7F}
+	if _, err := NewDeformer(attrs, order).Deform(testTuple(data, 5)); err == nil || !strings.Contains(err.Error(), "dropped column 2 at offset 2") {
+		t.Errorf("truncated dropped column: %v", err)
+	}
+}
+
+func TestDeformCorrupt(t *testing.T) {
+	order := binary.LittleEndian
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated int8", testTupleData[order][:20]},
+		{"varlena beyond data", append([]byte{0x01, 0x00, 0x07, 'h', 'i', 0, 0, 0, 0xFC, 0x00, 0x00, 0x00}, make([]byte, 12)...)},
+		{"varlena header beyond data", []byte{0x01, 0x00, 0x07, 'h', 'i'}},
+	}
+
+	deformer := NewDeformer(testAttributes, order)
+	for _, test := range tests {
+		if datums, err := deformer.Deform(testTuple(test.data, 5)); err == nil {
+			t.Errorf("%s: no error, %d datums", test.name, len(datums))
+		}
+	}
+}
+
+func TestAlignOffset(t *testing.T) {
+	tests := []struct {
+		offset int
+		align  byte
+		want   int
+	}{
+		{5, TYPALIGN_CHAR, 5},
+		{5, TYPALIGN_SHORT, 6},
+		{5, TYPALIGN_INT, 8},
+		{8, TYPALIGN_INT, 8},
+		{9, TYPALIGN_DOUBLE, 16},
+		{0, TYPALIGN_DOUBLE, 0},
+	}
+	for _, test := range tests {
+		if got := AlignOffset(test.offset, test.align); got != test.want {
+			t.Errorf("AlignOffset(%d, %c) = %d, want %d", test.offset, test.align, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package decoder
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// textEncodings are the character sets of the server encodings that are
+// converted to UTF-8 for output. EUC_CN is GB2312 in EUC form, which GBK
+// decodes.
+var textEncodings = map[pgtypes.Encoding]encoding.Encoding{
+	pgtypes.PG_EUC_JP:     japanese.EUCJP,
+	pgtypes.PG_EUC_CN:     simplifiedchinese.GBK,
+	pgtypes.PG_EUC_KR:     korean.EUCKR,
+	pgtypes.PG_LATIN1:     charmap.ISO8859_1,
+	pgtypes.PG_LATIN2:     charmap.ISO8859_2,
+	pgtypes.PG_LATIN3:     charmap.ISO8859_3,
+	pgtypes.PG_LATIN4:     charmap.ISO8859_4,
+	pgtypes.PG_LATIN5:     charmap.ISO8859_9,
+	pgtypes.PG_LATIN6:     charmap.ISO8859_10,
+	pgtypes.PG_LATIN7:     charmap.ISO8859_13,
+	pgtypes.PG_LATIN8:     charmap.ISO8859_14,
+	pgtypes.PG_LATIN9:     charmap.ISO8859_15,
+	pgtypes.PG_LATIN10:    charmap.ISO8859_16,
+	pgtypes.PG_WIN1256:    charmap.Windows1256,
+	pgtypes.PG_WIN1258:    charmap.Windows1258,
+	pgtypes.PG_WIN866:     charmap.CodePage866,
+	pgtypes.PG_WIN874:     charmap.Windows874,
+	pgtypes.PG_KOI8R:      charmap.KOI8R,
+	pgtypes.PG_WIN1251:    charmap.Windows1251,
+	pgtypes.PG_WIN1252:    charmap.Windows1252,
+	pgtypes.PG_ISO_8859_5: charmap.ISO8859_5,
+	pgtypes.PG_ISO_8859_6: charmap.ISO8859_6,
+	pgtypes.PG_ISO_8859_7: charmap.ISO8859_7,
+	pgtypes.PG_ISO_8859_8: charmap.ISO8859_8,
+	pgtypes.PG_WIN1250:    charmap.Windows1250,
+	pgtypes.PG_WIN1253:    charmap.Windows1253,
+	pgtypes.PG_WIN1254:    charmap.Windows1254,
+	pgtypes.PG_WIN1255:    charmap.Windows1255,
+	pgtypes.PG_WIN1257:    charmap.Windows1257,
+	pgtypes.PG_KOI8U:      charmap.KOI8U,
+}
+
+// TextOutput converts text stored in a database of the given encoding to
+// UTF-8. UTF8 text must be valid; SQL_ASCII text is returned as stored, as
+// PostgreSQL does not know what it is either.
+func TextOutput(data []byte, enc pgtypes.Encoding) (string, error) {
+	switch enc {
+	case pgtypes.PG_UTF8:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("invalid UTF8 text")
+		}
+		return string(data), nil
+	case pgtypes.PG_SQL_ASCII:
+		return string(data), nil
+	}
+
+	charset, ok := textEncodings[enc]
+	if !ok {
+		return "", fmt.Errorf("cannot convert %s text to UTF8", enc)
+	}
+	text, err := charset.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s text: %v", enc, err)
+	}
+	return string(text), nil
+}
@@ -0,0 +1,448 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// ErrUnsupportedType is returned by Output for types it has no output function for.
+var ErrUnsupportedType = errors.New("unsupported type")
+
+// postgresEpoch is the origin of dates and timestamps, 2000-01-01 00:00 UTC.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Special values of dates and timestamps
+const (
+	DATEVAL_NOBEGIN = math.MinInt32
+	DATEVAL_NOEND   = math.MaxInt32
+	DT_NOBEGIN      = math.MinInt64
+	DT_NOEND        = math.MaxInt64
+)
+
+// Output returns the text representation of a non-null datum, as the type's
+// output function prints it with DateStyle ISO and TimeZone UTC, in UTF-8.
+// Text is converted from enc, the encoding of the database. Compressed values
+// are decompressed. It returns ErrUnsupportedType for types it does not know,
+// and ErrExternal for values stored in a TOAST table.
+func Output(datum *Datum, order binary.ByteOrder, enc pgtypes.Encoding) (string, error) {
+	if order == nil {
+		order = pgtypes.DefaultByteOrder
+	}
+	data := datum.Data
+
+	// Fixed-length values must have their full length
+	if datum.Attr.AttLen > 0 && len(data) < int(datum.Attr.AttLen) {
+		return "", fmt.Errorf("value is %d bytes, expected %d", len(data), datum.Attr.AttLen)
+	}
+
+	switch datum.Attr.TypeOid {
+	case pgtypes.BOOLOID:
+		if data[0] != 0 {
+			return "t", nil
+		}
+		return "f", nil
+	case pgtypes.CHAROID:
+		return charOutput(data[0]), nil
+	case pgtypes.NAMEOID:
+		if end := bytes.IndexByte(data, 0); end >= 0 {
+			data = data[:end]
+		}
+		return TextOutput(data, enc)
+	case pgtypes.INT2OID:
+		return strconv.FormatInt(int64(int16(order.Uint16(data))), 10), nil
+	case pgtypes.INT4OID:
+		return strconv.FormatInt(int64(int32(order.Uint32(data))), 10), nil
+	case pgtypes.INT8OID:
+		return strconv.FormatInt(int64(order.Uint64(data)), 10), nil
+	case pgtypes.OIDOID, pgtypes.XIDOID, pgtypes.CIDOID, pgtypes.REGPROCOID, pgtypes.REGCLASSOID, pgtypes.REGTYPEOID:
+		return strconv.FormatUint(uint64(order.Uint32(data)), 10), nil
+	case pgtypes.FLOAT4OID:
+		return floatOutput(float64(math.Float32frombits(order.Uint32(data))), 32), nil
+	case pgtypes.FLOAT8OID:
+		return floatOutput(math.Float64frombits(order.Uint64(data)), 64), nil
+	case pgtypes.MONEYOID:
+		return moneyOutput(int64(order.Uint64(data))), nil
+	case pgtypes.TIDOID:
+		block := uint32(order.Uint16(data))<<16 | uint32(order.Uint16(data[2:]))
+		return fmt.Sprintf("(%d,%d)", block, order.Uint16(data[4:])), nil
+	case pgtypes.MACADDROID:
+		return net.HardwareAddr(data[:6]).String(), nil
+	case pgtypes.UUIDOID:
+		h := hex.EncodeToString(data[:16])
+		return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+	case pgtypes.PGLSNOID:
+		lsn := order.Uint64(data)
+		return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn)), nil
+	case pgtypes.DATEOID:
+		return dateOutput(int32(order.Uint32(data))), nil
+	case pgtypes.TIMEOID:
+		return timeOutput(int64(order.Uint64(data))), nil
+	case pgtypes.TIMETZOID:
+		return timeOutput(int64(order.Uint64(data))) + zoneOutput(int32(order.Uint32(data[8:]))), nil
+	case pgtypes.TIMESTAMPOID:
+		return timestampOutput(int64(order.Uint64(data)), false), nil
+	case pgtypes.TIMESTAMPTZOID:
+		return timestampOutput(int64(order.Uint64(data)), true), nil
+	case pgtypes.INTERVALOID:
+		return intervalOutput(int64(order.Uint64(data)), int32(order.Uint32(data[8:])), int32(order.Uint32(data[12:]))), nil
+	}
+
+	if datum.Attr.AttLen != -1 {
+		return "", ErrUnsupportedType
+	}
+	value, err := DecodeVarlena(data, order)
+	if err != nil {
+		return "", err
+	}
+	if value.Toast != nil {
+		return "", fmt.Errorf("%w: %s", ErrExternal, value.Toast)
+	}
+	payload := value.Data
+
+	switch datum.Attr.TypeOid {
+	case pgtypes.TEXTOID, pgtypes.VARCHAROID, pgtypes.BPCHAROID, pgtypes.JSONOID, pgtypes.XMLOID, pgtypes.PGNODETREEOID:
+		return TextOutput(payload, enc)
+	case pgtypes.BYTEAOID:
+		return `\x` + hex.EncodeToString(payload), nil
+	case pgtypes.NUMERICOID:
+		return numericOutput(payload, order)
+	case pgtypes.INETOID, pgtypes.CIDROID:
+		return inetOutput(payload, datum.Attr.TypeOid == pgtypes.CIDROID)
+	case pgtypes.BITOID, pgtypes.VARBITOID:
+		return bitOutput(payload, order)
+	}
+	return "", ErrUnsupportedType
+}
+
+// Raw returns the bytes of a non-null datum for forensic output, when its
+// type is unknown, as for dropped columns: the payload of an inline varlena,
+// decompressed, or else the value as stored, fixed-length values in the
+// cluster's byte order and TOAST pointers with their header.
+func Raw(datum *Datum, order binary.ByteOrder) []byte {
+	if datum.Attr.AttLen == -1 {
+		if value, err := DecodeVarlena(datum.Data, order); err == nil && value.Toast == nil {
+			return value.Data
+		}
+	}
+	return datum.Data
+}
+
+// charOutput prints a "char" value, escaping bytes with the high bit set
+// as charout does.
+func charOutput(c byte) string {
+	switch {
+	case c == 0:
+		return ""
+	case c&0x80 != 0:
+		return fmt.Sprintf(`\%03o`, c)
+	}
+	return string([]byte{c})
+}
+
+// floatOutput prints a floating point value in its shortest exact form.
+func floatOutput(v float64, bits int) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(v, 'g', -1, bits)
+}
+
+// moneyOutput prints an amount of money in cents, without currency symbol.
+func moneyOutput(cents int64) string {
+	sign := ""
+	u := uint64(cents)
+	if cents < 0 {
+		sign, u = "-", uint64(-cents)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, u/100, u%100)
+}
+
+// dateOutput prints a date, stored as days since 2000-01-01.
+func dateOutput(days int32) string {
+	switch days {
+	case DATEVAL_NOBEGIN:
+		return "-infinity"
+	case DATEVAL_NOEND:
+		return "infinity"
+	}
+	return formatDate(postgresEpoch.AddDate(0, 0, int(days)))
+}
+
+// formatDate prints the date part of t, with years before 1 as BC.
+func formatDate(t time.Time) string {
+	year, bc := t.Year(), ""
+	if year <= 0 {
+		year, bc = 1-year, " BC"
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", year, t.Month(), t.Day()) + bc
+}
+
+// timeOutput prints a time of day, stored as microseconds since midnight.
+func timeOutput(usecs int64) string {
+	var b strings.Builder
+	writeClock(&b, usecs)
+	return b.String()
+}
+
+// writeClock writes a non-negative number of microseconds as HH:MM:SS with
+// the fraction of a second if any.
+func writeClock(b *strings.Builder, usecs int64) {
+	secs := usecs / 1000000
+	fmt.Fprintf(b, "%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	if frac := usecs % 1000000; frac != 0 {
+		b.WriteString(strings.TrimRight(fmt.Sprintf(".%06d", frac), "0"))
+	}
+}
+
+// zoneOutput prints a time zone offset, stored in seconds west of UTC.
+func zoneOutput(west int32) string {
+	sign, east := "+", -west
+	if east < 0 {
+		sign, east = "-", -east
+	}
+	s := fmt.Sprintf("%s%02d", sign, east/3600)
+	if east%3600 != 0 {
+		s += fmt.Sprintf(":%02d", east/60%60)
+		if east%60 != 0 {
+			s += fmt.Sprintf(":%02d", east%60)
+		}
+	}
+	return s
+}
+
+// timestampOutput prints a timestamp, stored as microseconds since
+// 2000-01-01. Timestamps with time zone are printed in UTC.
+func timestampOutput(usecs int64, withZone bool) string {
+	switch usecs {
+	case DT_NOBEGIN:
+		return "-infinity"
+	case DT_NOEND:
+		return "infinity"
+	}
+
+	secs, frac := usecs/1000000, usecs%1000000
+	if frac < 0 {
+		secs, frac = secs-1, frac+1000000
+	}
+	t := postgresEpoch.Add(time.Duration(secs%86400)*time.Second).AddDate(0, 0, int(secs/86400))
+
+	var b strings.Builder
+	date := formatDate(t)
+	bc := strings.HasSuffix(date, " BC")
+	b.WriteString(strings.TrimSuffix(date, " BC"))
+	b.WriteByte(' ')
+	writeClock(&b, int64(t.Hour()*3600+t.Minute()*60+t.Second())*1000000+frac)
+	if withZone {
+		b.WriteString("+00")
+	}
+	if bc {
+		b.WriteString(" BC")
+	}
+	return b.String()
+}
+
+// intervalOutput prints an interval in the postgres IntervalStyle, e.g.
+// "1 year 2 mons 3 days 04:05:06".
+func intervalOutput(usecs int64, days, months int32) string {
+	var parts []string
+	negative := false
+	add := func(n int64, unit string) {
+		if n == 0 {
+			return
+		}
+		sign := ""
+		if negative && n > 0 {
+			sign = "+"
+		}
+		plural := "s"
+		if n == 1 {
+			plural = ""
+		}
+		parts = append(parts, fmt.Sprintf("%s%d %s%s", sign, n, unit, plural))
+		negative = n < 0
+	}
+	add(int64(months/12), "year")
+	add(int64(months%12), "mon")
+	add(int64(days), "day")
+
+	if usecs != 0 || len(parts) == 0 {
+		var b strings.Builder
+		switch {
+		case usecs < 0:
+			b.WriteByte('-')
+			usecs = -usecs
+		case negative:
+			b.WriteByte('+')
+		}
+		writeClock(&b, usecs)
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Numeric header bits
+const (
+	NUMERIC_SIGN_MASK = 0xC000
+	NUMERIC_POS       = 0x0000
+	NUMERIC_NEG       = 0x4000
+	NUMERIC_SHORT     = 0x8000
+	NUMERIC_SPECIAL   = 0xC000
+	NUMERIC_NAN       = 0xC000
+	NUMERIC_PINF      = 0xD000
+	NUMERIC_NINF      = 0xF000
+
+	NUMERIC_SHORT_SIGN_MASK        = 0x2000
+	NUMERIC_SHORT_DSCALE_MASK      = 0x1F80
+	NUMERIC_SHORT_DSCALE_SHIFT     = 7
+	NUMERIC_SHORT_WEIGHT_SIGN_MASK = 0x0040
+	NUMERIC_SHORT_WEIGHT_MASK      = 0x003F
+	NUMERIC_DSCALE_MASK            = 0x3FFF
+
+	NBASE      = 10000
+	DEC_DIGITS = 4
+)
+
+// numericOutput prints a numeric value from its varlena payload, a header
+// followed by base-10000 digits.
+func numericOutput(payload []byte, order binary.ByteOrder) (string, error) {
+	if len(payload) < 2 {
+		return "", fmt.Errorf("numeric value is %d bytes", len(payload))
+	}
+	header := order.Uint16(payload)
+
+	var negative bool
+	var weight, dscale int
+	var digits []byte
+	switch header & NUMERIC_SIGN_MASK {
+	case NUMERIC_SPECIAL:
+		switch header & 0xF000 {
+		case NUMERIC_NAN:
+			return "NaN", nil
+		case NUMERIC_PINF:
+			return "Infinity", nil
+		case NUMERIC_NINF:
+			return "-Infinity", nil
+		}
+		return "", fmt.Errorf("invalid numeric header %#04x", header)
+	case NUMERIC_SHORT:
+		negative = header&NUMERIC_SHORT_SIGN_MASK != 0
+		dscale = int(header&NUMERIC_SHORT_DSCALE_MASK) >> NUMERIC_SHORT_DSCALE_SHIFT
+		weight = int(header & NUMERIC_SHORT_WEIGHT_MASK)
+		if header&NUMERIC_SHORT_WEIGHT_SIGN_MASK != 0 {
+			weight -= NUMERIC_SHORT_WEIGHT_MASK + 1
+		}
+		digits = payload[2:]
+	default:
+		if len(payload) < 4 {
+			return "", fmt.Errorf("numeric value is %d bytes", len(payload))
+		}
+		negative = header&NUMERIC_SIGN_MASK == NUMERIC_NEG
+		dscale = int(header & NUMERIC_DSCALE_MASK)
+		weight = int(int16(order.Uint16(payload[2:])))
+		digits = payload[4:]
+	}
+	if len(digits)%2 != 0 {
+		return "", fmt.Errorf("numeric digits are %d bytes", len(digits))
+	}
+	ndigits := len(digits) / 2
+	digit := func(i int) int {
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+		return int(order.Uint16(digits[i*2:]))
+	}
+	for i := 0; i < ndigits; i++ {
+		if digit(i) >= NBASE {
+			return "", fmt.Errorf("invalid numeric digit %d", digit(i))
+		}
+	}
+
+	// Integer part, then dscale digits of fraction, as get_str_from_var
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	if weight < 0 {
+		b.WriteByte('0')
+	} else {
+		b.WriteString(strconv.Itoa(digit(0)))
+		for i := 1; i <= weight; i++ {
+			fmt.Fprintf(&b, "%04d", digit(i))
+		}
+	}
+	if dscale > 0 {
+		var frac strings.Builder
+		for i := weight + 1; frac.Len() < dscale; i++ {
+			fmt.Fprintf(&frac, "%04d", digit(i))
+		}
+		b.WriteByte('.')
+		b.WriteString(frac.String()[:dscale])
+	}
+	return b.String(), nil
+}
+
+// Address families of inet values
+const (
+	PGSQL_AF_INET  = 2
+	PGSQL_AF_INET6 = 3
+)
+
+// inetOutput prints an inet or cidr value from its varlena payload: family,
+// netmask bits and address.
+func inetOutput(payload []byte, cidr bool) (string, error) {
+	if len(payload) < 2 {
+		return "", fmt.Errorf("inet value is %d bytes", len(payload))
+	}
+	family, bits := payload[0], int(payload[1])
+	size := net.IPv4len
+	if family == PGSQL_AF_INET6 {
+		size = net.IPv6len
+	} else if family != PGSQL_AF_INET {
+		return "", fmt.Errorf("invalid inet family %d", family)
+	}
+	if len(payload) < 2+size {
+		return "", fmt.Errorf("inet value is %d bytes", len(payload))
+	}
+	ip := net.IP(payload[2 : 2+size]).String()
+	if cidr || bits != size*8 {
+		ip += "/" + strconv.Itoa(bits)
+	}
+	return ip, nil
+}
+
+// bitOutput prints a bit or bit varying value from its varlena payload: the
+// length in bits followed by the bits.
+func bitOutput(payload []byte, order binary.ByteOrder) (string, error) {
+	if len(payload) < 4 {
+		return "", fmt.Errorf("bit string value is %d bytes", len(payload))
+	}
+	n := int(int32(order.Uint32(payload)))
+	bits := payload[4:]
+	if n < 0 || (n+7)/8 > len(bits) {
+		return "", fmt.Errorf("invalid bit string length %d", n)
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if bits[i/8]&(0x80>>(i%8)) != 0 {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,173 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// le encodes little-endian integers of the given sizes.
+func le(values ...interface{}) []byte {
+	var data []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case int8:
+			data = append(data, byte(v))
+		case int16:
+			data = binary.LittleEndian.AppendUint16(data, uint16(v))
+		case int32:
+			data = binary.LittleEndian.AppendUint32(data, uint32(v))
+		case int64:
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		case []byte:
+			data = append(data, v...)
+		}
+	}
+	return data
+}
+
+// shortVarlena returns payload with a 1-byte little-endian varlena header.
+func shortVarlena(payload ...byte) []byte {
+	return append([]byte{byte(1+len(payload))<<1 | 1}, payload...)
+}
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		typeOid uint32
+		attLen  int16
+		data    []byte
+		want    string
+	}{
+		{"bool", pgtypes.BOOLOID, 1, []byte{1}, "t"},
+		{"char", pgtypes.CHAROID, 1, []byte{0xE9}, `\351`},
+		{"name", pgtypes.NAMEOID, 64, append([]byte("pg_class"), make([]byte, 56)...), "pg_class"},
+		{"int2", pgtypes.INT2OID, 2, le(int16(-5)), "-5"},
+		{"int4", pgtypes.INT4OID, 4, le(int32(2147483647)), "2147483647"},
+		{"int8", pgtypes.INT8OID, 8, le(int64(-9000000000)), "-9000000000"},
+		{"oid", pgtypes.OIDOID, 4, le(int32(-1)), "4294967295"},
+		{"float4", pgtypes.FLOAT4OID, 4, le(int32(math.Float32bits(0.1))), "0.1"},
+		{"float8", pgtypes.FLOAT8OID, 8, le(int64(math.Float64bits(-1.5))), "-1.5"},
+		{"float8 NaN", pgtypes.FLOAT8OID, 8, le(int64(math.Float64bits(math.NaN()))), "NaN"},
+		{"money", pgtypes.MONEYOID, 8, le(int64(-12345)), "-123.45"},
+		{"tid", pgtypes.TIDOID, 6, le(int16(1), int16(2), int16(3)), "(65538,3)"},
+		{"uuid", pgtypes.UUIDOID, 16, []byte{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11},
+			"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{"pg_lsn", pgtypes.PGLSNOID, 8, le(int64(0x1000028)), "0/1000028"},
+		{"date", pgtypes.DATEOID, 4, le(int32(-1)), "1999-12-31"},
+		{"date BC", pgtypes.DATEOID, 4, le(int32(-730120)), "0001-12-31 BC"},
+		{"date infinity", pgtypes.DATEOID, 4, le(int32(DATEVAL_NOEND)), "infinity"},
+		{"time", pgtypes.TIMEOID, 8, le(int64(3723250000)), "01:02:03.25"},
+		{"timetz", pgtypes.TIMETZOID, 12, le(int64(3723000000), int32(-19800)), "01:02:03+05:30"},
+		{"timestamp", pgtypes.TIMESTAMPOID, 8, le(int64(86401500000)), "2000-01-02 00:00:01.5"},
+		{"timestamp before 2000", pgtypes.TIMESTAMPOID, 8, le(int64(-1)), "1999-12-31 23:59:59.999999"},
+		{"timestamptz", pgtypes.TIMESTAMPTZOID, 8, le(int64(0)), "2000-01-01 00:00:00+00"},
+		{"timestamptz -infinity", pgtypes.TIMESTAMPTZOID, 8, le(int64(DT_NOBEGIN)), "-infinity"},
+		{"interval", pgtypes.INTERVALOID, 16, le(int64(14706000000), int32(3), int32(14)), "1 year 2 mons 3 days 04:05:06"},
+		{"negative interval", pgtypes.INTERVALOID, 16, le(int64(-60000000), int32(-1), int32(0)), "-1 days -00:01:00"},
+		{"zero interval", pgtypes.INTERVALOID, 16, le(int64(0), int32(0), int32(0)), "00:00:00"},
+		{"text", pgtypes.TEXTOID, -1, shortVarlena('h', 'i'), "hi"},
+		{"text 4-byte header", pgtypes.VARCHAROID, -1, le(int32(7<<2), []byte("xyz")), "xyz"},
+		{"bytea", pgtypes.BYTEAOID, -1, shortVarlena(0xDE, 0xAD), `\xdead`},
+		{"numeric", pgtypes.NUMERICOID, -1, shortVarlena(le(int16(-0x7F00), int16(123), int16(4500))...), "123.45"},
+		{"numeric fraction", pgtypes.NUMERICOID, -1, shortVarlena(le(int16(-0x5E01), int16(10))...), "-0.001"},
+		{"numeric long", pgtypes.NUMERICOID, -1, shortVarlena(le(int16(0), int16(1), int16(1), int16(0))...), "10000"},
+		{"numeric NaN", pgtypes.NUMERICOID, -1, shortVarlena(le(int16(-0x4000))...), "NaN"},
+		{"inet", pgtypes.INETOID, -1, shortVarlena(PGSQL_AF_INET, 32, 192, 168, 0, 1), "192.168.0.1"},
+		{"cidr", pgtypes.CIDROID, -1, shortVarlena(PGSQL_AF_INET, 24, 192, 168, 0, 0), "192.168.0.0/24"},
+		{"bit", pgtypes.VARBITOID, -1, shortVarlena(le(int32(4), []byte{0xA0})...), "1010"},
+	}
+
+	for _, test := range tests {
+		datum := &Datum{
+			Attr: &Attribute{Name: test.name, TypeOid: test.typeOid, AttLen: test.attLen},
+			Data: test.data,
+		}
+		if got, err := Output(datum, binary.LittleEndian, pgtypes.PG_UTF8); err != nil || got != test.want {
+			t.Errorf("%s: %q (%v), want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestOutputBigEndian(t *testing.T) {
+	datum := &Datum{
+		Attr: &Attribute{Name: "n", TypeOid: pgtypes.NUMERICOID, AttLen: -1},
+		Data: []byte{0x87, 0x81, 0x00, 0x00, 0x7B, 0x11, 0x94},
+	}
+	if got, err := Output(datum, binary.BigEndian, pgtypes.PG_UTF8); err != nil || got != "123.45" {
+		t.Errorf("numeric: %q (%v)", got, err)
+	}
+}
+
+func TestOutputErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		typeOid uint32
+		attLen  int16
+		data    []byte
+		want    error
+	}{
+		{"unknown type", 0, 4, le(int32(1)), ErrUnsupportedType},
+		{"unknown varlena type", 0, -1, shortVarlena('x'), ErrUnsupportedType},
+		{"corrupt compressed", pgtypes.TEXTOID, -1, le(int32(12<<2|2), int32(100), int32(0)), nil},
+		{"invalid UTF8", pgtypes.TEXTOID, -1, shortVarlena(0xE9), nil},
+		{"external", pgtypes.TEXTOID, -1, append([]byte{0x01, 18}, make([]byte, 16)...), ErrExternal},
+		{"truncated int8", pgtypes.INT8OID, 8, le(int32(1)), nil},
+		{"invalid numeric digit", pgtypes.NUMERICOID, -1, shortVarlena(le(int16(-0x8000), int16(10000))...), nil},
+		{"invalid inet family", pgtypes.INETOID, -1, shortVarlena(9, 32, 1, 2, 3, 4), nil},
+		{"truncated bit string", pgtypes.BITOID, -1, shortVarlena(le(int32(9), []byte{0xFF})...), nil},
+	}
+
+	for _, test := range tests {
+		datum := &Datum{
+			Attr: &Attribute{Name: test.name, TypeOid: test.typeOid, AttLen: test.attLen},
+			Data: test.data,
+		}
+		got, err := Output(datum, binary.LittleEndian, pgtypes.PG_UTF8)
+		if err == nil || (test.want != nil && !errors.Is(err, test.want)) {
+			t.Errorf("%s: %q (%v), want error %v", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		attLen int16
+		data   []byte
+		want   []byte
+	}{
+		{"fixed length", 4, le(int32(42)), le(int32(42))},
+		{"short varlena", -1, shortVarlena('h', 'i'), []byte("hi")},
+		{"compressed", -1, le(int32(12<<2|2), int32(100), int32(0)), le(int32(12<<2|2), int32(100), int32(0))},
+	}
+	for _, test := range tests {
+		datum := &Datum{Attr: &Attribute{Name: test.name, AttLen: test.attLen, AttIsDropped: true}, Data: test.data}
+		if got := Raw(datum, binary.LittleEndian); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: % x, want % x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOutputEncoding(t *testing.T) {
+	tests := []struct {
+		enc  pgtypes.Encoding
+		data []byte
+		want string
+	}{
+		{pgtypes.PG_UTF8, shortVarlena(0xC3, 0xA9), "é"},
+		{pgtypes.PG_LATIN1, shortVarlena(0xE9), "é"},
+		{pgtypes.PG_WIN1251, shortVarlena(0xC4, 0xE0), "Да"},
+		{pgtypes.PG_SQL_ASCII, shortVarlena(0xE9), "\xe9"},
+	}
+	for _, test := range tests {
+		datum := &Datum{Attr: &Attribute{Name: "t", TypeOid: pgtypes.TEXTOID, AttLen: -1}, Data: test.data}
+		if got, err := Output(datum, binary.LittleEndian, test.enc); err != nil || got != test.want {
+			t.Errorf("%s: %q (%v), want %q", test.enc, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Varlena header sizes
+const (
+	VARHDRSZ          = 4 // size of a 4-byte varlena header
+	VARHDRSZ_SHORT    = 1 // size of a 1-byte varlena header
+	VARHDRSZ_EXTERNAL = 2 // size of an external TOAST pointer header (1-byte header plus tag)
+)
+
+// Tags of external varlena datums (vartag_external)
+const (
+	VARTAG_INDIRECT    = 1  // in-memory pointer to another datum
+	VARTAG_EXPANDED_RO = 2  // read-only expanded object
+	VARTAG_EXPANDED_RW = 3  // read-write expanded object
+	VARTAG_ONDISK      = 18 // pointer to a value in a TOAST table
+)
+
+// Sizes of the payloads behind each external tag
+const (
+	sizeOfVaratt_indirect = 8
+	sizeOfVaratt_expanded = 8
+	sizeOfVaratt_external = 16
+)
+
+// VarattIs1BE checks if a varlena starts with an external (TOAST pointer) header.
+func VarattIs1BE(data []byte, order binary.ByteOrder) bool {
+	if order == binary.BigEndian {
+		return data[0] == 0x80
+	}
+	return data[0] == 0x01
+}
+
+// VarattIs1B checks if a varlena starts with a 1-byte header (short or external).
+func VarattIs1B(data []byte, order binary.ByteOrder) bool {
+	if order == binary.BigEndian {
+		return data[0]&0x80 == 0x80
+	}
+	return data[0]&0x01 == 0x01
+}
+
+// VarSize1B returns the total size of a short varlena, including its header.
+func VarSize1B(data []byte, order binary.ByteOrder) int {
+	if order == binary.BigEndian {
+		return int(data[0] & 0x7F)
+	}
+	return int((data[0] >> 1) & 0x7F)
+}
+
+// VarSize4B returns the total size of a varlena with a 4-byte header.
+func VarSize4B(data []byte, order binary.ByteOrder) int {
+	if order == binary.BigEndian {
+		return int(order.Uint32(data[0:4]) & 0x3FFFFFFF)
+	}
+	return int((order.Uint32(data[0:4]) >> 2) & 0x3FFFFFFF)
+}
+
+// VartagSize returns the size of the payload behind an external varlena tag.
+func VartagSize(tag byte) (int, error) {
+	switch tag {
+	case VARTAG_INDIRECT:
+		return sizeOfVaratt_indirect, nil
+	case VARTAG_EXPANDED_RO, VARTAG_EXPANDED_RW:
+		return sizeOfVaratt_expanded, nil
+	case VARTAG_ONDISK:
+		return sizeOfVaratt_external, nil
+	default:
+		return 0, fmt.Errorf("unknown external varlena tag %d", tag)
+	}
+}
+
+// VarSizeAny returns the total on-disk size of the varlena at the start of
+// data, whatever kind of header it has. It is the equivalent of PostgreSQL's
+// VARSIZE_ANY.
+func VarSizeAny(data []byte, order binary.ByteOrder) (int, error) {
+	if len(data) < 1 {
+		return 0, fmt.Errorf("empty varlena")
+	}
+
+	// External TOAST pointer: header byte, tag byte, payload
+	if VarattIs1BE(data, order) {
+		if len(data) < VARHDRSZ_EXTERNAL {
+			return 0, fmt.Errorf("truncated external varlena header")
+		}
+		size, err := VartagSize(data[1])
+		if err != nil {
+			return 0, err
+		}
+		return VARHDRSZ_EXTERNAL + size, nil
+	}
+
+	// Short header: length includes the single header byte
+	if VarattIs1B(data, order) {
+		size := VarSize1B(data, order)
+		if size < VARHDRSZ_SHORT {
+			return 0, fmt.Errorf("invalid short varlena length %d", size)
+		}
+		return size, nil
+	}
+
+	// Regular 4-byte header
+	if len(data) < VARHDRSZ {
+		return 0, fmt.Errorf("truncated varlena header")
+	}
+	size := VarSize4B(data, order)
+	if size < VARHDRSZ {
+		return 0, fmt.Errorf("invalid varlena length %d", size)
+	}
+	return size, nil
+}
+
+// Errors returned by VarData for values that are not stored inline as is
+var (
+	ErrCompressed = errors.New("compressed varlena")
+	ErrExternal   = errors.New("external TOAST pointer")
+)
+
+// VarattIs4BC checks if a varlena with a 4-byte header holds a compressed value.
+func VarattIs4BC(data []byte, order binary.ByteOrder) bool {
+	if order == binary.BigEndian {
+		return data[0]&0xC0 == 0x40
+	}
+	return data[0]&0x03 == 0x02
+}
+
+// Compression methods of compressed varlenas (ToastCompressionId). Before
+// PostgreSQL 14 every value is compressed with pglz and the method bits,
+// the top two of the raw size, are zero.
+const (
+	TOAST_PGLZ_COMPRESSION_ID    = 0
+	TOAST_LZ4_COMPRESSION_ID     = 1
+	TOAST_INVALID_COMPRESSION_ID = 2
+)
+
+// Layout of the size and compression method of compressed values
+const (
+	VARLENA_EXTSIZE_BITS = 30
+	VARLENA_EXTSIZE_MASK = 1<<VARLENA_EXTSIZE_BITS - 1
+	VARHDRSZ_COMPRESSED  = 8 // 4-byte header plus va_tcinfo
+)
+
+// ToastPointer is an external TOAST pointer (varatt_external): a value
+// stored out of line, in chunks of a TOAST table.
+type ToastPointer struct {
+	RawSize    int32  // va_rawsize: size of the original value, including its header
+	ExtInfo    uint32 // va_extinfo: stored size, and compression method in the top 2 bits (14+)
+	ValueID    uint32 // va_valueid: chunk_id of the value in the TOAST table
+	ToastRelID uint32 // va_toastrelid: OID of the TOAST table
+}
+
+// ExtSize returns the size of the value as stored in the TOAST table.
+func (p *ToastPointer) ExtSize() int {
+	return int(p.ExtInfo & VARLENA_EXTSIZE_MASK)
+}
+
+// CompressionMethod returns the compression method of the stored value.
+func (p *ToastPointer) CompressionMethod() int {
+	return int(p.ExtInfo >> VARLENA_EXTSIZE_BITS)
+}
+
+// IsCompressed reports whether the stored value is compressed, that is,
+// smaller than the original value.
+func (p *ToastPointer) IsCompressed() bool {
+	return p.ExtSize() < int(p.RawSize)-VARHDRSZ
+}
+
+// String describes the pointer, e.g. "value 16403 in TOAST table 16389 (2000
+// bytes, 512 stored compressed)".
+func (p *ToastPointer) String() string {
+	if p.IsCompressed() {
+		return fmt.Sprintf("value %d in TOAST table %d (%d bytes, %d stored compressed)",
+			p.ValueID, p.ToastRelID, int(p.RawSize)-VARHDRSZ, p.ExtSize())
+	}
+	return fmt.Sprintf("value %d in TOAST table %d (%d bytes)", p.ValueID, p.ToastRelID, int(p.RawSize)-VARHDRSZ)
+}
+
+// Value returns the value the pointer refers to, from the concatenated data
+// of its chunks, decompressing it if needed.
+func (p *ToastPointer) Value(stored []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(stored) != p.ExtSize() {
+		return nil, fmt.Errorf("%s: chunks hold %d bytes, expected %d", p, len(stored), p.ExtSize())
+	}
+	if !p.IsCompressed() {
+		return stored, nil
+	}
+	// The chunks hold the compressed datum without its 4-byte header
+	if len(stored) < VARHDRSZ_COMPRESSED-VARHDRSZ {
+		return nil, fmt.Errorf("%s: compressed value of %d bytes", p, len(stored))
+	}
+	return decompress(p.CompressionMethod(), stored[VARHDRSZ_COMPRESSED-VARHDRSZ:], int(p.RawSize)-VARHDRSZ)
+}
+
+// Varlena is a decoded varlena: its value, or where the value is stored out
+// of line.
+type Varlena struct {
+	Data  []byte        // value without its header, decompressed; nil if external
+	Toast *ToastPointer // pointer to the value in a TOAST table, or nil
+}
+
+// DecodeVarlena decodes the varlena at the start of data, whatever its
+// header: short and 4-byte headers give the payload, inline compressed values
+// are decompressed with pglz or LZ4, and external values give the TOAST
+// pointer to resolve. It is the on-disk half of PostgreSQL's detoasting.
+func DecodeVarlena(data []byte, order binary.ByteOrder) (*Varlena, error) {
+	size, err := VarSizeAny(data, order)
+	if err != nil {
+		return nil, err
+	}
+	if size > len(data) {
+		return nil, fmt.Errorf("varlena length %d exceeds the %d bytes available", size, len(data))
+	}
+
+	switch {
+	case VarattIs1BE(data, order):
+		if data[1] != VARTAG_ONDISK {
+			return nil, fmt.Errorf("in-memory external varlena (tag %d) on disk", data[1])
+		}
+		pointer := data[VARHDRSZ_EXTERNAL:size]
+		return &Varlena{Toast: &ToastPointer{
+			RawSize:    int32(order.Uint32(pointer[0:])),
+			ExtInfo:    order.Uint32(pointer[4:]),
+			ValueID:    order.Uint32(pointer[8:]),
+			ToastRelID: order.Uint32(pointer[12:]),
+		}}, nil
+	case VarattIs1B(data, order):
+		return &Varlena{Data: data[VARHDRSZ_SHORT:size]}, nil
+	case VarattIs4BC(data, order):
+		if size < VARHDRSZ_COMPRESSED {
+			return nil, fmt.Errorf("compressed varlena of %d bytes", size)
+		}
+		info := order.Uint32(data[VARHDRSZ:])
+		value, err := decompress(int(info>>VARLENA_EXTSIZE_BITS), data[VARHDRSZ_COMPRESSED:size], int(info&VARLENA_EXTSIZE_MASK))
+		if err != nil {
+			return nil, err
+		}
+		return &Varlena{Data: value}, nil
+	default:
+		return &Varlena{Data: data[VARHDRSZ:size]}, nil
+	}
+}
+
+// VarData returns the payload of an uncompressed inline varlena, without its
+// header. It is the equivalent of PostgreSQL's VARDATA_ANY.
+func VarData(data []byte, order binary.ByteOrder) ([]byte, error) {
+	size, err := VarSizeAny(data, order)
+	if err != nil {
+		return nil, err
+	}
+	if size > len(data) {
+		return nil, fmt.Errorf("varlena length %d exceeds the %d bytes available", size, len(data))
+	}
+
+	switch {
+	case VarattIs1BE(data, order):
+		return nil, ErrExternal
+	case VarattIs1B(data, order):
+		return data[VARHDRSZ_SHORT:size], nil
+	case VarattIs4BC(data, order):
+		return nil, ErrCompressed
+	default:
+		return data[VARHDRSZ:size], nil
+	}
+}
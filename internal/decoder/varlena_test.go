@@ -0,0 +1,160 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestVarSizeAny(t *testing.T) {
+	tests := []struct {
+		order binary.ByteOrder
+		data  []byte
+		want  int
+	}{
+		{binary.LittleEndian, []byte{0x0D}, 6},                      // short
+		{binary.BigEndian, []byte{0x86}, 6},                         // short
+		{binary.LittleEndian, []byte{0x03}, 1},                      // short, empty
+		{binary.LittleEndian, []byte{0x24, 0x00, 0x00, 0x00}, 9},    // 4-byte
+		{binary.BigEndian, []byte{0x00, 0x00, 0x00, 0x09}, 9},       // 4-byte
+		{binary.LittleEndian, []byte{0x3A, 0x00, 0x00, 0x00}, 14},   // 4-byte, compressed
+		{binary.BigEndian, []byte{0x40, 0x00, 0x00, 0x0E}, 14},      // 4-byte, compressed
+		{binary.LittleEndian, []byte{0x01, VARTAG_ONDISK}, 18},      // TOAST pointer
+		{binary.BigEndian, []byte{0x80, VARTAG_ONDISK}, 18},         // TOAST pointer
+		{binary.LittleEndian, []byte{0x01, VARTAG_EXPANDED_RW}, 10}, // expanded object
+	}
+	for _, test := range tests {
+		if got, err := VarSizeAny(test.data, test.order); err != nil || got != test.want {
+			t.Errorf("%s % x: size %d (%v), want %d", test.order, test.data, got, err, test.want)
+		}
+	}
+
+	for _, data := range [][]byte{{}, {0x01}, {0x01, 7}, {0x01, 0x00}, {0x08, 0x00, 0x00, 0x00}, {0x00, 0x00}} {
+		if size, err := VarSizeAny(data, binary.LittleEndian); err == nil {
+			t.Errorf("% x: size %d, want an error", data, size)
+		}
+	}
+}
+
+func TestDecodeVarlena(t *testing.T) {
+	tests := []struct {
+		name  string
+		order binary.ByteOrder
+		data  []byte
+		want  string
+	}{
+		{"short", binary.LittleEndian, []byte{0x0D, 'h', 'e', 'l', 'l', 'o', 0xEE}, "hello"},
+		{"short", binary.BigEndian, []byte{0x86, 'h', 'e', 'l', 'l', 'o', 0xEE}, "hello"},
+		{"4-byte", binary.LittleEndian, []byte{0x24, 0x00, 0x00, 0x00, 'h', 'e', 'l', 'l', 'o'}, "hello"},
+		{"4-byte", binary.BigEndian, []byte{0x00, 0x00, 0x00, 0x09, 'h', 'e', 'l', 'l', 'o'}, "hello"},
+		{"pglz", binary.LittleEndian, []byte{
+			0x3A, 0x00, 0x00, 0x00, // 14 bytes, compressed
+			0x0C, 0x00, 0x00, 0x00, // 12 bytes raw, pglz
+			0x08, 'a', 'b', 'c', 0x06, 0x03, // 3 literals, 9 bytes from 3 back
+		}, "abcabcabcabc"},
+		{"pglz", binary.BigEndian, []byte{
+			0x40, 0x00, 0x00, 0x0E,
+			0x00, 0x00, 0x00, 0x0C,
+			0x08, 'a', 'b', 'c', 0x06, 0x03,
+		}, "abcabcabcabc"},
+		{"lz4", binary.LittleEndian, []byte{
+			0x52, 0x00, 0x00, 0x00, // 20 bytes, compressed
+			0x11, 0x00, 0x00, 0x40, // 17 bytes raw, lz4
+			0x35, 'a', 'b', 'c', 0x03, 0x00, // 3 literals, 9 bytes from 3 back
+			0x50, 'x', 'y', 'z', 'w', 'v', // 5 literals
+		}, "abcabcabcabcxyzwv"},
+		{"lz4", binary.BigEndian, []byte{
+			0x40, 0x00, 0x00, 0x14,
+			0x40, 0x00, 0x00, 0x11,
+			0x35, 'a', 'b', 'c', 0x03, 0x00,
+			0x50, 'x', 'y', 'z', 'w', 'v',
+		}, "abcabcabcabcxyzwv"},
+	}
+
+	for _, test := range tests {
+		v, err := DecodeVarlena(test.data, test.order)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.name, test.order, err)
+			continue
+		}
+		if v.Toast != nil || string(v.Data) != test.want {
+			t.Errorf("%s %s: %q, pointer %v; want %q", test.name, test.order, v.Data, v.Toast, test.want)
+		}
+	}
+}
+
+func TestDecodeVarlenaToastPointer(t *testing.T) {
+	want := ToastPointer{RawSize: 2004, ExtInfo: 1<<VARLENA_EXTSIZE_BITS | 512, ValueID: 16403, ToastRelID: 16389}
+	fixtures := map[binary.ByteOrder][]byte{
+		binary.LittleEndian: {
+			0x01, VARTAG_ONDISK,
+			0xD4, 0x07, 0x00, 0x00, // va_rawsize
+			0x00, 0x02, 0x00, 0x40, // va_extinfo
+			0x13, 0x40, 0x00, 0x00, // va_valueid
+			0x05, 0x40, 0x00, 0x00, // va_toastrelid
+		},
+		binary.BigEndian: {
+			0x80, VARTAG_ONDISK,
+			0x00, 0x00, 0x07, 0xD4,
+			0x40, 0x00, 0x02, 0x00,
+			0x00, 0x00, 0x40, 0x13,
+			0x00, 0x00, 0x40, 0x05,
+		},
+	}
+
+	for order, data := range fixtures {
+		v, err := DecodeVarlena(data, order)
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		if v.Toast == nil || *v.Toast != want || v.Data != nil {
+			t.Fatalf("%s: pointer %+v, data %q; want %+v", order, v.Toast, v.Data, want)
+		}
+		if !v.Toast.IsCompressed() || v.Toast.ExtSize() != 512 || v.Toast.CompressionMethod() != TOAST_LZ4_COMPRESSION_ID {
+			t.Errorf("%s: compressed %v, %d bytes stored, method %d",
+				order, v.Toast.IsCompressed(), v.Toast.ExtSize(), v.Toast.CompressionMethod())
+		}
+		if s := v.Toast.String(); s != "value 16403 in TOAST table 16389 (2000 bytes, 512 stored compressed)" {
+			t.Errorf("%s: %s", order, s)
+		}
+		if _, err := VarData(data, order); !errors.Is(err, ErrExternal) {
+			t.Errorf("%s: VarData returned %v, want ErrExternal", order, err)
+		}
+	}
+}
+
+func TestToastPointerValue(t *testing.T) {
+	order := binary.LittleEndian
+	plain := &ToastPointer{RawSize: 9, ExtInfo: 5}
+	if value, err := plain.Value([]byte("hello"), order); err != nil || string(value) != "hello" {
+		t.Errorf("uncompressed: %q (%v)", value, err)
+	}
+	if _, err := plain.Value([]byte("hell"), order); err == nil {
+		t.Errorf("uncompressed, short chunks: no error")
+	}
+
+	// The chunks of a compressed value start with va_tcinfo
+	compressed := &ToastPointer{RawSize: 16, ExtInfo: 10}
+	chunks := []byte{0x0C, 0x00, 0x00, 0x00, 0x08, 'a', 'b', 'c', 0x06, 0x03}
+	if value, err := compressed.Value(chunks, order); err != nil || string(value) != "abcabcabcabc" {
+		t.Errorf("compressed: %q (%v)", value, err)
+	}
+}
+
+func TestDecodeVarlenaErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", []byte{0x24, 0x00, 0x00, 0x00, 'h', 'e'}},
+		{"in-memory pointer", []byte{0x01, VARTAG_INDIRECT, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"compressed header only", []byte{0x1A, 0x00, 0x00, 0x00, 0x0C, 0x00}},
+		{"invalid compression method", []byte{0x3A, 0x00, 0x00, 0x00, 0x0C, 0x00, 0x00, 0x80, 0x08, 'a', 'b', 'c', 0x06, 0x03}},
+		{"corrupt pglz", []byte{0x3A, 0x00, 0x00, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x08, 'a', 'b', 'c', 0x06, 0x09}},
+	}
+	for _, test := range tests {
+		if v, err := DecodeVarlena(test.data, binary.LittleEndian); err == nil {
+			t.Errorf("%s: %+v, want an error", test.name, v)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package fileio
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// PagePool reuses page buffers of one block size.
+type PagePool struct {
+	pool      sync.Pool
+	blockSize int
+	stats     *IOStats
+}
+
+// NewPagePool creates a new PagePool for pages of blockSize bytes. Pool hits
+// and misses are counted in stats, which may be nil.
+func NewPagePool(blockSize int, stats *IOStats) *PagePool {
+	p := &PagePool{
+		blockSize: blockSize,
+		stats:     stats,
+	}
+	p.pool.New = func() interface{} {
+		p.stats.countPoolMiss()
+		return make([]byte, p.blockSize)
+	}
+	return p
+}
+
+// Get returns a page buffer of the pool's block size. Its contents are undefined.
+func (p *PagePool) Get() []byte {
+	p.stats.countPoolGet()
+	return p.pool.Get().([]byte)[:p.blockSize]
+}
+
+// Put returns a page buffer to the pool. Buffers of another size are ignored.
+func (p *PagePool) Put(page []byte) {
+	if cap(page) != p.blockSize {
+		return
+	}
+	p.pool.Put(page[:p.blockSize])
+}
+
+// PageReleaser is implemented by FileReaders whose pages come from a
+// PagePool. A caller that no longer uses a page, nor anything sliced from it,
+// can hand it back with ReleasePage so that the buffer is reused.
+type PageReleaser interface {
+	ReleasePage(page []byte)
+}
+
+// IOStats counts the reads done by FileReaders. The counters are updated
+// atomically, so one IOStats can be shared by concurrent readers.
+type IOStats struct {
+	PagesRead     int64 // pages returned by ReadPage
+	ReadAheadHits int64 // pages served from the read-ahead buffer
+	ReadCalls     int64 // read system calls
+	BytesRead     int64 // bytes read from files
+	PoolGets      int64 // page buffers taken from the pool
+	PoolMisses    int64 // page buffers the pool had to allocate
+}
+
+// Snapshot returns a consistent copy of the counters.
+func (s *IOStats) Snapshot() IOStats {
+	return IOStats{
+		PagesRead:     atomic.LoadInt64(&s.PagesRead),
+		ReadAheadHits: atomic.LoadInt64(&s.ReadAheadHits),
+		ReadCalls:     atomic.LoadInt64(&s.ReadCalls),
+		BytesRead:     atomic.LoadInt64(&s.BytesRead),
+		PoolGets:      atomic.LoadInt64(&s.PoolGets),
+		PoolMisses:    atomic.LoadInt64(&s.PoolMisses),
+	}
+}
+
+// countPage counts a page returned by ReadPage. Like the other count
+// methods, it does nothing on a nil IOStats.
+func (s *IOStats) countPage() {
+	if s != nil {
+		atomic.AddInt64(&s.PagesRead, 1)
+	}
+}
+
+// countReadAheadHit counts a page served from the read-ahead buffer.
+func (s *IOStats) countReadAheadHit() {
+	if s != nil {
+		atomic.AddInt64(&s.ReadAheadHits, 1)
+	}
+}
+
+// countRead counts a read system call that returned n bytes.
+func (s *IOStats) countRead(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.ReadCalls, 1)
+		atomic.AddInt64(&s.BytesRead, int64(n))
+	}
+}
+
+// countPoolGet counts a page buffer taken from the pool.
+func (s *IOStats) countPoolGet() {
+	if s != nil {
+		atomic.AddInt64(&s.PoolGets, 1)
+	}
+}
+
+// countPoolMiss counts a page buffer the pool had to allocate.
+func (s *IOStats) countPoolMiss() {
+	if s != nil {
+		atomic.AddInt64(&s.PoolMisses, 1)
+	}
+}
+
+// ReadAheadHitRate returns the fraction of pages served from the read-ahead buffer.
+func (s IOStats) ReadAheadHitRate() float64 {
+	if s.PagesRead == 0 {
+		return 0
+	}
+	return float64(s.ReadAheadHits) / float64(s.PagesRead)
+}
+
+// PoolHitRate returns the fraction of page buffers that were reused.
+func (s IOStats) PoolHitRate() float64 {
+	if s.PoolGets == 0 {
+		return 0
+	}
+	return float64(s.PoolGets-s.PoolMisses) / float64(s.PoolGets)
+}
+
+// String returns a one-line summary of the counters.
+func (s IOStats) String() string {
+	return fmt.Sprintf("%d pages, %d bytes in %d reads, read-ahead hit rate %.1f%%, buffer pool hit rate %.1f%%",
+		s.PagesRead, s.BytesRead, s.ReadCalls, 100*s.ReadAheadHitRate(), 100*s.PoolHitRate())
+}
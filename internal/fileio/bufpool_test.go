@@ -0,0 +1,121 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPagePool(t *testing.T) {
+	stats := &IOStats{}
+	pool := NewPagePool(testBlockSize, stats)
+
+	page := pool.Get()
+	if len(page) != testBlockSize {
+		t.Fatalf("page of %d bytes", len(page))
+	}
+	pool.Put(page[:10])
+	if page := pool.Get(); len(page) != testBlockSize {
+		t.Errorf("reused page of %d bytes", len(page))
+	}
+
+	// Buffers of another size are not pooled
+	pool.Put(make([]byte, 2*testBlockSize))
+	if page := pool.Get(); len(page) != testBlockSize || cap(page) != testBlockSize {
+		t.Errorf("page of %d bytes, capacity %d", len(page), cap(page))
+	}
+
+	s := stats.Snapshot()
+	if s.PoolGets != 3 || s.PoolMisses < 2 || s.PoolMisses > 3 {
+		t.Errorf("%d gets, %d misses", s.PoolGets, s.PoolMisses)
+	}
+
+	// A pool without statistics works all the same
+	if page := NewPagePool(testBlockSize, nil).Get(); len(page) != testBlockSize {
+		t.Errorf("page of %d bytes without statistics", len(page))
+	}
+}
+
+func TestReadAhead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	writeBlocks(t, path, 0, 10)
+
+	stats := &IOStats{}
+	r := NewPgFileReader(testParams())
+	r.SetIOOptions(IOOptions{ReadAhead: 4, Pool: NewPagePool(testBlockSize, stats), Stats: stats})
+	if err := r.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Sequential reads take one system call per four blocks
+	for block := int64(0); block < 10; block++ {
+		page, err := r.ReadPage(block)
+		if err != nil || !bytes.Equal(page, bytes.Repeat([]byte{byte(block)}, testBlockSize)) {
+			t.Fatalf("block %d: % .4x (%v)", block, page, err)
+		}
+		r.ReleasePage(page)
+	}
+	s := stats.Snapshot()
+	if s.PagesRead != 10 || s.ReadCalls != 3 || s.ReadAheadHits != 7 || s.BytesRead != 10*testBlockSize || s.PoolGets != 10 {
+		t.Errorf("sequential: %+v", s)
+	}
+
+	// Other reads take one system call per block, and keep the buffer
+	for _, block := range []int64{2, 6, 9} {
+		if page, err := r.ReadPage(block); err != nil || page[0] != byte(block) {
+			t.Errorf("block %d: % .4x (%v)", block, page, err)
+		}
+	}
+	s = stats.Snapshot()
+	if s.PagesRead != 13 || s.ReadCalls != 5 || s.ReadAheadHits != 8 || s.BytesRead != 12*testBlockSize {
+		t.Errorf("random: %+v", s)
+	}
+}
+
+func TestReadPageReleasesOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	writeBlocks(t, path, 0, 4)
+
+	for _, readAhead := range []int{1, 4} {
+		stats := &IOStats{}
+		r := NewPgFileReader(testParams())
+		r.SetIOOptions(IOOptions{ReadAhead: readAhead, Pool: NewPagePool(testBlockSize, stats), Stats: stats})
+		if err := r.Open(path); err != nil {
+			t.Fatal(err)
+		}
+
+		// The file shrinks after it is opened, so every read comes up short
+		if err := os.Truncate(path, 0); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			if page, err := r.ReadPage(int64(i % 4)); err == nil {
+				t.Fatalf("read-ahead %d: block %d read after truncation: % .4x", readAhead, i%4, page)
+			}
+		}
+		r.Close()
+
+		// The buffers of failed reads go back to the pool; the pool may
+		// still drop some
+		if s := stats.Snapshot(); s.PoolGets != 20 || s.PoolMisses >= s.PoolGets {
+			t.Errorf("read-ahead %d: %d gets, %d misses", readAhead, s.PoolGets, s.PoolMisses)
+		}
+		writeBlocks(t, path, 0, 4)
+	}
+}
+
+func TestIOStats(t *testing.T) {
+	s := IOStats{PagesRead: 8, ReadAheadHits: 6, ReadCalls: 2, BytesRead: 65536, PoolGets: 8, PoolMisses: 2}
+	if s.ReadAheadHitRate() != 0.75 || s.PoolHitRate() != 0.75 {
+		t.Errorf("read-ahead hit rate %f, pool hit rate %f", s.ReadAheadHitRate(), s.PoolHitRate())
+	}
+	want := "8 pages, 65536 bytes in 2 reads, read-ahead hit rate 75.0%, buffer pool hit rate 75.0%"
+	if s.String() != want {
+		t.Errorf("%q, want %q", s.String(), want)
+	}
+	if empty := (IOStats{}); empty.ReadAheadHitRate() != 0 || empty.PoolHitRate() != 0 {
+		t.Errorf("empty: read-ahead hit rate %f, pool hit rate %f", empty.ReadAheadHitRate(), empty.PoolHitRate())
+	}
+}
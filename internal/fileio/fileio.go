@@ -4,6 +4,7 @@ package fileio
 import (
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/wublabdubdub/pdu/pkg/pgtypes"
 )
@@ -31,18 +32,36 @@ type FileReader interface {
 
 // PgFileReader implements FileReader for PostgreSQL data files.
 type PgFileReader struct {
-	file     *os.File
-	fileSize int64
+	file      *os.File
+	fileSize  int64
+	blockSize int64
+	io        IOOptions
+
+	// Read-ahead buffer holding windowPages blocks from windowStart
+	mu          sync.Mutex
+	window      []byte
+	windowStart int64
+	windowPages int64
 }
 
-// NewPgFileReader creates a new PgFileReader instance.
-func NewPgFileReader() *PgFileReader {
+// NewPgFileReader creates a new PgFileReader instance that reads pages of the
+// block size given in params.
+func NewPgFileReader(params pgtypes.StorageParams) *PgFileReader {
 	return &PgFileReader{
-		file:     nil,
-		fileSize: 0,
+		file:      nil,
+		fileSize:  0,
+		blockSize: int64(params.BlockSize),
 	}
 }
 
+// SetIOOptions sets the page pool, read-ahead, statistics and throttle of the reader.
+// The I/O mode is ignored.
+func (r *PgFileReader) SetIOOptions(opts IOOptions) {
+	r.io = opts
+	r.window = nil
+	r.windowPages = 0
+}
+
 // Open opens a file for reading.
 func (r *PgFileReader) Open(path string) error {
 	// Check if file exists
@@ -51,7 +70,7 @@ func (r *PgFileReader) Open(path string) error {
 	}
 
 	// Open the file
-	file, err := os.Open(path)
+	file, err := OpenReadOnly(path)
 	if err != nil {
 		return err
 	}
@@ -66,6 +85,7 @@ func (r *PgFileReader) Open(path string) error {
 	// Set file and file size
 	r.file = file
 	r.fileSize = fileInfo.Size()
+	r.windowPages = 0
 
 	return nil
 }
@@ -81,34 +101,105 @@ func (r *PgFileReader) Close() error {
 // ReadPage reads a single page from the file.
 func (r *PgFileReader) ReadPage(pageNumber int64) ([]byte, error) {
 	// Calculate offset
-	offset := pageNumber * int64(pgtypes.BLCKSZ)
+	offset := pageNumber * r.blockSize
 
 	// Check if offset is within file bounds
 	if offset >= r.fileSize {
 		return nil, os.ErrInvalid
 	}
 
-	// Calculate bytes to read (may be less than BLCKSZ for last page)
-	bytesToRead := int64(pgtypes.BLCKSZ)
+	// Calculate bytes to read (may be less than the block size for last page)
+	bytesToRead := r.blockSize
 	if offset+bytesToRead > r.fileSize {
 		bytesToRead = r.fileSize - offset
 	}
 
 	// Allocate buffer
-	buffer := make([]byte, bytesToRead)
+	buffer := r.newBuffer(bytesToRead)
+	r.io.Stats.countPage()
+
+	// Read the page, ahead of time if reading sequentially
+	var err error
+	if r.io.ReadAhead > 1 {
+		err = r.readAhead(pageNumber, buffer)
+	} else {
+		err = r.readAt(buffer, offset)
+	}
+	if err != nil {
+		r.ReleasePage(buffer)
+		return nil, err
+	}
+
+	return buffer, nil
+}
 
-	// Read the page
+// ReleasePage hands a page returned by ReadPage back to the page pool.
+func (r *PgFileReader) ReleasePage(page []byte) {
+	if r.io.Pool != nil {
+		r.io.Pool.Put(page)
+	}
+}
+
+// newBuffer returns a buffer for a page of n bytes, from the pool if possible.
+func (r *PgFileReader) newBuffer(n int64) []byte {
+	if r.io.Pool != nil && n == r.blockSize {
+		return r.io.Pool.Get()
+	}
+	return make([]byte, n)
+}
+
+// readAhead copies a page from the read-ahead buffer into buffer. When the
+// page follows the buffered ones, the buffer is refilled with the next
+// ReadAhead blocks in one read; other pages are read on their own.
+func (r *PgFileReader) readAhead(pageNumber int64, buffer []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	offset := pageNumber * r.blockSize
+	inWindow := pageNumber >= r.windowStart && pageNumber < r.windowStart+r.windowPages
+	switch {
+	case inWindow:
+		r.io.Stats.countReadAheadHit()
+	case r.windowPages == 0 || pageNumber == r.windowStart+r.windowPages:
+		// Refill the buffer, up to the end of the file
+		if r.window == nil {
+			r.window = make([]byte, int64(r.io.ReadAhead)*r.blockSize)
+		}
+		n := int64(len(r.window))
+		if offset+n > r.fileSize {
+			n = r.fileSize - offset
+		}
+		r.windowPages = 0
+		if err := r.readAt(r.window[:n], offset); err != nil {
+			return err
+		}
+		r.windowStart = pageNumber
+		r.windowPages = (n + r.blockSize - 1) / r.blockSize
+	default:
+		// Random access
+		return r.readAt(buffer, offset)
+	}
+
+	start := (pageNumber - r.windowStart) * r.blockSize
+	copy(buffer, r.window[start:start+int64(len(buffer))])
+	return nil
+}
+
+// readAt fills buffer from the file at offset, failing on short reads.
+func (r *PgFileReader) readAt(buffer []byte, offset int64) error {
+	r.io.Throttle.Wait(len(buffer))
 	n, err := r.file.ReadAt(buffer, offset)
+	r.io.Stats.countRead(n)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Check if we read the expected number of bytes
-	if int64(n) != bytesToRead {
-		return nil, os.ErrInvalid
+	if n != len(buffer) {
+		return os.ErrInvalid
 	}
 
-	return buffer, nil
+	return nil
 }
 
 // ReadBytes reads a specified number of bytes from the file at a given offset.
@@ -122,16 +213,10 @@ func (r *PgFileReader) ReadBytes(offset int64, length int) ([]byte, error) {
 	buffer := make([]byte, length)
 
 	// Read the bytes
-	n, err := r.file.ReadAt(buffer, offset)
-	if err != nil {
+	if err := r.readAt(buffer, offset); err != nil {
 		return nil, err
 	}
 
-	// Check if we read the expected number of bytes
-	if n != length {
-		return nil, os.ErrInvalid
-	}
-
 	return buffer, nil
 }
 
@@ -146,8 +231,8 @@ func (r *PgFileReader) GetPageCount() int64 {
 		return 0
 	}
 
-	pageCount := r.fileSize / int64(pgtypes.BLCKSZ)
-	if r.fileSize%int64(pgtypes.BLCKSZ) > 0 {
+	pageCount := r.fileSize / r.blockSize
+	if r.fileSize%r.blockSize > 0 {
 		pageCount++
 	}
 
@@ -170,9 +255,10 @@ func FindDataFiles(dir string) ([]string, error) {
 		}
 
 		// Check if the file is a PostgreSQL data file
-		// Data files have numeric names without extensions
+		// Data files are named after the relfilenode, optionally followed by
+		// a fork suffix (_fsm, _vm, _init) and a segment number (.1, .2, ...)
 		name := info.Name()
-		if IsNumeric(name) {
+		if _, ok := ParseRelationFileName(name); ok {
 			dataFiles = append(dataFiles, path)
 		}
 
@@ -0,0 +1,183 @@
+package fileio
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// IOMode selects how data files are read.
+type IOMode int
+
+// I/O modes
+const (
+	IOModePread IOMode = iota // read every page into a new buffer with pread
+	IOModeMmap                // map files into memory and return pages without copying
+)
+
+// ParseIOMode parses an I/O mode name, "pread" or "mmap". An empty name
+// selects pread.
+func ParseIOMode(name string) (IOMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "pread":
+		return IOModePread, nil
+	case "mmap":
+		return IOModeMmap, nil
+	default:
+		return 0, fmt.Errorf("unknown I/O mode %q (use pread or mmap)", name)
+	}
+}
+
+// String returns the name of the I/O mode.
+func (m IOMode) String() string {
+	if m == IOModeMmap {
+		return "mmap"
+	}
+	return "pread"
+}
+
+// IOOptions controls how FileReaders read data files. The zero value reads
+// every page with its own pread into a new buffer.
+type IOOptions struct {
+	Mode      IOMode    // pread or mmap
+	ReadAhead int       // blocks read per system call when reading sequentially with pread
+	Pool      *PagePool // page buffers for pread, or nil to allocate every page
+	Stats     *IOStats  // counters to update, or nil
+	Throttle  *Throttle // rate limits shared by all readers, or nil
+}
+
+// NewFileReader creates a FileReader for a single data file using the given
+// I/O options.
+func NewFileReader(params pgtypes.StorageParams, opts IOOptions) FileReader {
+	if opts.Mode == IOModeMmap {
+		reader := NewMmapFileReader(params)
+		reader.SetIOOptions(opts)
+		return reader
+	}
+	reader := NewPgFileReader(params)
+	reader.SetIOOptions(opts)
+	return reader
+}
+
+// MmapFileReader implements FileReader by mapping the file into memory.
+// ReadPage and ReadBytes return slices of the mapping instead of copies, so
+// they must not be modified and are only valid until Close. Files that
+// cannot be mapped, such as empty files or files on filesystems without mmap
+// support, are read with pread instead.
+//
+// A mapped file that is truncated while it is being read, for example by a
+// running server, makes the process fail with SIGBUS; use pread on live
+// clusters.
+type MmapFileReader struct {
+	pread *PgFileReader // used for the file size, and for reading if data is nil
+	data  []byte        // the mapped file
+}
+
+// NewMmapFileReader creates a new MmapFileReader instance that reads pages of
+// the block size given in params.
+func NewMmapFileReader(params pgtypes.StorageParams) *MmapFileReader {
+	return &MmapFileReader{
+		pread: NewPgFileReader(params),
+	}
+}
+
+// SetIOOptions sets the statistics and throttle of the reader, and the
+// read-ahead used if the file cannot be mapped. Mapped pages do not need a
+// page pool.
+func (r *MmapFileReader) SetIOOptions(opts IOOptions) {
+	r.pread.SetIOOptions(IOOptions{
+		ReadAhead: opts.ReadAhead,
+		Stats:     opts.Stats,
+		Throttle:  opts.Throttle,
+	})
+}
+
+// Open opens and maps a file.
+func (r *MmapFileReader) Open(path string) error {
+	if err := r.pread.Open(path); err != nil {
+		return err
+	}
+
+	// Map the file, falling back to pread if that is not possible
+	r.data = nil
+	if r.pread.fileSize == 0 {
+		return nil
+	}
+	data, err := mmapFile(r.pread.file, r.pread.fileSize)
+	if err != nil {
+		// Keep reading with pread
+		return nil
+	}
+	r.data = data
+
+	// The mapping stays valid without the file descriptor
+	err = r.pread.file.Close()
+	r.pread.file = nil
+	return err
+}
+
+// Mapped reports whether the file is mapped, rather than read with pread.
+func (r *MmapFileReader) Mapped() bool {
+	return r.data != nil
+}
+
+// Close unmaps or closes the file.
+func (r *MmapFileReader) Close() error {
+	if r.data == nil {
+		return r.pread.Close()
+	}
+	err := munmapFile(r.data)
+	r.data = nil
+	return err
+}
+
+// ReadPage returns a single page of the file.
+func (r *MmapFileReader) ReadPage(pageNumber int64) ([]byte, error) {
+	if r.data == nil {
+		return r.pread.ReadPage(pageNumber)
+	}
+
+	// Check if offset is within file bounds
+	offset := pageNumber * r.pread.blockSize
+	if pageNumber < 0 || offset >= r.pread.fileSize {
+		return nil, os.ErrInvalid
+	}
+
+	// The last page may be short
+	end := offset + r.pread.blockSize
+	if end > r.pread.fileSize {
+		end = r.pread.fileSize
+	}
+	r.pread.io.Stats.countPage()
+	r.pread.io.Throttle.Wait(int(end - offset))
+
+	return r.data[offset:end:end], nil
+}
+
+// ReadBytes returns a specified number of bytes of the file at a given offset.
+func (r *MmapFileReader) ReadBytes(offset int64, length int) ([]byte, error) {
+	if r.data == nil {
+		return r.pread.ReadBytes(offset, length)
+	}
+
+	// Check if offset and length are within file bounds
+	end := offset + int64(length)
+	if offset < 0 || int64(length) <= 0 || end > r.pread.fileSize {
+		return nil, os.ErrInvalid
+	}
+	r.pread.io.Throttle.Wait(length)
+
+	return r.data[offset:end:end], nil
+}
+
+// GetFileSize returns the size of the file.
+func (r *MmapFileReader) GetFileSize() int64 {
+	return r.pread.GetFileSize()
+}
+
+// GetPageCount returns the number of pages in the file.
+func (r *MmapFileReader) GetPageCount() int64 {
+	return r.pread.GetPageCount()
+}
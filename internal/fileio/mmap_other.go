@@ -0,0 +1,21 @@
+//go:build !unix
+
+package fileio
+
+import (
+	"errors"
+	"os"
+)
+
+// errMmapUnsupported is returned by mmapFile on platforms without mmap.
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// mmapFile reports that files cannot be mapped, so that pread is used.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// munmapFile is never called, as nothing is ever mapped.
+func munmapFile(data []byte) error {
+	return errMmapUnsupported
+}
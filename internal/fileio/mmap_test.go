@@ -0,0 +1,101 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMmapFileReader(t *testing.T) {
+	// Two and a half blocks
+	path := filepath.Join(t.TempDir(), "16384")
+	writeBlocks(t, path, 0, 3)
+	if err := os.Truncate(path, 2*testBlockSize+testBlockSize/2); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMmapFileReader(testParams())
+	if err := r.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if (runtime.GOOS == "linux" || runtime.GOOS == "darwin") && !r.Mapped() {
+		t.Errorf("file is not mapped")
+	}
+	if r.GetFileSize() != 2*testBlockSize+testBlockSize/2 || r.GetPageCount() != 3 {
+		t.Errorf("%d bytes, %d pages", r.GetFileSize(), r.GetPageCount())
+	}
+
+	// Pages match what pread returns, up to the short last page
+	pread := NewPgFileReader(testParams())
+	if err := pread.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer pread.Close()
+	for block := int64(0); block < 3; block++ {
+		page, err := r.ReadPage(block)
+		want, wantErr := pread.ReadPage(block)
+		if (err != nil) != (wantErr != nil) || !bytes.Equal(page, want) {
+			t.Errorf("block %d: % .4x (%v), pread returned % .4x (%v)", block, page, err, want, wantErr)
+		}
+	}
+	for _, block := range []int64{-1, 3} {
+		if _, err := r.ReadPage(block); err == nil {
+			t.Errorf("block %d: no error", block)
+		}
+	}
+
+	// Slices cannot be appended to over the following data
+	page, _ := r.ReadPage(0)
+	if cap(page) != testBlockSize {
+		t.Errorf("page has capacity %d", cap(page))
+	}
+
+	data, err := r.ReadBytes(testBlockSize-2, 4)
+	if err != nil || !bytes.Equal(data, []byte{0, 0, 1, 1}) || cap(data) != 4 {
+		t.Errorf("read across blocks 0 and 1: % x (%v)", data, err)
+	}
+	for _, length := range []int{0, 2 * testBlockSize} {
+		if _, err := r.ReadBytes(testBlockSize, length); err == nil {
+			t.Errorf("read of %d bytes: no error", length)
+		}
+	}
+}
+
+func TestMmapFileReaderFallback(t *testing.T) {
+	// Empty files cannot be mapped, and are read with pread
+	path := filepath.Join(t.TempDir(), "16384")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := NewMmapFileReader(testParams())
+	if err := r.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if r.Mapped() || r.GetFileSize() != 0 || r.GetPageCount() != 0 {
+		t.Errorf("mapped %v, %d bytes, %d pages", r.Mapped(), r.GetFileSize(), r.GetPageCount())
+	}
+	if _, err := r.ReadPage(0); err == nil {
+		t.Errorf("block 0 of an empty file: no error")
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+
+	if err := NewMmapFileReader(testParams()).Open(path + "_vm"); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestParseIOMode(t *testing.T) {
+	for name, want := range map[string]IOMode{"": IOModePread, "pread": IOModePread, " MMAP": IOModeMmap} {
+		if mode, err := ParseIOMode(name); err != nil || mode != want {
+			t.Errorf("%q: %s (%v), want %s", name, mode, err, want)
+		}
+	}
+	if mode, err := ParseIOMode("direct"); err == nil {
+		t.Errorf("direct: %s, want an error", mode)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package fileio
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps size bytes of file read-only into memory.
+func mmapFile(file *os.File, size int64) ([]byte, error) {
+	// Files larger than the address space cannot be mapped
+	if int64(int(size)) != size {
+		return nil, errors.New("file too large to map")
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmapFile removes a mapping created by mmapFile.
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,23 @@
+package fileio
+
+import (
+	"io"
+	"os"
+)
+
+// OpenReadOnly opens a file of the data directory for reading only. PDU
+// never writes to a data directory; every file in it is opened here.
+func OpenReadOnly(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY, 0)
+}
+
+// ReadFile reads a whole file of the data directory, opening it read-only.
+func ReadFile(path string) ([]byte, error) {
+	file, err := OpenReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
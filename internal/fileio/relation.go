@@ -0,0 +1,487 @@
+package fileio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// ForkNumber identifies one of the physical files a relation is made of.
+type ForkNumber int
+
+// Relation forks
+const (
+	MAIN_FORKNUM          ForkNumber = iota // table or index data
+	FSM_FORKNUM                             // free space map
+	VISIBILITYMAP_FORKNUM                   // visibility map
+	INIT_FORKNUM                            // init fork of unlogged relations
+)
+
+// forkNames are the file name suffixes of each fork, indexed by ForkNumber.
+var forkNames = []string{"main", "fsm", "vm", "init"}
+
+// AllForks lists every fork in ForkNumber order.
+var AllForks = []ForkNumber{MAIN_FORKNUM, FSM_FORKNUM, VISIBILITYMAP_FORKNUM, INIT_FORKNUM}
+
+// String returns the name PostgreSQL uses for the fork.
+func (f ForkNumber) String() string {
+	if f < 0 || int(f) >= len(forkNames) {
+		return fmt.Sprintf("fork(%d)", int(f))
+	}
+	return forkNames[f]
+}
+
+// ErrSegmentMissing is returned when a block lies in a segment file that does
+// not exist or ends before the block.
+var ErrSegmentMissing = errors.New("segment file missing or short")
+
+// Segment problem kinds
+const (
+	SegmentMissing = "missing" // segment file does not exist but a later one does
+	SegmentShort   = "short"   // non-final segment is smaller than a full segment
+	SegmentPartial = "partial" // segment size is not a multiple of the block size
+)
+
+// SegmentProblem describes a missing or damaged segment file of a relation fork.
+type SegmentProblem struct {
+	Fork     ForkNumber // fork the segment belongs to
+	Segment  int        // segment number (0 for the file without suffix)
+	Path     string     // path of the segment file
+	Kind     string     // one of SegmentMissing, SegmentShort, SegmentPartial
+	Size     int64      // actual size in bytes
+	Expected int64      // expected size in bytes
+}
+
+// String returns a human-readable description of the problem.
+func (p SegmentProblem) String() string {
+	switch p.Kind {
+	case SegmentMissing:
+		return fmt.Sprintf("%s: segment %d is missing", p.Path, p.Segment)
+	case SegmentShort:
+		return fmt.Sprintf("%s: segment %d is short: %d of %d bytes", p.Path, p.Segment, p.Size, p.Expected)
+	default:
+		return fmt.Sprintf("%s: segment %d ends with a partial block: %d bytes", p.Path, p.Segment, p.Size)
+	}
+}
+
+// SegmentedFileReader implements FileReader over all segment files of one
+// relation fork. Block N of the fork lives in segment N/RelSegSize, so the
+// segments form a single logical block address space.
+type SegmentedFileReader struct {
+	fork       ForkNumber
+	params     pgtypes.StorageParams
+	segments   []FileReader // indexed by segment number, nil when missing
+	paths      []string
+	problems   []SegmentProblem
+	blockSize  int64
+	relsegSize int64
+	io         IOOptions
+}
+
+// NewSegmentedFileReader creates a new SegmentedFileReader for the given fork,
+// using the block and segment sizes in params.
+func NewSegmentedFileReader(fork ForkNumber, params pgtypes.StorageParams) *SegmentedFileReader {
+	return &SegmentedFileReader{
+		fork:       fork,
+		params:     params,
+		blockSize:  int64(params.BlockSize),
+		relsegSize: int64(params.RelSegSize),
+	}
+}
+
+// SetIOOptions selects how the segment files are read. It takes effect on the next Open.
+func (r *SegmentedFileReader) SetIOOptions(opts IOOptions) {
+	r.io = opts
+}
+
+// ReleasePage hands a page returned by ReadPage back to the page pool.
+func (r *SegmentedFileReader) ReleasePage(page []byte) {
+	// Mapped pages are not buffers
+	if r.io.Mode == IOModePread && r.io.Pool != nil {
+		r.io.Pool.Put(page)
+	}
+}
+
+// Open opens every segment of the fork. The path is the relfilenode path
+// without fork or segment suffix, e.g. base/5/16384.
+func (r *SegmentedFileReader) Open(path string) error {
+	// Find all segment files of this fork
+	segPaths, err := findSegments(path, r.fork)
+	if err != nil {
+		return err
+	}
+	if len(segPaths) == 0 {
+		return &os.PathError{Op: "open", Path: ForkPath(path, r.fork), Err: os.ErrNotExist}
+	}
+
+	// Open the segments, leaving gaps for missing ones
+	last := segPaths[len(segPaths)-1].segno
+	r.segments = make([]FileReader, last+1)
+	r.paths = make([]string, last+1)
+	r.problems = nil
+	for segno := 0; segno <= last; segno++ {
+		r.paths[segno] = SegmentPath(path, r.fork, segno)
+	}
+	for _, seg := range segPaths {
+		reader := NewFileReader(r.params, r.io)
+		if err := reader.Open(seg.path); err != nil {
+			r.Close()
+			return err
+		}
+		r.segments[seg.segno] = reader
+	}
+
+	// Record missing, short and partial segments
+	segBytes := r.relsegSize * r.blockSize
+	for segno, seg := range r.segments {
+		switch {
+		case seg == nil:
+			r.problems = append(r.problems, SegmentProblem{
+				Fork: r.fork, Segment: segno, Path: r.paths[segno], Kind: SegmentMissing, Expected: segBytes,
+			})
+		case segno < last && seg.GetFileSize() < segBytes:
+			r.problems = append(r.problems, SegmentProblem{
+				Fork: r.fork, Segment: segno, Path: r.paths[segno], Kind: SegmentShort,
+				Size: seg.GetFileSize(), Expected: segBytes,
+			})
+		case seg.GetFileSize()%r.blockSize != 0:
+			r.problems = append(r.problems, SegmentProblem{
+				Fork: r.fork, Segment: segno, Path: r.paths[segno], Kind: SegmentPartial,
+				Size: seg.GetFileSize(), Expected: segBytes,
+			})
+		}
+	}
+
+	return nil
+}
+
+// Close closes all segment files.
+func (r *SegmentedFileReader) Close() error {
+	var firstErr error
+	for _, seg := range r.segments {
+		if seg == nil {
+			continue
+		}
+		if err := seg.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.segments = nil
+	return firstErr
+}
+
+// ReadPage reads a single page from the fork by logical block number.
+func (r *SegmentedFileReader) ReadPage(pageNumber int64) ([]byte, error) {
+	// Locate the segment holding the block
+	segno := pageNumber / r.relsegSize
+	if pageNumber < 0 || segno >= int64(len(r.segments)) {
+		return nil, os.ErrInvalid
+	}
+	seg := r.segments[segno]
+	if seg == nil {
+		return nil, fmt.Errorf("block %d: %s: %w", pageNumber, r.paths[segno], ErrSegmentMissing)
+	}
+
+	// Read the block from within the segment
+	segBlock := pageNumber % r.relsegSize
+	if segBlock*r.blockSize >= seg.GetFileSize() {
+		return nil, fmt.Errorf("block %d: %s: %w", pageNumber, r.paths[segno], ErrSegmentMissing)
+	}
+	return seg.ReadPage(segBlock)
+}
+
+// ReadBytes reads a specified number of bytes at a logical offset, crossing
+// segment boundaries as needed.
+func (r *SegmentedFileReader) ReadBytes(offset int64, length int) ([]byte, error) {
+	// Check if offset and length are within the fork bounds
+	if offset < 0 || int64(length) <= 0 || offset+int64(length) > r.GetFileSize() {
+		return nil, os.ErrInvalid
+	}
+
+	buffer := make([]byte, 0, length)
+	segBytes := r.relsegSize * r.blockSize
+	for len(buffer) < length {
+		// Locate the segment holding the current offset
+		segno := offset / segBytes
+		seg := r.segments[segno]
+		if seg == nil {
+			return nil, fmt.Errorf("offset %d: %s: %w", offset, r.paths[segno], ErrSegmentMissing)
+		}
+
+		// Read up to the end of this segment
+		segOffset := offset % segBytes
+		chunk := length - len(buffer)
+		if segOffset+int64(chunk) > segBytes {
+			chunk = int(segBytes - segOffset)
+		}
+		if segOffset+int64(chunk) > seg.GetFileSize() {
+			return nil, fmt.Errorf("offset %d: %s: %w", offset, r.paths[segno], ErrSegmentMissing)
+		}
+		data, err := seg.ReadBytes(segOffset, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer = append(buffer, data...)
+		offset += int64(chunk)
+	}
+
+	return buffer, nil
+}
+
+// GetFileSize returns the logical size of the fork: the offset of the last
+// segment plus its size.
+func (r *SegmentedFileReader) GetFileSize() int64 {
+	if len(r.segments) == 0 {
+		return 0
+	}
+	last := len(r.segments) - 1
+	return int64(last)*r.relsegSize*r.blockSize + r.segments[last].GetFileSize()
+}
+
+// GetPageCount returns the number of blocks in the logical address space of the fork.
+func (r *SegmentedFileReader) GetPageCount() int64 {
+	size := r.GetFileSize()
+	pageCount := size / r.blockSize
+	if size%r.blockSize > 0 {
+		pageCount++
+	}
+	return pageCount
+}
+
+// Fork returns the fork the reader covers.
+func (r *SegmentedFileReader) Fork() ForkNumber {
+	return r.fork
+}
+
+// SegmentCount returns the number of segments in the fork, including missing ones.
+func (r *SegmentedFileReader) SegmentCount() int {
+	return len(r.segments)
+}
+
+// Problems returns the missing, short or partial segments found when opening the fork.
+func (r *SegmentedFileReader) Problems() []SegmentProblem {
+	return r.problems
+}
+
+// RelationReader gives access to all forks of a relation.
+type RelationReader struct {
+	path   string
+	params pgtypes.StorageParams
+	forks  map[ForkNumber]*SegmentedFileReader
+	io     IOOptions
+}
+
+// NewRelationReader creates a new RelationReader instance.
+func NewRelationReader(params pgtypes.StorageParams) *RelationReader {
+	return &RelationReader{
+		params: params,
+		forks:  make(map[ForkNumber]*SegmentedFileReader),
+	}
+}
+
+// SetIOOptions selects how the relation files are read. It takes effect on the next Open.
+func (r *RelationReader) SetIOOptions(opts IOOptions) {
+	r.io = opts
+}
+
+// Open opens every fork that exists for the relfilenode path. The main fork is required.
+func (r *RelationReader) Open(path string) error {
+	r.path = path
+	for _, fork := range AllForks {
+		reader := NewSegmentedFileReader(fork, r.params)
+		reader.SetIOOptions(r.io)
+		err := reader.Open(path)
+		if errors.Is(err, os.ErrNotExist) && fork != MAIN_FORKNUM {
+			continue
+		}
+		if err != nil {
+			r.Close()
+			return err
+		}
+		r.forks[fork] = reader
+	}
+	return nil
+}
+
+// Close closes all forks.
+func (r *RelationReader) Close() error {
+	var firstErr error
+	for fork, reader := range r.forks {
+		if err := reader.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.forks, fork)
+	}
+	return firstErr
+}
+
+// Path returns the relfilenode path the reader was opened with.
+func (r *RelationReader) Path() string {
+	return r.path
+}
+
+// Fork returns the reader for a fork, or nil if the fork does not exist.
+func (r *RelationReader) Fork(fork ForkNumber) *SegmentedFileReader {
+	return r.forks[fork]
+}
+
+// Main returns the reader for the main fork.
+func (r *RelationReader) Main() *SegmentedFileReader {
+	return r.forks[MAIN_FORKNUM]
+}
+
+// Problems returns the segment problems of all forks.
+func (r *RelationReader) Problems() []SegmentProblem {
+	var problems []SegmentProblem
+	for _, fork := range AllForks {
+		if reader, ok := r.forks[fork]; ok {
+			problems = append(problems, reader.Problems()...)
+		}
+	}
+	return problems
+}
+
+// RelationFile describes a file name in a database directory that belongs to a relation.
+type RelationFile struct {
+	RelFileNode uint32     // relfilenode number
+	Fork        ForkNumber // fork the file belongs to
+	Segment     int        // segment number
+}
+
+// ParseRelationFileName parses names such as 16384, 16384.2, 16384_fsm or
+// 16384_vm.1. It returns false for anything else.
+func ParseRelationFileName(name string) (RelationFile, bool) {
+	var file RelationFile
+
+	// Split off the segment number
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		if !IsNumeric(name[dot+1:]) {
+			return file, false
+		}
+		segno, err := strconv.Atoi(name[dot+1:])
+		if err != nil || segno == 0 {
+			return file, false
+		}
+		file.Segment = segno
+		name = name[:dot]
+	}
+
+	// Split off the fork name
+	if us := strings.IndexByte(name, '_'); us >= 0 {
+		fork, ok := parseForkName(name[us+1:])
+		if !ok {
+			return file, false
+		}
+		file.Fork = fork
+		name = name[:us]
+	}
+
+	// What is left must be the relfilenode
+	if !IsNumeric(name) {
+		return file, false
+	}
+	relfilenode, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return file, false
+	}
+	file.RelFileNode = uint32(relfilenode)
+
+	return file, true
+}
+
+// parseForkName maps a fork file name suffix to its ForkNumber.
+func parseForkName(name string) (ForkNumber, bool) {
+	for i, forkName := range forkNames {
+		if i != int(MAIN_FORKNUM) && forkName == name {
+			return ForkNumber(i), true
+		}
+	}
+	return MAIN_FORKNUM, false
+}
+
+// ForkPath returns the path of the first segment of a fork.
+func ForkPath(path string, fork ForkNumber) string {
+	if fork == MAIN_FORKNUM {
+		return path
+	}
+	return path + "_" + fork.String()
+}
+
+// SegmentPath returns the path of a segment of a fork.
+func SegmentPath(path string, fork ForkNumber, segno int) string {
+	if segno == 0 {
+		return ForkPath(path, fork)
+	}
+	return fmt.Sprintf("%s.%d", ForkPath(path, fork), segno)
+}
+
+// segmentFile is a segment file found on disk.
+type segmentFile struct {
+	segno int
+	path  string
+}
+
+// findSegments lists the existing segment files of a fork, ordered by segment number.
+func findSegments(path string, fork ForkNumber) ([]segmentFile, error) {
+	base := filepath.Base(path)
+	relfilenode, ok := ParseRelationFileName(base)
+	if !ok || relfilenode.Fork != MAIN_FORKNUM || relfilenode.Segment != 0 {
+		return nil, fmt.Errorf("%s is not a relfilenode path", path)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segmentFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file, ok := ParseRelationFileName(entry.Name())
+		if !ok || file.RelFileNode != relfilenode.RelFileNode || file.Fork != fork {
+			continue
+		}
+		segments = append(segments, segmentFile{
+			segno: file.Segment,
+			path:  filepath.Join(filepath.Dir(path), entry.Name()),
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].segno < segments[j].segno
+	})
+
+	return segments, nil
+}
+
+// FindRelations finds the relfilenode paths (without fork or segment suffix)
+// of all relations in a directory tree.
+func FindRelations(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	var relations []string
+
+	files, err := FindDataFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		file, _ := ParseRelationFileName(filepath.Base(path))
+		relPath := filepath.Join(filepath.Dir(path), strconv.FormatUint(uint64(file.RelFileNode), 10))
+		if !seen[relPath] {
+			seen[relPath] = true
+			relations = append(relations, relPath)
+		}
+	}
+
+	sort.Strings(relations)
+	return relations, nil
+}
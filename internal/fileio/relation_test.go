@@ -0,0 +1,186 @@
+package fileio
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// testBlockSize is the block size of the files written by writeBlocks.
+const testBlockSize = 4096
+
+// testParams are the storage parameters of a cluster with 4kB blocks and
+// segments of two blocks.
+func testParams() pgtypes.StorageParams {
+	params := pgtypes.DefaultStorageParams()
+	params.BlockSize = testBlockSize
+	params.RelSegSize = 2
+	return params
+}
+
+// writeBlocks writes a file of n blocks, each filled with its logical block
+// number starting at first.
+func writeBlocks(t *testing.T, path string, first, n int) {
+	t.Helper()
+	var data []byte
+	for i := 0; i < n; i++ {
+		data = append(data, bytes.Repeat([]byte{byte(first + i)}, testBlockSize)...)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openSegmented opens a fork with testParams.
+func openSegmented(t *testing.T, path string, fork ForkNumber, opts IOOptions) *SegmentedFileReader {
+	t.Helper()
+	r := NewSegmentedFileReader(fork, testParams())
+	r.SetIOOptions(opts)
+	if err := r.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestSegmentedFileReader(t *testing.T) {
+	dir := t.TempDir()
+	writeBlocks(t, filepath.Join(dir, "16384"), 0, 2)
+	writeBlocks(t, filepath.Join(dir, "16384.1"), 2, 2)
+	writeBlocks(t, filepath.Join(dir, "16384.2"), 4, 1)
+	writeBlocks(t, filepath.Join(dir, "16384_fsm"), 100, 1)
+	writeBlocks(t, filepath.Join(dir, "163840"), 200, 1)
+
+	modes := []struct {
+		name string
+		opts IOOptions
+	}{
+		{"pread", IOOptions{}},
+		{"mmap", IOOptions{Mode: IOModeMmap}},
+		{"read-ahead", IOOptions{ReadAhead: 3, Pool: NewPagePool(testBlockSize, nil)}},
+	}
+	for _, mode := range modes {
+		r := openSegmented(t, filepath.Join(dir, "16384"), MAIN_FORKNUM, mode.opts)
+		if r.SegmentCount() != 3 || r.GetPageCount() != 5 || r.GetFileSize() != 5*testBlockSize || len(r.Problems()) != 0 {
+			t.Fatalf("%s: %d segments, %d pages, %d bytes, problems %v",
+				mode.name, r.SegmentCount(), r.GetPageCount(), r.GetFileSize(), r.Problems())
+		}
+		for block := int64(0); block < 5; block++ {
+			page, err := r.ReadPage(block)
+			if err != nil || len(page) != testBlockSize || page[0] != byte(block) || page[testBlockSize-1] != byte(block) {
+				t.Errorf("%s: block %d: % .4x (%v)", mode.name, block, page, err)
+			}
+		}
+		if _, err := r.ReadPage(5); err == nil {
+			t.Errorf("%s: block 5: no error", mode.name)
+		}
+
+		// Reads cross segment boundaries
+		data, err := r.ReadBytes(2*testBlockSize-1, 2)
+		if err != nil || !bytes.Equal(data, []byte{1, 2}) {
+			t.Errorf("%s: read across segments 0 and 1: % x (%v)", mode.name, data, err)
+		}
+		data, err = r.ReadBytes(testBlockSize, 3*testBlockSize)
+		if err != nil || len(data) != 3*testBlockSize || data[0] != 1 || data[len(data)-1] != 3 {
+			t.Errorf("%s: read of blocks 1 to 3: %d bytes (%v)", mode.name, len(data), err)
+		}
+
+		// Other forks are separate address spaces
+		fsm := openSegmented(t, filepath.Join(dir, "16384"), FSM_FORKNUM, mode.opts)
+		if page, err := fsm.ReadPage(0); err != nil || page[0] != 100 || fsm.GetPageCount() != 1 {
+			t.Errorf("%s: fsm: block 0 is % .4x, %d pages (%v)", mode.name, page, fsm.GetPageCount(), err)
+		}
+	}
+}
+
+func TestSegmentedFileReaderProblems(t *testing.T) {
+	dir := t.TempDir()
+
+	// Segment 1 is missing
+	writeBlocks(t, filepath.Join(dir, "16385"), 0, 2)
+	writeBlocks(t, filepath.Join(dir, "16385.2"), 4, 1)
+	r := openSegmented(t, filepath.Join(dir, "16385"), MAIN_FORKNUM, IOOptions{})
+	if problems := r.Problems(); len(problems) != 1 || problems[0].Kind != SegmentMissing || problems[0].Segment != 1 {
+		t.Errorf("missing segment: problems %v", problems)
+	}
+	if _, err := r.ReadPage(2); !errors.Is(err, ErrSegmentMissing) {
+		t.Errorf("block in the missing segment: %v", err)
+	}
+	if page, err := r.ReadPage(4); err != nil || page[0] != 4 {
+		t.Errorf("block after the missing segment: % .4x (%v)", page, err)
+	}
+
+	// Segment 0 is short and segment 1 ends with a partial block
+	writeBlocks(t, filepath.Join(dir, "16386"), 0, 1)
+	writeBlocks(t, filepath.Join(dir, "16386.1"), 2, 1)
+	if err := os.Truncate(filepath.Join(dir, "16386.1"), 100); err != nil {
+		t.Fatal(err)
+	}
+	r = openSegmented(t, filepath.Join(dir, "16386"), MAIN_FORKNUM, IOOptions{})
+	problems := r.Problems()
+	if len(problems) != 2 || problems[0].Kind != SegmentShort || problems[1].Kind != SegmentPartial {
+		t.Fatalf("short and partial segments: problems %v", problems)
+	}
+	if problems[0].Size != testBlockSize || problems[0].Expected != 2*testBlockSize || problems[1].Size != 100 {
+		t.Errorf("short and partial segments: problems %+v", problems)
+	}
+	if _, err := r.ReadPage(1); !errors.Is(err, ErrSegmentMissing) {
+		t.Errorf("block beyond the short segment: %v", err)
+	}
+}
+
+func TestRelationReader(t *testing.T) {
+	dir := t.TempDir()
+	writeBlocks(t, filepath.Join(dir, "16387"), 0, 2)
+	writeBlocks(t, filepath.Join(dir, "16387_vm"), 10, 1)
+	writeBlocks(t, filepath.Join(dir, "16387_init"), 20, 1)
+
+	r := NewRelationReader(testParams())
+	if err := r.Open(filepath.Join(dir, "16387")); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Main().GetPageCount() != 2 || r.Fork(FSM_FORKNUM) != nil {
+		t.Errorf("main fork of %d pages, fsm %v", r.Main().GetPageCount(), r.Fork(FSM_FORKNUM))
+	}
+	for fork, first := range map[ForkNumber]byte{VISIBILITYMAP_FORKNUM: 10, INIT_FORKNUM: 20} {
+		if page, err := r.Fork(fork).ReadPage(0); err != nil || page[0] != first {
+			t.Errorf("%s: block 0 is % .4x (%v)", fork, page, err)
+		}
+	}
+
+	// The main fork is required
+	if err := NewRelationReader(testParams()).Open(filepath.Join(dir, "16388")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("relation without files: %v", err)
+	}
+}
+
+func TestParseRelationFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want RelationFile
+		ok   bool
+	}{
+		{"16384", RelationFile{16384, MAIN_FORKNUM, 0}, true},
+		{"16384.2", RelationFile{16384, MAIN_FORKNUM, 2}, true},
+		{"16384_fsm", RelationFile{16384, FSM_FORKNUM, 0}, true},
+		{"16384_vm.1", RelationFile{16384, VISIBILITYMAP_FORKNUM, 1}, true},
+		{"16384_init", RelationFile{16384, INIT_FORKNUM, 0}, true},
+		{"16384.0", RelationFile{}, false},
+		{"16384_main", RelationFile{}, false},
+		{"16384_fsm_vm", RelationFile{}, false},
+		{"pg_filenode.map", RelationFile{}, false},
+		{"4294967296", RelationFile{}, false},
+		{"t3_16384", RelationFile{}, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseRelationFileName(test.name)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("%s: %+v (%v), want %+v (%v)", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
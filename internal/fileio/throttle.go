@@ -0,0 +1,146 @@
+package fileio
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleBurst is how much unused budget a Throttle lets readers catch up
+// on, so that short pauses between reads do not lower the rate.
+const throttleBurst = 100 * time.Millisecond
+
+// rateInterval is the minimum interval over which Rate measures.
+const rateInterval = time.Second
+
+// Throttle limits the rate of reads in bytes per second and in read
+// operations per second, and measures the rate actually achieved. One
+// Throttle is shared by all readers of a cluster, so the limits apply to
+// PDU as a whole. A nil Throttle neither limits nor measures.
+type Throttle struct {
+	bytesPerSec int64 // 0 means no limit
+	iops        int64 // 0 means no limit
+
+	mu       sync.Mutex
+	bytesDue time.Time // when the bytes read so far are paid for
+	opsDue   time.Time // when the operations so far are paid for
+
+	// Totals, and the totals at the start of the current rate interval
+	bytes, ops         int64
+	rateBytes, rateOps int64
+	rateStart          time.Time
+	lastBytes, lastOps float64
+	started            time.Time
+}
+
+// NewThrottle creates a new Throttle. A limit of zero means no limit.
+func NewThrottle(bytesPerSec, iops int64) *Throttle {
+	return &Throttle{
+		bytesPerSec: bytesPerSec,
+		iops:        iops,
+		rateStart:   time.Now(),
+		started:     time.Now(),
+	}
+}
+
+// Wait blocks until a read of n bytes is within the limits, and counts it.
+func (t *Throttle) Wait(n int) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	t.bytes += int64(n)
+	t.ops++
+	var wait time.Duration
+	if t.bytesPerSec > 0 {
+		cost := time.Duration(float64(n) / float64(t.bytesPerSec) * float64(time.Second))
+		wait = reserve(&t.bytesDue, now, cost)
+	}
+	if t.iops > 0 {
+		if opsWait := reserve(&t.opsDue, now, time.Second/time.Duration(t.iops)); opsWait > wait {
+			wait = opsWait
+		}
+	}
+	t.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// reserve schedules an operation costing cost against a budget that is paid
+// for until due, and returns how long to wait for it.
+func reserve(due *time.Time, now time.Time, cost time.Duration) time.Duration {
+	// Unused budget only accumulates up to the burst
+	if earliest := now.Add(-throttleBurst); due.Before(earliest) {
+		*due = earliest
+	}
+	*due = due.Add(cost)
+	return due.Sub(now)
+}
+
+// Rate returns the bytes and read operations per second over the last
+// interval of at least a second.
+func (t *Throttle) Rate() (bytesPerSec, iops float64) {
+	if t == nil {
+		return 0, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := time.Since(t.rateStart)
+	if elapsed < rateInterval {
+		return t.lastBytes, t.lastOps
+	}
+	t.lastBytes = float64(t.bytes-t.rateBytes) / elapsed.Seconds()
+	t.lastOps = float64(t.ops-t.rateOps) / elapsed.Seconds()
+	t.rateBytes, t.rateOps = t.bytes, t.ops
+	t.rateStart = time.Now()
+	return t.lastBytes, t.lastOps
+}
+
+// Summary returns the average rate since the Throttle was created, and the limits.
+func (t *Throttle) Summary() string {
+	if t == nil {
+		return formatRate(0, 0)
+	}
+
+	t.mu.Lock()
+	elapsed := time.Since(t.started)
+	bytes, ops := t.bytes, t.ops
+	t.mu.Unlock()
+
+	s := fmt.Sprintf("%s over %s", formatRate(float64(bytes)/elapsed.Seconds(), float64(ops)/elapsed.Seconds()),
+		elapsed.Round(time.Millisecond))
+	return s + t.limits()
+}
+
+// String returns the current rate and the limits.
+func (t *Throttle) String() string {
+	return formatRate(t.Rate()) + t.limits()
+}
+
+// limits describes the limits in parentheses, or returns "" if there are none.
+func (t *Throttle) limits() string {
+	if t == nil || (t.bytesPerSec == 0 && t.iops == 0) {
+		return ""
+	}
+
+	var limits []string
+	if t.bytesPerSec > 0 {
+		limits = append(limits, fmt.Sprintf("%.1f MB/s", float64(t.bytesPerSec)/(1<<20)))
+	}
+	if t.iops > 0 {
+		limits = append(limits, fmt.Sprintf("%d IOPS", t.iops))
+	}
+	return " (limit " + strings.Join(limits, ", ") + ")"
+}
+
+// formatRate formats a read rate.
+func formatRate(bytesPerSec, iops float64) string {
+	return fmt.Sprintf("%.1f MB/s, %.0f IOPS", bytesPerSec/(1<<20), iops)
+}
@@ -0,0 +1,136 @@
+package fileio
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	now := time.Now()
+
+	// An idle budget lets a burst through at once
+	var due time.Time
+	if wait := reserve(&due, now, 10*time.Millisecond); wait > 0 {
+		t.Errorf("first operation waits %s", wait)
+	}
+	if want := now.Add(-throttleBurst + 10*time.Millisecond); !due.Equal(want) {
+		t.Errorf("due %s, want %s", due.Sub(now), want.Sub(now))
+	}
+
+	// Once the burst is used up, operations wait for their cost
+	due = now
+	if wait := reserve(&due, now, 10*time.Millisecond); wait != 10*time.Millisecond {
+		t.Errorf("operation after burst waits %s", wait)
+	}
+	if wait := reserve(&due, now, 5*time.Millisecond); wait != 15*time.Millisecond {
+		t.Errorf("second operation after burst waits %s", wait)
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	// At 200 IOPS, 20 reads beyond the burst of 20 take 100ms
+	throttle := NewThrottle(0, 200)
+	start := time.Now()
+	for i := 0; i < 40; i++ {
+		throttle.Wait(100)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("40 reads at 200 IOPS took %s", elapsed)
+	}
+
+	// At 1 MB/s, 200kB beyond the burst of 100kB take 100ms
+	throttle = NewThrottle(1000000, 0)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		throttle.Wait(100000)
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("300kB at 1 MB/s took %s", elapsed)
+	}
+
+	// Without limits nothing waits
+	throttle = NewThrottle(0, 0)
+	start = time.Now()
+	for i := 0; i < 1000; i++ {
+		throttle.Wait(1 << 20)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("unlimited reads took %s", elapsed)
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	throttle := NewThrottle(0, 0)
+	throttle.Wait(1 << 20)
+	throttle.Wait(1 << 20)
+
+	// The rate is not measured over less than a second
+	if bytes, ops := throttle.Rate(); bytes != 0 || ops != 0 {
+		t.Errorf("rate %.0f bytes/s, %.0f IOPS within a second", bytes, ops)
+	}
+
+	throttle.rateStart = time.Now().Add(-2 * time.Second)
+	bytes, ops := throttle.Rate()
+	if bytes < 0.9*(1<<20) || bytes > 1<<20 || ops < 0.9 || ops > 1 {
+		t.Errorf("rate %.0f bytes/s, %.2f IOPS, want 1 MB/s, 1 IOPS", bytes, ops)
+	}
+
+	// Until the next interval the last rate is kept
+	throttle.Wait(1 << 20)
+	if b, o := throttle.Rate(); b != bytes || o != ops {
+		t.Errorf("rate %.0f bytes/s, %.2f IOPS, want the last rate", b, o)
+	}
+}
+
+func TestThrottleString(t *testing.T) {
+	var throttle *Throttle
+	throttle.Wait(1 << 20)
+	if s := throttle.String(); s != "0.0 MB/s, 0 IOPS" {
+		t.Errorf("nil throttle: %q", s)
+	}
+	if s := throttle.Summary(); s != "0.0 MB/s, 0 IOPS" {
+		t.Errorf("nil throttle summary: %q", s)
+	}
+
+	throttle = NewThrottle(2<<20, 500)
+	if s := throttle.String(); s != "0.0 MB/s, 0 IOPS (limit 2.0 MB/s, 500 IOPS)" {
+		t.Errorf("throttle: %q", s)
+	}
+	if s := NewThrottle(0, 500).limits(); s != " (limit 500 IOPS)" {
+		t.Errorf("IOPS limit: %q", s)
+	}
+	if s := NewThrottle(0, 0).Summary(); !strings.HasPrefix(s, "0.0 MB/s, 0 IOPS over ") || strings.Contains(s, "limit") {
+		t.Errorf("summary without limits: %q", s)
+	}
+}
+
+func TestThrottledReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "16384")
+	writeBlocks(t, path, 0, 4)
+
+	for _, mode := range []IOMode{IOModePread, IOModeMmap} {
+		throttle := NewThrottle(0, 0)
+		r := NewFileReader(testParams(), IOOptions{Mode: mode, ReadAhead: 2, Throttle: throttle})
+		if err := r.Open(path); err != nil {
+			t.Fatal(err)
+		}
+		for block := int64(0); block < 4; block++ {
+			if _, err := r.ReadPage(block); err != nil {
+				t.Fatalf("%s: block %d: %v", mode, block, err)
+			}
+		}
+		r.Close()
+
+		// Read-ahead counts one read per system call
+		wantOps := int64(2)
+		if mode == IOModeMmap {
+			wantOps = 4
+		}
+		if throttle.bytes != 4*testBlockSize || throttle.ops != wantOps {
+			t.Errorf("%s: %d bytes in %d reads, want %d bytes in %d reads",
+				mode, throttle.bytes, throttle.ops, 4*testBlockSize, wantOps)
+		}
+	}
+}
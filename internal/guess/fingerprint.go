@@ -0,0 +1,77 @@
+package guess
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Fingerprint summarizes the shape of sampled tuples: how many columns they
+// have, how wide they are and how often they have nulls.
+type Fingerprint struct {
+	Tuples    int
+	Natts     map[int]int // tuples per number of columns
+	MinWidth  int         // shortest user data, in bytes
+	MaxWidth  int         // longest user data, in bytes
+	MeanWidth float64
+	WithNulls int // tuples with a null bitmap
+}
+
+// NewFingerprint computes the fingerprint of sampled tuples.
+func NewFingerprint(tuples []*pager.Tuple) *Fingerprint {
+	f := &Fingerprint{Tuples: len(tuples), Natts: make(map[int]int)}
+	total := 0
+	for i, tuple := range tuples {
+		f.Natts[pgtypes.HeapTupleHeaderGetNatts(tuple.Header)]++
+		width := len(tuple.Data)
+		if i == 0 || width < f.MinWidth {
+			f.MinWidth = width
+		}
+		if width > f.MaxWidth {
+			f.MaxWidth = width
+		}
+		total += width
+		if pgtypes.HeapTupleHasNulls(tuple.Header) {
+			f.WithNulls++
+		}
+	}
+	if len(tuples) > 0 {
+		f.MeanWidth = float64(total) / float64(len(tuples))
+	}
+	return f
+}
+
+// MaxNatts returns the largest number of columns of a sampled tuple. A table
+// definition with fewer columns cannot match.
+func (f *Fingerprint) MaxNatts() int {
+	max := 0
+	for natts := range f.Natts {
+		if natts > max {
+			max = natts
+		}
+	}
+	return max
+}
+
+// String returns a one-line summary, e.g. "natts 5 (300), width 40-72 bytes
+// (mean 68.3), 20% with nulls".
+func (f *Fingerprint) String() string {
+	var natts []int
+	for n := range f.Natts {
+		natts = append(natts, n)
+	}
+	sort.Ints(natts)
+	var counts []string
+	for _, n := range natts {
+		counts = append(counts, fmt.Sprintf("%d (%d)", n, f.Natts[n]))
+	}
+	nulls := 0
+	if f.Tuples > 0 {
+		nulls = f.WithNulls * 100 / f.Tuples
+	}
+	return fmt.Sprintf("natts %s, width %d-%d bytes (mean %.1f), %d%% with nulls",
+		strings.Join(counts, ", "), f.MinWidth, f.MaxWidth, f.MeanWidth, nulls)
+}
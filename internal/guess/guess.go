@@ -0,0 +1,493 @@
+// Package guess infers the column types of heap files whose catalog entries
+// are lost. Tuples record how many columns they have and which are null, but
+// not their types; the types are searched for so that every sampled tuple
+// decodes to exactly its length, and ranked by how plausible the values are.
+package guess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Search limits
+const (
+	sampleBlocks = 256 // blocks sampled, spread over the file
+	beamWidth    = 256 // partial layouts kept per column
+	closeScore   = 0.2 // types scoring within this of the best are alternatives
+
+	constantPenalty = 0.5 // factor for the scores of columns whose sampled values are all the same
+)
+
+// Sample reads up to max tuples from blocks spread evenly over the file open
+// in processor. Pages that cannot be parsed are skipped, as are pages with
+// special space, which belong to indexes and sequences rather than tables.
+func Sample(processor *pager.PageProcessor, max int) []*pager.Tuple {
+	pages := processor.PageCount()
+	blocks := pages
+	if blocks > sampleBlocks {
+		blocks = sampleBlocks
+	}
+
+	var tuples []*pager.Tuple
+	for i := int64(0); i < blocks && len(tuples) < max; i++ {
+		page, pageTuples, err := processor.ProcessPage(i * pages / blocks)
+		if err != nil || int(page.Header.PDSpecial) != len(page.RawData) {
+			continue
+		}
+		for _, tuple := range pageTuples {
+			if len(tuples) == max {
+				break
+			}
+			if pgtypes.HeapTupleHeaderGetNatts(tuple.Header) == 0 {
+				continue
+			}
+			// Keep a copy, as page buffers are reused
+			tuple.Data = append([]byte(nil), tuple.Data...)
+			tuple.Header.TBits = append([]uint8(nil), tuple.Header.TBits...)
+			tuples = append(tuples, tuple)
+		}
+	}
+	return tuples
+}
+
+// Column is the guessed type of one column.
+type Column struct {
+	Type   pgtypes.TypeInfo
+	Score  float64 // mean plausibility of the sampled values as this type
+	Values int     // sampled tuples in which the column is not null
+	Nulls  int     // sampled tuples in which the column is null or missing
+}
+
+// Candidate is a guessed table layout.
+type Candidate struct {
+	Columns      []Column
+	Tuples       int     // sampled tuples
+	Decoded      int     // sampled tuples the layout decodes to exactly their length
+	Plausibility float64 // mean score of the columns with values
+}
+
+// Fit returns the fraction of sampled tuples the layout decodes exactly.
+func (c *Candidate) Fit() float64 {
+	if c.Tuples == 0 {
+		return 0
+	}
+	return float64(c.Decoded) / float64(c.Tuples)
+}
+
+// Score ranks candidates: the fit weighted by the plausibility of the values.
+func (c *Candidate) Score() float64 {
+	return c.Fit() * c.Plausibility
+}
+
+// CreateTable returns the candidate as a CREATE TABLE statement that unload
+// reads with --table-def. Columns never seen with a value are given type
+// text, and flagged with a comment, as any type would do.
+func (c *Candidate) CreateTable(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", name)
+	for i, col := range c.Columns {
+		typeName := col.Type.Name
+		if typeName == "char" {
+			typeName = `"char"`
+		}
+		fmt.Fprintf(&b, "    col%d %s", i+1, typeName)
+		if i < len(c.Columns)-1 {
+			b.WriteByte(',')
+		}
+		switch {
+		case col.Values == 0:
+			b.WriteString(" -- always null in the sample")
+		case col.Nulls == 0:
+			fmt.Fprintf(&b, " -- never null, score %.2f", col.Score)
+		default:
+			fmt.Fprintf(&b, " -- %d%% null, score %.2f", col.Nulls*100/(col.Values+col.Nulls), col.Score)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+// class is a storage layout shared by several types, which the structure of
+// a tuple cannot tell apart; the values can.
+type class struct {
+	len   int16
+	align byte
+	types []pgtypes.TypeInfo
+}
+
+// classes lists the layouts searched and the types tried for each, most
+// likely first.
+var classes = func() []class {
+	layouts := [][]uint32{
+		{pgtypes.BOOLOID, pgtypes.CHAROID},
+		{pgtypes.INT2OID},
+		{pgtypes.INT4OID, pgtypes.DATEOID, pgtypes.FLOAT4OID},
+		{pgtypes.INT8OID, pgtypes.TIMESTAMPOID, pgtypes.FLOAT8OID},
+		{pgtypes.UUIDOID},
+		{pgtypes.TEXTOID, pgtypes.NUMERICOID, pgtypes.BYTEAOID},
+	}
+	var classes []class
+	for _, oids := range layouts {
+		var cl class
+		for _, oid := range oids {
+			t, _ := pgtypes.LookupTypeByOid(oid)
+			cl.len, cl.align = t.Len, t.Align
+			cl.types = append(cl.types, t)
+		}
+		classes = append(classes, cl)
+	}
+	return classes
+}()
+
+// unknownType is used for columns that are null in every sampled tuple.
+var unknownType, _ = pgtypes.LookupTypeByOid(pgtypes.TEXTOID)
+
+// layout is a partial guess: the columns so far and where each sampled
+// tuple's next column starts.
+type layout struct {
+	columns []columnScores
+	offsets []int // -1 once a tuple failed to decode
+	failed  int
+	score   float64 // sum of the best type scores of the columns
+}
+
+// columnScores is the class guessed for a column and the score of each of
+// its types.
+type columnScores struct {
+	class  int // index into classes, -1 if the column is always null
+	scores []float64
+	values int
+	nulls  int
+}
+
+// Infer guesses the layout of the tuples and returns up to max candidates,
+// best first. The number of columns is the largest natts in the sample.
+func Infer(tuples []*pager.Tuple, order binary.ByteOrder, max int) []*Candidate {
+	if len(tuples) == 0 {
+		return nil
+	}
+	if order == nil {
+		order = pgtypes.DefaultByteOrder
+	}
+
+	natts := 0
+	for _, tuple := range tuples {
+		if n := pgtypes.HeapTupleHeaderGetNatts(tuple.Header); n > natts {
+			natts = n
+		}
+	}
+
+	// Beam search over the classes of the columns, one column at a time
+	beam := []*layout{{offsets: make([]int, len(tuples))}}
+	for attnum := 0; attnum < natts; attnum++ {
+		var next []*layout
+		for _, l := range beam {
+			next = append(next, l.extend(tuples, attnum, order)...)
+		}
+		beam = prune(next)
+	}
+
+	// Tuples must end where their last column does
+	for _, l := range beam {
+		for i, tuple := range tuples {
+			if l.offsets[i] >= 0 && l.offsets[i] != len(tuple.Data) {
+				l.offsets[i] = -1
+				l.failed++
+			}
+		}
+	}
+	sortLayouts(beam)
+
+	// Each layout gives its best types and, where another type scores
+	// nearly as well, alternatives
+	var candidates []*Candidate
+	seen := make(map[string]bool)
+	add := func(c *Candidate) {
+		key := c.key()
+		if !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, c)
+		}
+	}
+	for _, l := range beam {
+		best := l.candidate(len(tuples), -1, 0)
+		add(best)
+		for i, col := range l.columns {
+			if col.class < 0 {
+				continue
+			}
+			for t := range col.scores {
+				if classes[col.class].types[t].Oid != best.Columns[i].Type.Oid &&
+					col.scores[t] >= best.Columns[i].Score-closeScore {
+					add(l.candidate(len(tuples), i, t))
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score() > candidates[j].Score()
+	})
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	return candidates
+}
+
+// prune keeps the best layouts, and of layouts that leave every tuple at the
+// same offset only the best, as they continue alike.
+func prune(layouts []*layout) []*layout {
+	sortLayouts(layouts)
+	var kept []*layout
+	seen := make(map[string]bool)
+	for _, l := range layouts {
+		key := offsetsKey(l.offsets)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, l)
+		if len(kept) == beamWidth {
+			break
+		}
+	}
+	return kept
+}
+
+// offsetsKey returns a map key for the offsets of a layout.
+func offsetsKey(offsets []int) string {
+	key := make([]byte, 0, len(offsets)*2)
+	for _, offset := range offsets {
+		key = append(key, byte(offset), byte(offset>>8))
+	}
+	return string(key)
+}
+
+// sortLayouts orders layouts by the number of tuples they fail to decode,
+// then by score.
+func sortLayouts(layouts []*layout) {
+	sort.SliceStable(layouts, func(i, j int) bool {
+		if layouts[i].failed != layouts[j].failed {
+			return layouts[i].failed < layouts[j].failed
+		}
+		return layouts[i].score > layouts[j].score
+	})
+}
+
+// extend returns the layouts that follow from l with one more column of
+// each class. A column that is null in every tuple takes no space, so it
+// only extends l once, with an unknown type.
+func (l *layout) extend(tuples []*pager.Tuple, attnum int, order binary.ByteOrder) []*layout {
+	nulls := 0
+	for _, tuple := range tuples {
+		if isNull(tuple, attnum) {
+			nulls++
+		}
+	}
+	if nulls == len(tuples) {
+		next := l.copy()
+		next.columns = append(next.columns, columnScores{class: -1, nulls: nulls})
+		return []*layout{next}
+	}
+
+	var layouts []*layout
+	for c, cl := range classes {
+		next := l.copy()
+		col := columnScores{class: c, scores: make([]float64, len(cl.types)), nulls: nulls}
+		var first []byte
+		constant := true
+		for i, tuple := range tuples {
+			offset := next.offsets[i]
+			if offset < 0 || isNull(tuple, attnum) {
+				continue
+			}
+			value, end, ok := fetch(tuple.Data, offset, cl.len, cl.align, order)
+			if !ok {
+				next.offsets[i] = -1
+				next.failed++
+				continue
+			}
+			next.offsets[i] = end
+			if first == nil {
+				first = value
+			} else if constant && !bytes.Equal(first, value) {
+				constant = false
+			}
+			col.values++
+			for t, typ := range cl.types {
+				col.scores[t] += plausibility(typ.Oid, value, order)
+			}
+		}
+
+		// A value that never changes is weak evidence: the zero high bytes
+		// of small integers pass for any narrower type
+		best := 0.0
+		for t := range col.scores {
+			if col.values > 0 {
+				col.scores[t] /= float64(col.values)
+			}
+			if constant && col.values > 1 {
+				col.scores[t] *= constantPenalty
+			}
+			if col.scores[t] > best {
+				best = col.scores[t]
+			}
+		}
+		next.columns = append(next.columns, col)
+		next.score += best
+		layouts = append(layouts, next)
+	}
+	return layouts
+}
+
+// copy returns a copy of l that can be extended independently.
+func (l *layout) copy() *layout {
+	return &layout{
+		columns: append([]columnScores(nil), l.columns...),
+		offsets: append([]int(nil), l.offsets...),
+		failed:  l.failed,
+		score:   l.score,
+	}
+}
+
+// candidate turns a complete layout into a candidate, with the best type of
+// each column except column alt, which gets type index altType.
+func (l *layout) candidate(tuples, alt, altType int) *Candidate {
+	c := &Candidate{Tuples: tuples, Decoded: tuples - l.failed}
+	scored := 0
+	for i, col := range l.columns {
+		if col.class < 0 {
+			c.Columns = append(c.Columns, Column{Type: unknownType, Nulls: col.nulls})
+			continue
+		}
+		t := 0
+		for j := range col.scores {
+			if col.scores[j] > col.scores[t] {
+				t = j
+			}
+		}
+		if i == alt {
+			t = altType
+		}
+		c.Columns = append(c.Columns, Column{
+			Type:   classes[col.class].types[t],
+			Score:  col.scores[t],
+			Values: col.values,
+			Nulls:  col.nulls,
+		})
+		c.Plausibility += col.scores[t]
+		scored++
+	}
+	if scored > 0 {
+		c.Plausibility /= float64(scored)
+	}
+	return c
+}
+
+// key identifies the types of a candidate.
+func (c *Candidate) key() string {
+	var b strings.Builder
+	for _, col := range c.Columns {
+		fmt.Fprintf(&b, "%d,", col.Type.Oid)
+	}
+	return b.String()
+}
+
+// isNull reports whether a column is null or missing in a tuple.
+func isNull(tuple *pager.Tuple, attnum int) bool {
+	return attnum >= pgtypes.HeapTupleHeaderGetNatts(tuple.Header) ||
+		pgtypes.HeapTupleHeaderAttIsNull(tuple.Header, attnum)
+}
+
+// fetch returns the value of a column with the given length and alignment
+// at offset, and the offset after it, as the deformer would find them. The
+// alignment padding PostgreSQL writes is zeroed, so a guess that skips
+// non-zero bytes is wrong.
+func fetch(data []byte, offset int, length int16, align byte, order binary.ByteOrder) ([]byte, int, bool) {
+	if length != -1 || offset >= len(data) || data[offset] == 0 {
+		aligned := decoder.AlignOffset(offset, align)
+		for ; offset < aligned && offset < len(data); offset++ {
+			if data[offset] != 0 {
+				return nil, 0, false
+			}
+		}
+		offset = aligned
+	}
+	if offset >= len(data) {
+		return nil, 0, false
+	}
+
+	size := int(length)
+	if length == -1 {
+		var err error
+		if size, err = decoder.VarSizeAny(data[offset:], order); err != nil {
+			return nil, 0, false
+		}
+	}
+	if offset+size > len(data) {
+		return nil, 0, false
+	}
+	return data[offset : offset+size], offset + size, true
+}
+
+// Fit decodes the sampled tuples with a column descriptor list and reports
+// how many decode to exactly their length, and the mean plausibility of the
+// values of the columns that have any. It measures how well a known table
+// definition matches a file.
+func Fit(attrs []decoder.Attribute, tuples []*pager.Tuple, order binary.ByteOrder) (int, float64) {
+	if order == nil {
+		order = pgtypes.DefaultByteOrder
+	}
+	deformer := decoder.NewDeformer(attrs, order)
+
+	decoded := 0
+	scores := make([]float64, len(attrs))
+	values := make([]int, len(attrs))
+	for _, tuple := range tuples {
+		// Attributes beyond the descriptor are ignored by the deformer but
+		// mean the descriptor is wrong
+		if pgtypes.HeapTupleHeaderGetNatts(tuple.Header) > len(attrs) {
+			continue
+		}
+		datums, err := deformer.Deform(tuple)
+		if err != nil {
+			continue
+		}
+		end := 0
+		for _, datum := range datums {
+			if !datum.IsNull && !datum.IsMissing {
+				end = datum.Offset + len(datum.Data)
+			}
+		}
+		if end != len(tuple.Data) {
+			continue
+		}
+		decoded++
+		for i, datum := range datums {
+			if !datum.IsNull && !datum.IsMissing && !datum.Attr.AttIsDropped {
+				scores[i] += plausibility(datum.Attr.TypeOid, datum.Data, order)
+				values[i]++
+			}
+		}
+	}
+
+	plausible, scored := 0.0, 0
+	for i := range attrs {
+		if values[i] > 0 {
+			plausible += scores[i] / float64(values[i])
+			scored++
+		}
+	}
+	if scored > 0 {
+		plausible /= float64(scored)
+	}
+	return decoded, plausible
+}
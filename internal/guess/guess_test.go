@@ -0,0 +1,135 @@
+package guess
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+var order = binary.LittleEndian
+
+// testTuples returns n tuples of a table (id int4, name text, created
+// timestamp, note text) in which note is always null and name is null in
+// every fifth row.
+func testTuples(n int) []*pager.Tuple {
+	var tuples []*pager.Tuple
+	for i := 0; i < n; i++ {
+		tuple := &pager.Tuple{}
+		tuple.Header.TInfomask2 = 4
+		tuple.Header.TInfomask = pgtypes.HEAP_HASNULL
+		tuple.Header.TBits = []byte{0x07}
+
+		data := order.AppendUint32(nil, uint32(100000+i))
+		if i%5 == 4 {
+			tuple.Header.TBits[0] = 0x05
+		} else {
+			name := fmt.Sprintf("customer %d", i*7)
+			data = append(data, byte(1+len(name))<<1|1)
+			data = append(data, name...)
+		}
+		for len(data)%8 != 0 {
+			data = append(data, 0)
+		}
+		created := int64(8401)*usecsPerDay + int64(i)*3600*1000000 // 2023-01-01, hourly
+		tuple.Data = order.AppendUint64(data, uint64(created))
+		tuples = append(tuples, tuple)
+	}
+	return tuples
+}
+
+// columnTypes returns the type names of the columns of a candidate.
+func columnTypes(c *Candidate) string {
+	var names []string
+	for _, col := range c.Columns {
+		names = append(names, col.Type.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestInfer(t *testing.T) {
+	tuples := testTuples(40)
+	candidates := Infer(tuples, order, 5)
+	if len(candidates) == 0 || len(candidates) > 5 {
+		t.Fatalf("%d candidates", len(candidates))
+	}
+
+	best := candidates[0]
+	if got := columnTypes(best); got != "int4, text, timestamp, text" {
+		t.Errorf("best candidate %s", got)
+	}
+	if best.Tuples != 40 || best.Decoded != 40 || best.Fit() != 1 {
+		t.Errorf("best candidate decodes %d of %d tuples", best.Decoded, best.Tuples)
+	}
+	if name := best.Columns[1]; name.Values != 32 || name.Nulls != 8 {
+		t.Errorf("name: %d values, %d nulls", name.Values, name.Nulls)
+	}
+	if note := best.Columns[3]; note.Values != 0 || note.Nulls != 40 {
+		t.Errorf("note: %d values, %d nulls", note.Values, note.Nulls)
+	}
+	for i := 1; i < len(candidates); i++ {
+		if candidates[i].Score() > candidates[i-1].Score() {
+			t.Errorf("candidate %d scores %.3f, more than the one before", i, candidates[i].Score())
+		}
+	}
+
+	if candidates := Infer(nil, order, 5); candidates != nil {
+		t.Errorf("%d candidates without tuples", len(candidates))
+	}
+}
+
+func TestInferBigEndian(t *testing.T) {
+	tuple := &pager.Tuple{Data: []byte{0x00, 0x00, 0x00, 0x2A, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07}}
+	tuple.Header.TInfomask2 = 2
+	candidates := Infer([]*pager.Tuple{tuple}, binary.BigEndian, 1)
+	if len(candidates) != 1 || candidates[0].Fit() != 1 || len(candidates[0].Columns) != 2 {
+		t.Fatalf("candidates %+v", candidates)
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	best := Infer(testTuples(40), order, 1)[0]
+	want := `CREATE TABLE orphan (
+    col1 int4, -- never null, score `
+	got := best.CreateTable("orphan")
+	if !strings.HasPrefix(got, want) || !strings.Contains(got, "    col2 text, -- 20% null") ||
+		!strings.Contains(got, "    col4 text -- always null in the sample\n);\n") {
+		t.Errorf("CREATE TABLE statement:\n%s", got)
+	}
+}
+
+func TestFit(t *testing.T) {
+	attrs := func(oids ...uint32) []decoder.Attribute {
+		var attrs []decoder.Attribute
+		for i, oid := range oids {
+			typ, _ := pgtypes.LookupTypeByOid(oid)
+			attrs = append(attrs, decoder.Attribute{
+				Name:     fmt.Sprintf("col%d", i+1),
+				AttNum:   i + 1,
+				TypeOid:  typ.Oid,
+				AttLen:   typ.Len,
+				AttAlign: typ.Align,
+				AttByVal: typ.ByVal,
+			})
+		}
+		return attrs
+	}
+	tuples := testTuples(20)
+
+	decoded, plausible := Fit(attrs(pgtypes.INT4OID, pgtypes.TEXTOID, pgtypes.TIMESTAMPOID, pgtypes.TEXTOID), tuples, order)
+	if decoded != 20 || plausible < 0.7 {
+		t.Errorf("table definition decodes %d tuples, plausibility %.2f", decoded, plausible)
+	}
+
+	// Too few columns, or the wrong types, do not fit
+	if decoded, _ := Fit(attrs(pgtypes.INT4OID, pgtypes.TEXTOID), tuples, order); decoded != 0 {
+		t.Errorf("2 columns decode %d tuples", decoded)
+	}
+	if decoded, _ := Fit(attrs(pgtypes.INT8OID, pgtypes.TEXTOID, pgtypes.TIMESTAMPOID, pgtypes.TEXTOID), tuples, order); decoded != 4 {
+		t.Errorf("int8 id decodes %d tuples, want the 4 without a name", decoded)
+	}
+}
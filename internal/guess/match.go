@@ -0,0 +1,59 @@
+package guess
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/internal/pager"
+)
+
+// Match is how well a known table definition fits sampled tuples.
+type Match struct {
+	Relation     *catalog.Relation
+	Tuples       int     // sampled tuples
+	Decoded      int     // sampled tuples the definition decodes to exactly their length
+	Plausibility float64 // mean plausibility of the decoded values
+}
+
+// Fit returns the fraction of sampled tuples the definition decodes exactly.
+func (m *Match) Fit() float64 {
+	if m.Tuples == 0 {
+		return 0
+	}
+	return float64(m.Decoded) / float64(m.Tuples)
+}
+
+// Score ranks matches: the fit weighted by the plausibility of the values.
+func (m *Match) Score() float64 {
+	return m.Fit() * m.Plausibility
+}
+
+// MatchRelations scores the definitions of relations against sampled tuples
+// and returns those that decode any tuple, best first. Definitions with fewer
+// columns than a tuple has are not tried, as they cannot have written it.
+func MatchRelations(relations []*catalog.Relation, tuples []*pager.Tuple, order binary.ByteOrder) []*Match {
+	natts := NewFingerprint(tuples).MaxNatts()
+
+	var matches []*Match
+	for _, rel := range relations {
+		if len(rel.Columns) < natts {
+			continue
+		}
+		decoded, plausibility := Fit(rel.Attributes(), tuples, order)
+		if decoded == 0 {
+			continue
+		}
+		matches = append(matches, &Match{
+			Relation:     rel,
+			Tuples:       len(tuples),
+			Decoded:      decoded,
+			Plausibility: plausibility,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score() > matches[j].Score()
+	})
+	return matches
+}
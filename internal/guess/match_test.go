@@ -0,0 +1,55 @@
+package guess
+
+import (
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/catalog"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+func TestMatchRelations(t *testing.T) {
+	profile, err := pgtypes.ProfileFor(150000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relations, err := catalog.ParseCreateTable(`
+		CREATE TABLE customers (id int, name text, created timestamp, note text);
+		CREATE TABLE wide_ids (id bigint, name text, created timestamp, note text);
+		CREATE TABLE events (id int, at date, payload text, created timestamp, note text);
+		CREATE TABLE pairs (id int, name text);
+		CREATE TABLE dates (id int, name text, created date, note text);`, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := MatchRelations(relations, testTuples(40), order)
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.Relation.Name)
+	}
+
+	// pairs has too few columns; events and dates decode no tuple
+	if len(matches) != 2 || names[0] != "customers" || names[1] != "wide_ids" {
+		t.Fatalf("matches %q", names)
+	}
+	if m := matches[0]; m.Tuples != 40 || m.Decoded != 40 || m.Fit() != 1 || m.Score() < 0.7 {
+		t.Errorf("customers decodes %d of %d tuples, score %.2f", m.Decoded, m.Tuples, m.Score())
+	}
+	if m := matches[1]; m.Decoded != 8 || m.Score() >= matches[0].Score() {
+		t.Errorf("wide_ids decodes %d tuples, score %.2f", m.Decoded, m.Score())
+	}
+
+	if matches := MatchRelations([]*catalog.Relation{}, testTuples(1), order); len(matches) != 0 {
+		t.Errorf("%d matches without relations", len(matches))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	f := NewFingerprint(testTuples(10))
+	if f.MaxNatts() != 4 || f.Tuples != 10 || f.WithNulls != 10 || f.MinWidth != 16 || f.MaxWidth != 24 {
+		t.Errorf("fingerprint %+v", f)
+	}
+	if s := f.String(); s != "natts 4 (10), width 16-24 bytes (mean 22.4), 100% with nulls" {
+		t.Errorf("fingerprint %s", s)
+	}
+}
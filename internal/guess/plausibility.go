@@ -0,0 +1,170 @@
+package guess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// Ranges of plausible dates and timestamps, 1970 to 2100, relative to the
+// PostgreSQL epoch 2000-01-01
+const (
+	minPlausibleDate = -10957
+	maxPlausibleDate = 36525
+	usecsPerDay      = 86400 * 1000000
+)
+
+// neutral is the score of values whose type cannot be judged.
+const neutral = 0.5
+
+// plausibility scores how likely a value is to be of a type, from 0 for
+// impossible to 1 for typical. Scores are only compared between types of the
+// same storage layout, so they reward what distinguishes a type: small
+// integers, dates in recent decades, floats of sensible magnitude, text that
+// is valid UTF-8.
+func plausibility(typeOid uint32, value []byte, order binary.ByteOrder) float64 {
+	switch typeOid {
+	case pgtypes.BOOLOID:
+		if value[0] <= 1 {
+			return 1
+		}
+		return 0
+	case pgtypes.CHAROID:
+		if value[0] >= 0x20 && value[0] < 0x7F {
+			return 0.8
+		}
+		return 0.1
+	case pgtypes.INT2OID:
+		if v := int16(order.Uint16(value)); v > -10000 && v < 10000 {
+			return 0.7
+		}
+		return 0.5
+	case pgtypes.INT4OID:
+		if v := int32(order.Uint32(value)); v > -10000000 && v < 10000000 {
+			return 0.7
+		}
+		return 0.4
+	case pgtypes.DATEOID:
+		// Small integers would be dates around 2000: possible, but more
+		// likely numbers
+		v := int32(order.Uint32(value))
+		if v >= minPlausibleDate && v <= maxPlausibleDate && (v < -3650 || v > 3650) {
+			return 0.75
+		}
+		return 0.1
+	case pgtypes.FLOAT4OID:
+		return floatPlausibility(float64(math.Float32frombits(order.Uint32(value))))
+	case pgtypes.INT8OID:
+		if v := int64(order.Uint64(value)); v > -1000000000000 && v < 1000000000000 {
+			return 0.7
+		}
+		return 0.4
+	case pgtypes.TIMESTAMPOID, pgtypes.TIMESTAMPTZOID:
+		v := int64(order.Uint64(value))
+		if v >= minPlausibleDate*usecsPerDay && v <= maxPlausibleDate*usecsPerDay && (v < -3650*usecsPerDay || v > 3650*usecsPerDay) {
+			return 0.8
+		}
+		return 0
+	case pgtypes.FLOAT8OID:
+		return floatPlausibility(math.Float64frombits(order.Uint64(value)))
+	case pgtypes.UUIDOID:
+		// RFC 4122 version and variant
+		if version := value[6] >> 4; version >= 1 && version <= 8 && value[8]&0xC0 == 0x80 {
+			return 0.9
+		}
+		return 0.1
+	case pgtypes.NAMEOID:
+		return textPlausibility(bytes.TrimRight(value, "\x00"))
+	case pgtypes.TEXTOID, pgtypes.VARCHAROID, pgtypes.BPCHAROID:
+		payload, err := decoder.VarData(value, order)
+		if err != nil {
+			return neutral
+		}
+		return textPlausibility(payload)
+	case pgtypes.NUMERICOID:
+		payload, err := decoder.VarData(value, order)
+		if err == decoder.ErrCompressed || err == decoder.ErrExternal {
+			return neutral
+		}
+		if err != nil {
+			return 0
+		}
+		return numericPlausibility(payload, order)
+	case pgtypes.BYTEAOID:
+		return 0.3
+	}
+	return neutral
+}
+
+// floatPlausibility scores a floating point value by its magnitude. The bits
+// of integers and timestamps read as floats are tiny or huge.
+func floatPlausibility(v float64) float64 {
+	abs := math.Abs(v)
+	switch {
+	case v == 0:
+		return neutral
+	case math.IsNaN(v), math.IsInf(v, 0):
+		return 0.1
+	case abs > 1e-6 && abs < 1e15:
+		return 0.75
+	}
+	return 0
+}
+
+// textPlausibility scores text: valid UTF-8 without control characters
+// other than tab and line breaks.
+func textPlausibility(payload []byte) float64 {
+	if !utf8.Valid(payload) {
+		return 0.05
+	}
+	for _, r := range string(payload) {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return 0.1
+		}
+	}
+	return 0.9
+}
+
+// numericPlausibility scores the payload of a numeric: a header, possibly a
+// weight, and base-10000 digits, with display scale and weight within what
+// applications use.
+func numericPlausibility(payload []byte, order binary.ByteOrder) float64 {
+	if len(payload) < 2 {
+		return 0
+	}
+	header := order.Uint16(payload)
+	digits, score := payload[2:], 0.85 // the short format is what PostgreSQL writes for typical values
+	switch header & decoder.NUMERIC_SIGN_MASK {
+	case decoder.NUMERIC_SPECIAL:
+		switch header {
+		case decoder.NUMERIC_NAN, decoder.NUMERIC_PINF, decoder.NUMERIC_NINF:
+			return 0.6
+		}
+		return 0
+	case decoder.NUMERIC_POS, decoder.NUMERIC_NEG:
+		if len(payload) < 4 {
+			return 0
+		}
+		dscale := header & decoder.NUMERIC_DSCALE_MASK
+		weight := int16(order.Uint16(payload[2:]))
+		digits, score = payload[4:], 0.8
+		if dscale > 100 || weight < -100 || weight > 100 {
+			score = 0.05
+		}
+	}
+
+	if len(digits)%2 != 0 {
+		return 0
+	}
+	for i := 0; i < len(digits); i += 2 {
+		if order.Uint16(digits[i:]) >= decoder.NBASE {
+			return 0
+		}
+	}
+	return score
+}
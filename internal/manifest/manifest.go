@@ -0,0 +1,57 @@
+// Package manifest writes the manifest that describes the output of a PDU
+// command: where the data came from, and how far it can be trusted.
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wublabdubdub/pdu/internal/cluster"
+)
+
+// FileName is the name of the manifest within an output directory.
+const FileName = "manifest.json"
+
+// Manifest describes the output of a PDU command.
+type Manifest struct {
+	Command       string    `json:"command"`                  // command that wrote the output
+	PGData        string    `json:"pgdata"`                   // data directory that was read
+	Version       string    `json:"version"`                  // PostgreSQL version of the cluster
+	Created       time.Time `json:"created"`                  // when the command started
+	LiveCluster   bool      `json:"live_cluster"`             // a postmaster was running on the cluster
+	PostmasterPID int       `json:"postmaster_pid,omitempty"` // PID from postmaster.pid
+	TornPageRisk  bool      `json:"torn_page_risk"`           // pages may be torn or inconsistent
+	Warnings      []string  `json:"warnings,omitempty"`       // warnings raised when opening the cluster
+}
+
+// New creates the manifest of a command reading cluster c.
+func New(command string, c *cluster.Cluster) *Manifest {
+	m := &Manifest{
+		Command:      command,
+		PGData:       c.PGData,
+		Version:      c.Params.Profile.String(),
+		Created:      time.Now(),
+		LiveCluster:  c.Live(),
+		TornPageRisk: c.TornPageRisk(),
+		Warnings:     c.Warnings,
+	}
+	if c.Postmaster != nil {
+		m.PostmasterPID = c.Postmaster.PID
+	}
+	return m
+}
+
+// Write writes the manifest to the output directory, creating it if needed.
+func (m *Manifest) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName), append(data, '\n'), 0644)
+}
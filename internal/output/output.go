@@ -0,0 +1,191 @@
+// Package output writes unloaded rows as SQL, CSV or JSON files.
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format is a file format for unloaded rows.
+type Format int
+
+// Output formats
+const (
+	FormatSQL  Format = iota // INSERT statements
+	FormatCSV                // CSV with a header line, as COPY ... CSV HEADER reads it
+	FormatJSON               // JSON Lines, one object per row
+)
+
+// ParseFormat parses a format name: sql, csv or json.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "sql":
+		return FormatSQL, nil
+	case "csv":
+		return FormatCSV, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return 0, fmt.Errorf("unknown output format %q (sql, csv, json)", name)
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatSQL:
+		return "sql"
+	case FormatCSV:
+		return "csv"
+	case FormatJSON:
+		return "json"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Extension returns the file name extension of the format, without the dot.
+func (f Format) Extension() string {
+	return f.String()
+}
+
+// Writer writes the rows of one table to a file.
+type Writer struct {
+	file    *os.File
+	w       *bufio.Writer
+	format  Format
+	table   string
+	columns []string
+	insert  string // INSERT statement up to VALUES, for FormatSQL
+	rows    int64
+}
+
+// Create creates the file at path and writes a table with the given schema,
+// name and column names to it. The schema may be empty.
+func Create(path string, format Format, schema, table string, columns []string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		file:    file,
+		w:       bufio.NewWriterSize(file, 1<<16),
+		format:  format,
+		table:   QuoteIdentifier(table),
+		columns: columns,
+	}
+	if schema != "" {
+		w.table = QuoteIdentifier(schema) + "." + w.table
+	}
+
+	switch format {
+	case FormatSQL:
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = QuoteIdentifier(column)
+		}
+		w.insert = fmt.Sprintf("INSERT INTO %s (%s) VALUES (", w.table, strings.Join(quoted, ", "))
+	case FormatCSV:
+		fields := make([]*string, len(columns))
+		for i := range columns {
+			fields[i] = &columns[i]
+		}
+		w.writeCSV(fields)
+	}
+	return w, nil
+}
+
+// WriteRow writes one row. Values are in text form, nil for null.
+func (w *Writer) WriteRow(values []*string) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("row has %d values, table has %d columns", len(values), len(w.columns))
+	}
+
+	switch w.format {
+	case FormatSQL:
+		w.w.WriteString(w.insert)
+		for i, value := range values {
+			if i > 0 {
+				w.w.WriteString(", ")
+			}
+			if value == nil {
+				w.w.WriteString("NULL")
+			} else {
+				w.w.WriteString(QuoteLiteral(*value))
+			}
+		}
+		w.w.WriteString(");\n")
+	case FormatCSV:
+		w.writeCSV(values)
+	case FormatJSON:
+		w.w.WriteByte('{')
+		for i, value := range values {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			name, _ := json.Marshal(w.columns[i])
+			w.w.Write(name)
+			w.w.WriteByte(':')
+			if value == nil {
+				w.w.WriteString("null")
+			} else {
+				data, _ := json.Marshal(*value)
+				w.w.Write(data)
+			}
+		}
+		w.w.WriteString("}\n")
+	}
+	w.rows++
+	return nil
+}
+
+// writeCSV writes a CSV line. Nulls are written as nothing and empty strings
+// as "", so COPY can tell them apart.
+func (w *Writer) writeCSV(values []*string) {
+	for i, value := range values {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		if value == nil {
+			continue
+		}
+		if *value == "" || strings.ContainsAny(*value, ",\"\r\n") || *value == `\.` {
+			w.w.WriteString(`"` + strings.Replace(*value, `"`, `""`, -1) + `"`)
+		} else {
+			w.w.WriteString(*value)
+		}
+	}
+	w.w.WriteByte('\n')
+}
+
+// Rows returns the number of rows written.
+func (w *Writer) Rows() int64 {
+	return w.rows
+}
+
+// Close flushes and closes the file.
+func (w *Writer) Close() error {
+	err := w.w.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// QuoteIdentifier quotes an SQL identifier. Names are always quoted, so
+// that keywords and mixed case survive.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// QuoteLiteral quotes a string as an SQL literal, using an escape string
+// literal if it contains backslashes.
+func QuoteLiteral(value string) string {
+	quoted := "'" + strings.Replace(value, "'", "''", -1) + "'"
+	if strings.Contains(value, `\`) {
+		return "E" + strings.Replace(quoted, `\`, `\\`, -1)
+	}
+	return quoted
+}
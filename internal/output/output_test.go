@@ -0,0 +1,115 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTable writes rows of a table with columns id and note in format.
+func writeTable(t *testing.T, format Format, rows ...[]*string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "t."+format.Extension())
+	w, err := Create(path, format, "public", "t", []string{"id", "note"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.Rows() != int64(len(rows)) {
+		t.Errorf("%s: %d rows written, want %d", format, w.Rows(), len(rows))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func str(s string) *string {
+	return &s
+}
+
+func TestWriter(t *testing.T) {
+	rows := [][]*string{
+		{str("1"), str("it's")},
+		{str("2"), nil},
+		{str("3"), str("")},
+		{str("4"), str("a,\"b\"\nc")},
+		{str("5"), str(`C:\`)},
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatSQL, `INSERT INTO "public"."t" ("id", "note") VALUES ('1', 'it''s');
+INSERT INTO "public"."t" ("id", "note") VALUES ('2', NULL);
+INSERT INTO "public"."t" ("id", "note") VALUES ('3', '');
+INSERT INTO "public"."t" ("id", "note") VALUES ('4', 'a,"b"
+c');
+INSERT INTO "public"."t" ("id", "note") VALUES ('5', E'C:\\');
+`},
+		{FormatCSV, `id,note
+1,it's
+2,
+3,""
+4,"a,""b""
+c"
+5,C:\
+`},
+		{FormatJSON, `{"id":"1","note":"it's"}
+{"id":"2","note":null}
+{"id":"3","note":""}
+{"id":"4","note":"a,\"b\"\nc"}
+{"id":"5","note":"C:\\"}
+`},
+	}
+
+	for _, test := range tests {
+		if got := writeTable(t, test.format, rows...); got != test.want {
+			t.Errorf("%s:\n%s\nwant\n%s", test.format, got, test.want)
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	w, err := Create(filepath.Join(t.TempDir(), "t.csv"), FormatCSV, "", "t", []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.WriteRow([]*string{str("1"), str("2")}); err == nil {
+		t.Error("row with too many values written")
+	}
+
+	if _, err := Create(filepath.Join(t.TempDir(), "missing", "t.csv"), FormatCSV, "", "t", nil); err == nil {
+		t.Error("file created in a missing directory")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"sql": FormatSQL, " CSV": FormatCSV, "json": FormatJSON} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %s (%v), want %s", name, got, err, want)
+		}
+	}
+	if got, err := ParseFormat("xml"); err == nil {
+		t.Errorf("ParseFormat(xml) = %s", got)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := QuoteIdentifier(`My "Table"`); got != `"My ""Table"""` {
+		t.Errorf("QuoteIdentifier: %s", got)
+	}
+	if got := QuoteLiteral(`it's a \n`); got != `E'it''s a \\n'` {
+		t.Errorf("QuoteLiteral: %s", got)
+	}
+}
@@ -0,0 +1,135 @@
+package pager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrorPolicy decides what the PageProcessor does with damaged pages and tuples.
+type ErrorPolicy int
+
+// Error policies
+const (
+	PolicyFailFast  ErrorPolicy = iota // stop at the first damaged page or tuple
+	PolicySkipPage                     // skip a page if it or any of its tuples is damaged
+	PolicySkipTuple                    // skip damaged pages, but only the damaged tuples of a valid page
+	PolicySalvage                      // like skip-tuple, and recover intact tuples from pages with bad line pointers
+)
+
+// DefaultErrorPolicy is the policy of a new PageProcessor.
+const DefaultErrorPolicy = PolicySkipTuple
+
+// ErrErrorBudgetExceeded is returned when more pages and tuples were skipped
+// than the error budget allows.
+var ErrErrorBudgetExceeded = errors.New("error budget exceeded")
+
+// errorPolicyNames maps configuration names to error policies.
+var errorPolicyNames = map[string]ErrorPolicy{
+	"fail-fast":  PolicyFailFast,
+	"skip-page":  PolicySkipPage,
+	"skip-tuple": PolicySkipTuple,
+	"salvage":    PolicySalvage,
+}
+
+// ParseErrorPolicy parses an error policy name such as "skip-page". An empty
+// name selects DefaultErrorPolicy.
+func ParseErrorPolicy(name string) (ErrorPolicy, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DefaultErrorPolicy, nil
+	}
+	if policy, ok := errorPolicyNames[name]; ok {
+		return policy, nil
+	}
+	return 0, fmt.Errorf("unknown error policy %q (use fail-fast, skip-page, skip-tuple or salvage)", name)
+}
+
+// String returns the configuration name of the policy.
+func (p ErrorPolicy) String() string {
+	switch p {
+	case PolicyFailFast:
+		return "fail-fast"
+	case PolicySkipPage:
+		return "skip-page"
+	case PolicySkipTuple:
+		return "skip-tuple"
+	case PolicySalvage:
+		return "salvage"
+	default:
+		return fmt.Sprintf("error-policy(%d)", int(p))
+	}
+}
+
+// salvageable reports whether tuples can still be recovered from a page of
+// the given class. Pages of the wrong size or version are not heap pages as
+// far as PDU can tell, so nothing on them can be trusted.
+func salvageable(class PageClass) bool {
+	switch class {
+	case PageHeaderCorrupt, PageLinePointerCorrupt, PageTornWrite:
+		return true
+	default:
+		return false
+	}
+}
+
+// DamagedBlock records a page that was skipped or salvaged.
+type DamagedBlock struct {
+	Block    int64     // block number within the relation fork
+	Class    PageClass // what is wrong with the page
+	Reason   string    // human-readable details
+	Salvaged int       // tuples recovered from the page, if it was salvaged
+	Skipped  bool      // the page was skipped entirely
+}
+
+// SkippedTuple records a tuple that was skipped.
+type SkippedTuple struct {
+	Block  int64  // block number within the relation fork
+	Item   int    // line pointer number, starting at 1
+	Reason string // human-readable details
+}
+
+// DamageReport summarizes the damage found while processing one relation fork.
+type DamageReport struct {
+	Path          string         // relation path
+	Blocks        int64          // blocks in the fork
+	Tuples        int64          // tuples returned
+	DamagedBlocks []DamagedBlock // pages that were skipped or salvaged
+	SkippedTuples []SkippedTuple // tuples that were skipped
+}
+
+// Damaged reports whether any page or tuple was skipped or salvaged.
+func (r *DamageReport) Damaged() bool {
+	return len(r.DamagedBlocks) > 0 || len(r.SkippedTuples) > 0
+}
+
+// Errors returns the number of skipped pages and tuples, which is what the
+// error budget is charged with.
+func (r *DamageReport) Errors() int {
+	count := len(r.SkippedTuples)
+	for _, block := range r.DamagedBlocks {
+		if block.Skipped {
+			count++
+		}
+	}
+	return count
+}
+
+// Print writes the report: a summary line followed by one line per damaged
+// block and skipped tuple.
+func (r *DamageReport) Print(w io.Writer) {
+	skipped := r.Errors() - len(r.SkippedTuples)
+	fmt.Fprintf(w, "%s: %d blocks, %d tuples, %d blocks skipped, %d blocks salvaged, %d tuples skipped\n",
+		r.Path, r.Blocks, r.Tuples, skipped, len(r.DamagedBlocks)-skipped, len(r.SkippedTuples))
+	for _, block := range r.DamagedBlocks {
+		if block.Skipped {
+			fmt.Fprintf(w, "  block %d: skipped: %s: %s\n", block.Block, block.Class, block.Reason)
+		} else {
+			fmt.Fprintf(w, "  block %d: salvaged %d tuples: %s: %s\n", block.Block, block.Salvaged, block.Class, block.Reason)
+		}
+	}
+	for _, tuple := range r.SkippedTuples {
+		fmt.Fprintf(w, "  block %d item %d: skipped: %s\n", tuple.Block, tuple.Item, tuple.Reason)
+	}
+}
@@ -0,0 +1,164 @@
+package pager
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+)
+
+// writeRelation writes the pages to a relation file and returns its path.
+func writeRelation(t *testing.T, pages ...[]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "16384")
+	if err := os.WriteFile(path, bytes.Join(pages, nil), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// damagedRelation writes a relation of four blocks: two valid tuples, a page
+// with an unaligned pd_special whose tuple can be salvaged, a valid page with
+// one corrupt tuple and a page of an unknown layout version.
+func damagedRelation(t *testing.T, order binary.ByteOrder) string {
+	salvageable := testPage(order, testTuple(order, 3))
+	order.PutUint16(salvageable[16:], 8188)
+	badTuple := testTuple(order, 5)
+	badTuple[22] = 40
+	wrongVersion := testPage(order, testTuple(order, 6))
+	order.PutUint16(wrongVersion[18:], 8192|3)
+
+	return writeRelation(t,
+		testPage(order, testTuple(order, 1), testTuple(order, 2)),
+		salvageable,
+		testPage(order, testTuple(order, 4), badTuple),
+		wrongVersion,
+	)
+}
+
+// processRelation reads every tuple of a relation with an error policy.
+func processRelation(t *testing.T, path string, policy ErrorPolicy, maxErrors int) (*DamageReport, error) {
+	t.Helper()
+	params := testParams(binary.LittleEndian)
+	processor := NewPageProcessor(fileio.NewPgFileReader(params), NewPageParser(params))
+	processor.SetErrorPolicy(policy, maxErrors)
+	if err := processor.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Close()
+	err := processor.ForEachTuple(context.Background(), func(t *ScannedTuple) error { return nil })
+	return processor.Damage(), err
+}
+
+func TestErrorPolicies(t *testing.T) {
+	path := damagedRelation(t, binary.LittleEndian)
+	tests := []struct {
+		policy ErrorPolicy
+		tuples int64
+		blocks []DamagedBlock
+		items  []SkippedTuple
+	}{
+		{PolicySkipPage, 2, []DamagedBlock{
+			{Block: 1, Class: PageHeaderCorrupt, Reason: "pd_special 8188 is not aligned to 8", Skipped: true},
+			{Block: 2, Class: PageTupleCorrupt, Reason: "item 2: invalid tuple header: t_hoff 40 exceeds tuple length 28", Skipped: true},
+			{Block: 3, Class: PageWrongVersion, Reason: "page layout version 3, expected 4", Skipped: true},
+		}, nil},
+		{PolicySkipTuple, 3, []DamagedBlock{
+			{Block: 1, Class: PageHeaderCorrupt, Reason: "pd_special 8188 is not aligned to 8", Skipped: true},
+			{Block: 3, Class: PageWrongVersion, Reason: "page layout version 3, expected 4", Skipped: true},
+		}, []SkippedTuple{
+			{Block: 2, Item: 2, Reason: "invalid tuple header: t_hoff 40 exceeds tuple length 28"},
+		}},
+		{PolicySalvage, 4, []DamagedBlock{
+			{Block: 1, Class: PageHeaderCorrupt, Reason: "pd_special 8188 is not aligned to 8", Salvaged: 1},
+			{Block: 3, Class: PageWrongVersion, Reason: "page layout version 3, expected 4", Skipped: true},
+		}, []SkippedTuple{
+			{Block: 2, Item: 2, Reason: "invalid tuple header: t_hoff 40 exceeds tuple length 28"},
+		}},
+	}
+
+	for _, test := range tests {
+		report, err := processRelation(t, path, test.policy, 0)
+		if err != nil {
+			t.Errorf("%s: %v", test.policy, err)
+			continue
+		}
+		if report.Blocks != 4 || report.Tuples != test.tuples || !report.Damaged() {
+			t.Errorf("%s: %d blocks, %d tuples, want 4 blocks, %d tuples", test.policy, report.Blocks, report.Tuples, test.tuples)
+		}
+		if !reflect.DeepEqual(report.DamagedBlocks, test.blocks) || !reflect.DeepEqual(report.SkippedTuples, test.items) {
+			t.Errorf("%s: damaged blocks %+v, skipped tuples %+v", test.policy, report.DamagedBlocks, report.SkippedTuples)
+		}
+	}
+
+	// Fail-fast stops at the first damaged page
+	_, err := processRelation(t, path, PolicyFailFast, 0)
+	var perr *PageError
+	if !errors.As(err, &perr) || perr.Block != 1 || perr.Class != PageHeaderCorrupt || perr.Relation != path {
+		t.Errorf("fail-fast: %v", err)
+	}
+}
+
+func TestErrorBudget(t *testing.T) {
+	path := damagedRelation(t, binary.LittleEndian)
+
+	// Three pages and tuples are skipped, one more than the budget
+	report, err := processRelation(t, path, PolicySkipTuple, 2)
+	if !errors.Is(err, ErrErrorBudgetExceeded) || report.Errors() != 3 {
+		t.Errorf("budget of 2: %d errors (%v)", report.Errors(), err)
+	}
+	if _, err := processRelation(t, path, PolicySkipTuple, 3); err != nil {
+		t.Errorf("budget of 3: %v", err)
+	}
+
+	// Salvaged pages are not charged
+	if _, err := processRelation(t, path, PolicySalvage, 2); err != nil {
+		t.Errorf("salvage with a budget of 2: %v", err)
+	}
+}
+
+func TestDamageReportPrint(t *testing.T) {
+	report := &DamageReport{
+		Path:   "base/5/16384",
+		Blocks: 4,
+		Tuples: 4,
+		DamagedBlocks: []DamagedBlock{
+			{Block: 1, Class: PageHeaderCorrupt, Reason: "pd_special 8188 is not aligned to 8", Salvaged: 1},
+			{Block: 3, Class: PageWrongVersion, Reason: "page layout version 3, expected 4", Skipped: true},
+		},
+		SkippedTuples: []SkippedTuple{{Block: 2, Item: 2, Reason: "invalid tuple header"}},
+	}
+	var out bytes.Buffer
+	report.Print(&out)
+	want := `base/5/16384: 4 blocks, 4 tuples, 1 blocks skipped, 1 blocks salvaged, 1 tuples skipped
+  block 1: salvaged 1 tuples: header-corrupt: pd_special 8188 is not aligned to 8
+  block 3: skipped: wrong-page-version: page layout version 3, expected 4
+  block 2 item 2: skipped: invalid tuple header
+`
+	if out.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for name, want := range map[string]ErrorPolicy{
+		"":            DefaultErrorPolicy,
+		"fail-fast":   PolicyFailFast,
+		" Skip-Page ": PolicySkipPage,
+		"skip-tuple":  PolicySkipTuple,
+		"salvage":     PolicySalvage,
+	} {
+		if policy, err := ParseErrorPolicy(name); err != nil || policy != want {
+			t.Errorf("%q: %s (%v), want %s", name, policy, err, want)
+		}
+	}
+	if policy, err := ParseErrorPolicy("ignore"); err == nil {
+		t.Errorf("ignore: %s, want an error", policy)
+	}
+}
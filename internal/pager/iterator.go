@@ -0,0 +1,128 @@
+package pager
+
+import (
+	"context"
+	"errors"
+)
+
+// ScannedTuple is a tuple together with the place it was read from.
+type ScannedTuple struct {
+	// The tuple itself
+	Tuple *Tuple
+
+	// Page the tuple was read from, with its header, checksum and validation results
+	Page *Page
+
+	// Block number within the relation fork
+	Block int64
+
+	// Line pointer number (OffsetNumber), starting at 1
+	Item int
+
+	// Byte offset of the tuple within the page (lp_off)
+	Offset int
+}
+
+// TupleFunc is called by ForEachTuple for every tuple. The tuple, its data
+// and its page are only valid until the last tuple of the page has been
+// passed to fn and fn has returned, as the page buffer is then reused; a
+// TupleFunc that keeps any of them must copy them. Returning
+// ErrStopIteration ends the iteration without an error.
+type TupleFunc func(t *ScannedTuple) error
+
+// ErrStopIteration can be returned by a TupleFunc to stop ForEachTuple early.
+var ErrStopIteration = errors.New("stop iteration")
+
+// ForEachTuple reads the file one page at a time and calls fn for every
+// tuple, in block and line pointer order. Damaged pages and tuples are handled
+// according to the error policy; what was skipped is available from Damage
+// afterwards. Iteration stops when ctx is cancelled.
+func (p *PageProcessor) ForEachTuple(ctx context.Context, fn TupleFunc) error {
+	p.ResetDamage()
+
+	// Process each page
+	pageCount := p.reader.GetPageCount()
+	for block := int64(0); block < pageCount; block++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pageData, err := p.reader.ReadPage(block)
+		err = p.forEachBlockTuple(block, pageData, err, fn)
+		p.ReleaseBlock(pageData)
+		if errors.Is(err, ErrStopIteration) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forEachBlockTuple processes a page read by ForEachTuple and calls fn for
+// each of its tuples. The caller releases the page once it returns.
+func (p *PageProcessor) forEachBlockTuple(block int64, pageData []byte, readErr error, fn TupleFunc) error {
+	result, err := p.ProcessBlock(block, pageData, readErr)
+	if err != nil {
+		return err
+	}
+	if err := p.RecordBlock(result); err != nil {
+		return err
+	}
+
+	// Hand out the tuples of this page
+	for _, tuple := range result.Tuples {
+		if err := fn(tuple); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tuples runs ForEachTuple in a goroutine and returns its tuples on a
+// channel. As the consumer reads them after ForEachTuple has moved on, the
+// tuples are sent with copies of their data, null bitmap and page. The tuple
+// channel is closed when the iteration ends, after which the error channel
+// yields its result. A consumer that stops reading early must cancel ctx to
+// release the goroutine.
+func (p *PageProcessor) Tuples(ctx context.Context) (<-chan *ScannedTuple, <-chan error) {
+	tuples := make(chan *ScannedTuple)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(tuples)
+
+		var source, page *Page
+		errc <- p.ForEachTuple(ctx, func(t *ScannedTuple) error {
+			if t.Page != source {
+				copied := *t.Page
+				copied.RawData = append([]byte(nil), t.Page.RawData...)
+				source, page = t.Page, &copied
+			}
+			select {
+			case tuples <- t.detach(page):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return tuples, errc
+}
+
+// detach returns a copy of t that does not share memory with the page
+// buffer, with page as its Page: a copy of t.Page made by the caller, which
+// can share it between the tuples of one page.
+func (t *ScannedTuple) detach(page *Page) *ScannedTuple {
+	tuple := *t.Tuple
+	tuple.Data = append([]byte(nil), t.Tuple.Data...)
+	tuple.Header.TBits = append([]uint8(nil), t.Tuple.Header.TBits...)
+	detached := *t
+	detached.Tuple = &tuple
+	detached.Page = page
+	return &detached
+}
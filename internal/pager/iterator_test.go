@@ -0,0 +1,150 @@
+package pager
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+)
+
+// openRelation opens a relation file for iteration.
+func openRelation(t *testing.T, order binary.ByteOrder, path string) *PageProcessor {
+	t.Helper()
+	params := testParams(order)
+	processor := NewPageProcessor(fileio.NewPgFileReader(params), NewPageParser(params))
+	if err := processor.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { processor.Close() })
+	return processor
+}
+
+// scanned is where a tuple holding value was found.
+type scanned struct {
+	block  int64
+	item   int
+	offset int
+	value  uint32
+}
+
+func TestForEachTuple(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		path := writeRelation(t,
+			testPage(order, testTuple(order, 1), testTuple(order, 2)),
+			make([]byte, 8192),
+			testPage(order, testTuple(order, 3)),
+		)
+		processor := openRelation(t, order, path)
+
+		var got []scanned
+		err := processor.ForEachTuple(context.Background(), func(st *ScannedTuple) error {
+			if st.Page.BlockNumber != st.Block {
+				t.Errorf("%s: tuple of block %d on page %d", order, st.Block, st.Page.BlockNumber)
+			}
+			got = append(got, scanned{st.Block, st.Item, st.Offset, order.Uint32(st.Tuple.Data)})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		want := []scanned{{0, 1, 8160, 1}, {0, 2, 8128, 2}, {2, 1, 8160, 3}}
+		if len(got) != len(want) {
+			t.Fatalf("%s: %+v, want %+v", order, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: tuple %d is %+v, want %+v", order, i, got[i], want[i])
+			}
+		}
+		if damage := processor.Damage(); damage.Blocks != 3 || damage.Tuples != 3 || damage.Damaged() {
+			t.Errorf("%s: %d blocks, %d tuples, damaged %v", order, damage.Blocks, damage.Tuples, damage.Damaged())
+		}
+	}
+}
+
+func TestForEachTupleStop(t *testing.T) {
+	order := binary.LittleEndian
+	path := writeRelation(t,
+		testPage(order, testTuple(order, 1), testTuple(order, 2)),
+		testPage(order, testTuple(order, 3)),
+	)
+	processor := openRelation(t, order, path)
+
+	// ErrStopIteration ends the iteration without an error
+	count := 0
+	err := processor.ForEachTuple(context.Background(), func(st *ScannedTuple) error {
+		count++
+		return ErrStopIteration
+	})
+	if err != nil || count != 1 {
+		t.Errorf("stopped: %d tuples (%v)", count, err)
+	}
+
+	// Other errors are returned as is
+	errTest := errors.New("test")
+	if err := processor.ForEachTuple(context.Background(), func(st *ScannedTuple) error { return errTest }); err != errTest {
+		t.Errorf("failed: %v", err)
+	}
+
+	// Cancellation is checked before each page
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err = processor.ForEachTuple(ctx, func(st *ScannedTuple) error {
+		count++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || count != 2 {
+		t.Errorf("cancelled: %d tuples (%v)", count, err)
+	}
+}
+
+func TestTuples(t *testing.T) {
+	order := binary.LittleEndian
+	path := writeRelation(t,
+		testPage(order, testTuple(order, 1), testTuple(order, 2)),
+		testPage(order, testTuple(order, 3)),
+	)
+	processor := openRelation(t, order, path)
+
+	var values []uint32
+	tuples, errc := processor.Tuples(context.Background())
+	for st := range tuples {
+		values = append(values, order.Uint32(st.Tuple.Data))
+	}
+	if err := <-errc; err != nil || len(values) != 3 || values[0] != 1 || values[2] != 3 {
+		t.Errorf("values %v (%v)", values, err)
+	}
+
+	// A consumer that stops early cancels the producer
+	ctx, cancel := context.WithCancel(context.Background())
+	tuples, errc = processor.Tuples(ctx)
+	<-tuples
+	cancel()
+	for range tuples {
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: %v", err)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	buffer := []byte{0x01, 0x2A, 0x00, 0x00, 0x00}
+	tuple := &Tuple{Data: buffer[1:]}
+	tuple.Header.TBits = buffer[:1]
+	st := &ScannedTuple{Block: 3, Tuple: tuple}
+
+	// Nothing of the detached tuple changes when the page buffer is reused
+	page := &Page{}
+	detached := st.detach(page)
+	for i := range buffer {
+		buffer[i] = 0xFF
+	}
+	if detached.Block != 3 || detached.Page != page || detached.Tuple == tuple ||
+		detached.Tuple.Data[0] != 0x2A || detached.Tuple.Header.TBits[0] != 0x01 {
+		t.Errorf("detached tuple %+v, data % x, null bitmap % x",
+			detached, detached.Tuple.Data, detached.Tuple.Header.TBits)
+	}
+}
@@ -2,6 +2,8 @@
 package pager
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/wublabdubdub/pdu/internal/fileio"
@@ -10,14 +12,46 @@ import (
 
 // PageParser is an interface for parsing PostgreSQL data pages.
 type PageParser interface {
-	// ParsePage parses a single page from a byte slice
+	// ParsePage parses and validates a single page from a byte slice. A page
+	// that fails validation is returned together with a *PageError.
 	ParsePage(pageData []byte) (*Page, error)
+
+	// ParsePageHeader parses only the page header, leaving the line pointers unread
+	ParsePageHeader(pageData []byte) (*Page, error)
 	
 	// GetTuples extracts all tuples from a page
 	GetTuples(page *Page) ([]*Tuple, error)
 	
 	// ParseTuple parses a tuple from a byte slice
 	ParseTuple(data []byte) (*Tuple, error)
+
+	// VerifyChecksum checks a page's checksum against its block number and records the result in the page
+	VerifyChecksum(page *Page, blockNumber int64) ChecksumStatus
+}
+
+// ChecksumStatus is the outcome of verifying a page checksum.
+type ChecksumStatus int
+
+// Checksum verification outcomes
+const (
+	ChecksumNotVerified ChecksumStatus = iota // verification was not requested
+	ChecksumOK                                // checksum matches, or the page is new and all zero
+	ChecksumMismatch                          // checksum does not match the page contents
+	ChecksumsDisabled                         // the cluster was initialized without data checksums
+)
+
+// String returns the name of the checksum status.
+func (s ChecksumStatus) String() string {
+	switch s {
+	case ChecksumOK:
+		return "checksum-ok"
+	case ChecksumMismatch:
+		return "checksum-mismatch"
+	case ChecksumsDisabled:
+		return "checksums-disabled"
+	default:
+		return "not-verified"
+	}
 }
 
 // Page represents a PostgreSQL data page.
@@ -33,6 +67,18 @@ type Page struct {
 	
 	// Number of item IDs
 	ItemCount int
+
+	// Result of page validation
+	Class PageClass
+
+	// Block number within the relation fork, set by the PageProcessor
+	BlockNumber int64
+
+	// Result of checksum verification
+	Checksum ChecksumStatus
+
+	// Checksum computed from the page contents, if verified
+	ComputedChecksum uint16
 }
 
 // Tuple represents a PostgreSQL tuple.
@@ -40,47 +86,135 @@ type Tuple struct {
 	// Tuple header data
 	Header pgtypes.HeapTupleHeaderData
 	
-	// Tuple data (after header)
+	// User data, starting at t_hoff
 	Data []byte
+
+	// Object ID stored in the header (only with HEAP_HASOID, before PostgreSQL 12)
+	Oid uint32
 	
 	// Tuple size in bytes
 	Size int
 }
 
-// NewPageParser creates a new PageParser instance.
-func NewPageParser() PageParser {
-	return &PgPageParser{}
+// NewPageParser creates a new PageParser instance for pages of the block size,
+// byte order and PostgreSQL version given in params.
+func NewPageParser(params pgtypes.StorageParams) PageParser {
+	return &PgPageParser{
+		order:     params.ByteOrder,
+		blockSize: params.BlockSize,
+		profile:   params.Profile,
+		checksums: params.DataChecksums,
+		maxAlign:  params.MaxAlign,
+	}
 }
 
 // PgPageParser implements PageParser for PostgreSQL data pages.
-type PgPageParser struct {}
+type PgPageParser struct {
+	order     binary.ByteOrder
+	blockSize int
+	profile   *pgtypes.Profile
+	checksums bool
+	maxAlign  int
+}
 
-// ParsePage parses a single page from a byte slice.
+// ParsePageHeader parses only the page header from a byte slice.
+func (p *PgPageParser) ParsePageHeader(pageData []byte) (*Page, error) {
+	// Check if page data is a full page
+	if len(pageData) < p.blockSize {
+		return nil, fmt.Errorf("page data too short: expected at least %d bytes, got %d", p.blockSize, len(pageData))
+	}
+
+	return &Page{
+		Header:  pgtypes.ReadHeapPageHeader(pageData, p.order),
+		RawData: pageData,
+	}, nil
+}
+
+// ParsePage parses a single page from a byte slice and validates its header
+// and line pointers. All-zero pages are returned as empty pages of class
+// PageNew. Pages that fail validation are returned with their class set and
+// a *PageError; their line pointers are read if pd_lower lies within the page,
+// so that intact tuples can be salvaged.
 func (p *PgPageParser) ParsePage(pageData []byte) (*Page, error) {
 	// Check if page data is at least the size of a page header
-	if len(pageData) < pgtypes.BLCKSZ {
-		return nil, fmt.Errorf("page data too short: expected at least %d bytes, got %d", pgtypes.BLCKSZ, len(pageData))
+	if len(pageData) < p.blockSize {
+		return nil, fmt.Errorf("page data too short: expected at least %d bytes, got %d", p.blockSize, len(pageData))
 	}
 
-	// Read page header
-	header := pgtypes.ReadHeapPageHeader(pageData)
+	// Read and validate page header
+	page := &Page{
+		Header:  pgtypes.ReadHeapPageHeader(pageData, p.order),
+		RawData: pageData,
+	}
+	perr := p.validateHeader(page.Header, pageData)
+	if perr != nil && perr.Class == PageNew {
+		page.Class = PageNew
+		return page, nil
+	}
 
-	// Calculate number of item IDs
-	itemCount := int((header.PDLower - uint16(pgtypes.SizeOfPageHeaderData)) / uint16(pgtypes.SizeOfItemIdData))
+	// Read item IDs, as far as pd_lower can be trusted
+	p.readItemIds(page)
+	if perr != nil {
+		page.Class = perr.Class
+		return page, perr
+	}
+
+	// Validate item IDs
+	if perr := p.validateItemIds(page.Header, page.ItemIds, pageData); perr != nil {
+		page.Class = perr.Class
+		return page, perr
+	}
+
+	return page, nil
+}
+
+// readItemIds reads the line pointers between the page header and pd_lower,
+// unless pd_lower lies outside the page.
+func (p *PgPageParser) readItemIds(page *Page) {
+	lower := int(page.Header.PDLower)
+	if lower < pgtypes.SizeOfPageHeaderData || lower > p.blockSize {
+		return
+	}
 
-	// Read item IDs
-	itemIds := make([]pgtypes.ItemIdData, itemCount)
+	itemCount := (lower - pgtypes.SizeOfPageHeaderData) / pgtypes.SizeOfItemIdData
+	page.ItemIds = make([]pgtypes.ItemIdData, itemCount)
 	for i := 0; i < itemCount; i++ {
 		offset := pgtypes.SizeOfPageHeaderData + i*pgtypes.SizeOfItemIdData
-		itemIds[i] = pgtypes.ReadItemIdData(pageData, offset)
+		page.ItemIds[i] = pgtypes.ReadItemIdData(page.RawData, offset, p.order)
 	}
+	page.ItemCount = itemCount
+}
 
-	return &Page{
-		Header:    header,
-		RawData:   pageData,
-		ItemIds:   itemIds,
-		ItemCount: itemCount,
-	}, nil
+// VerifyChecksum checks a page's checksum against its block number. New
+// pages (pd_upper is zero) carry no checksum and are accepted if they are
+// entirely zero, as PostgreSQL does.
+func (p *PgPageParser) VerifyChecksum(page *Page, blockNumber int64) ChecksumStatus {
+	switch {
+	case !p.checksums:
+		page.Checksum = ChecksumsDisabled
+	case page.Header.PDUpper == 0:
+		page.Checksum = ChecksumOK
+		if !isZero(page.RawData) {
+			page.Checksum = ChecksumMismatch
+		}
+	default:
+		page.ComputedChecksum = pgtypes.PageChecksum(page.RawData[:p.blockSize], uint32(blockNumber), p.order)
+		page.Checksum = ChecksumOK
+		if page.ComputedChecksum != page.Header.PDChecksum {
+			page.Checksum = ChecksumMismatch
+		}
+	}
+	return page.Checksum
+}
+
+// isZero checks if every byte of data is zero.
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // GetTuples extracts all tuples from a page.
@@ -128,32 +262,71 @@ func (p *PgPageParser) ParseTuple(data []byte) (*Tuple, error) {
 			pgtypes.SizeOfHeapTupleHeader, len(data))
 	}
 
-	// Create tuple
-	tuple := &Tuple{
-		Data: data,
+	// Parse the fixed part of the tuple header
+	header := pgtypes.ReadHeapTupleHeader(data, p.order)
+
+	// Validate number of attributes
+	natts := pgtypes.HeapTupleHeaderGetNatts(header)
+	if natts > pgtypes.MaxHeapAttributeNumber {
+		return nil, fmt.Errorf("invalid tuple header: natts %d exceeds maximum %d",
+			natts, pgtypes.MaxHeapAttributeNumber)
+	}
+
+	// Validate header size
+	hoff := int(header.THoff)
+	minHoff := pgtypes.SizeOfHeapTupleHeader
+	if pgtypes.HeapTupleHasNulls(header) {
+		minHoff += pgtypes.BitmapLen(natts)
+	}
+	hasOid := header.TInfomask&pgtypes.HEAP_HASOID != 0
+	if hasOid {
+		if !p.profile.TuplesHaveOids {
+			return nil, fmt.Errorf("invalid tuple header: HEAP_HASOID set, but %s tuples have no OIDs", p.profile)
+		}
+		minHoff += 4
+	}
+	if hoff < minHoff {
+		return nil, fmt.Errorf("invalid tuple header: t_hoff %d is smaller than header, null bitmap and OID (%d bytes)",
+			hoff, minHoff)
+	}
+	if hoff > len(data) {
+		return nil, fmt.Errorf("invalid tuple header: t_hoff %d exceeds tuple length %d", hoff, len(data))
 	}
 
-	// Parse tuple header
-	// Note: This is a simplified implementation. A full implementation would need to
-	// parse the actual tuple header fields according to PostgreSQL's format.
+	// Validate moved-by-VACUUM flags, which are mutually exclusive
+	if header.TInfomask&pgtypes.HEAP_MOVED == pgtypes.HEAP_MOVED {
+		return nil, fmt.Errorf("invalid tuple header: both HEAP_MOVED_OFF and HEAP_MOVED_IN set")
+	}
 
-	return tuple, nil
-}
+	// Attach the null bitmap
+	if pgtypes.HeapTupleHasNulls(header) {
+		header.TBits = data[pgtypes.SizeOfHeapTupleHeader : pgtypes.SizeOfHeapTupleHeader+pgtypes.BitmapLen(natts)]
+	}
 
-// SizeOfPageHeaderData is the size of a page header in bytes.
-const SizeOfPageHeaderData = 24
+	// Create tuple with user data starting at t_hoff
+	tuple := &Tuple{
+		Header: header,
+		Data:   data[hoff:],
+		Size:   len(data),
+	}
 
-// SizeOfItemIdData is the size of an item ID in bytes.
-const SizeOfItemIdData = 4
+	// The OID is stored just before the user data
+	if hasOid {
+		tuple.Oid = p.order.Uint32(data[hoff-4 : hoff])
+	}
 
-// SizeOfHeapTupleHeader is the size of a heap tuple header in bytes.
-const SizeOfHeapTupleHeader = 23
+	return tuple, nil
+}
 
 // PageProcessor processes PostgreSQL data pages from a file.
 type PageProcessor struct {
-	reader   fileio.FileReader
-	parser   PageParser
-	filePath string
+	reader          fileio.FileReader
+	parser          PageParser
+	filePath        string
+	verifyChecksums bool
+	policy          ErrorPolicy
+	maxErrors       int
+	damage          *DamageReport
 }
 
 // NewPageProcessor creates a new PageProcessor instance.
@@ -161,9 +334,28 @@ func NewPageProcessor(reader fileio.FileReader, parser PageParser) *PageProcesso
 	return &PageProcessor{
 		reader: reader,
 		parser: parser,
+		policy: DefaultErrorPolicy,
 	}
 }
 
+// SetVerifyChecksums enables checksum verification of every processed page.
+func (p *PageProcessor) SetVerifyChecksums(enabled bool) {
+	p.verifyChecksums = enabled
+}
+
+// SetErrorPolicy sets what ForEachTuple does with damaged pages and tuples,
+// and how many pages and tuples it may skip before giving up. A maxErrors of
+// zero means no limit.
+func (p *PageProcessor) SetErrorPolicy(policy ErrorPolicy, maxErrors int) {
+	p.policy = policy
+	p.maxErrors = maxErrors
+}
+
+// Damage returns the damage found by the last call to ForEachTuple.
+func (p *PageProcessor) Damage() *DamageReport {
+	return p.damage
+}
+
 // Open opens a file for processing.
 func (p *PageProcessor) Open(filePath string) error {
 	p.filePath = filePath
@@ -175,7 +367,9 @@ func (p *PageProcessor) Close() error {
 	return p.reader.Close()
 }
 
-// ProcessPage processes a single page from the file.
+// ProcessPage processes a single page from the file. If the page fails
+// validation, the page is returned with a *PageError that names the relation
+// and block, so that callers can skip it and continue with the next page.
 func (p *PageProcessor) ProcessPage(pageNumber int64) (*Page, []*Tuple, error) {
 	// Read page data
 	pageData, err := p.reader.ReadPage(pageNumber)
@@ -185,9 +379,22 @@ func (p *PageProcessor) ProcessPage(pageNumber int64) (*Page, []*Tuple, error) {
 
 	// Parse page
 	page, err := p.parser.ParsePage(pageData)
+	var perr *PageError
+	if errors.As(err, &perr) {
+		perr.Relation = p.filePath
+		perr.Block = pageNumber
+		page.BlockNumber = pageNumber
+		return page, nil, perr
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to parse page %d: %v", pageNumber, err)
 	}
+	page.BlockNumber = pageNumber
+
+	// Verify checksum
+	if p.verifyChecksums {
+		p.parser.VerifyChecksum(page, pageNumber)
+	}
 
 	// Get tuples from page
 	tuples, err := p.parser.GetTuples(page)
@@ -198,24 +405,186 @@ func (p *PageProcessor) ProcessPage(pageNumber int64) (*Page, []*Tuple, error) {
 	return page, tuples, nil
 }
 
-// ProcessAllPages processes all pages from the file.
-func (p *PageProcessor) ProcessAllPages() error {
-	// Get page count
-	pageCount := p.reader.GetPageCount()
+// BlockResult holds the tuples of one page and the damage found on it.
+type BlockResult struct {
+	Block         int64           // block number within the relation fork
+	Tuples        []*ScannedTuple // tuples that were read
+	Damaged       *DamagedBlock   // set if the page was skipped or salvaged
+	SkippedTuples []SkippedTuple  // tuples that were skipped
+}
+
+// PageCount returns the number of pages in the open file.
+func (p *PageProcessor) PageCount() int64 {
+	return p.reader.GetPageCount()
+}
+
+// ReadBlock reads the data of a page from the open file.
+func (p *PageProcessor) ReadBlock(block int64) ([]byte, error) {
+	return p.reader.ReadPage(block)
+}
+
+// ReleaseBlock hands the data of a page read by ReadBlock back to the
+// reader's page pool, if it has one. Nothing parsed from the page, such as
+// its tuples and their data, may be used afterwards.
+func (p *PageProcessor) ReleaseBlock(pageData []byte) {
+	if releaser, ok := p.reader.(fileio.PageReleaser); ok && pageData != nil {
+		releaser.ReleasePage(pageData)
+	}
+}
+
+// ResetDamage starts a new damage report for the open file.
+func (p *PageProcessor) ResetDamage() {
+	p.damage = &DamageReport{Path: p.filePath, Blocks: p.reader.GetPageCount()}
+}
+
+// ProcessBlock parses a page read by ReadBlock, where readErr is the error
+// ReadBlock returned, and applies the error policy to anything that is
+// damaged. It only returns an error, a *PageError, under the fail-fast policy.
+// ProcessBlock does not change the processor, so pages can be processed
+// concurrently; the results are then passed to RecordBlock in one goroutine.
+func (p *PageProcessor) ProcessBlock(block int64, pageData []byte, readErr error) (*BlockResult, error) {
+	result := &BlockResult{Block: block}
+
+	// Skip unreadable pages
+	if readErr != nil {
+		return result, p.skipBlock(result, PageUnreadable, readErr.Error())
+	}
+
+	// Parse page, salvaging what can be salvaged
+	page, err := p.parser.ParsePage(pageData)
+	var perr *PageError
+	switch {
+	case errors.As(err, &perr):
+		if p.policy != PolicySalvage || !salvageable(perr.Class) {
+			return result, p.skipBlock(result, perr.Class, perr.Reason)
+		}
+	case err != nil:
+		return result, p.skipBlock(result, PageUnreadable, err.Error())
+	}
+	page.BlockNumber = block
+
+	// Verify checksum
+	if p.verifyChecksums {
+		p.parser.VerifyChecksum(page, block)
+	}
+
+	// Parse tuples one at a time, so that a bad tuple need not cost the page
+	for i, itemId := range page.ItemIds {
+		// Skip unused, redirected, or dead items
+		if !pgtypes.ItemIdIsUsed(itemId) || !pgtypes.ItemIdHasStorage(itemId) {
+			continue
+		}
 
-	// Process each page
-	for pageNumber := int64(0); pageNumber < pageCount; pageNumber++ {
-		// Process page
-		page, tuples, err := p.ProcessPage(pageNumber)
+		tuple, err := p.parseItem(page, itemId)
 		if err != nil {
-			return fmt.Errorf("failed to process page %d: %v", pageNumber, err)
+			if p.policy == PolicyFailFast || p.policy == PolicySkipPage {
+				return result, p.skipBlock(result, PageTupleCorrupt, fmt.Sprintf("item %d: %v", i+1, err))
+			}
+			result.SkippedTuples = append(result.SkippedTuples, SkippedTuple{
+				Block:  block,
+				Item:   i + 1,
+				Reason: err.Error(),
+			})
+			continue
+		}
+		result.Tuples = append(result.Tuples, &ScannedTuple{
+			Tuple:  tuple,
+			Page:   page,
+			Block:  block,
+			Item:   i + 1,
+			Offset: int(pgtypes.ItemIdGetOffset(itemId)),
+		})
+	}
+
+	// Record the salvaged page
+	if perr != nil {
+		result.Damaged = &DamagedBlock{
+			Block:    block,
+			Class:    perr.Class,
+			Reason:   perr.Reason,
+			Salvaged: len(result.Tuples),
 		}
+	}
+
+	return result, nil
+}
 
-		// Print page information
-		fmt.Printf("Page %d: %d tuples\n", pageNumber, len(tuples))
+// RejectTuple applies the error policy to a tuple of result that a later
+// stage, such as decoding, found to be damaged: the tuple is dropped, or the
+// whole page under skip-page. Under fail-fast a *PageError is returned.
+func (p *PageProcessor) RejectTuple(result *BlockResult, tuple *ScannedTuple, reason error) error {
+	if p.policy == PolicyFailFast || p.policy == PolicySkipPage {
+		return p.skipBlock(result, PageTupleCorrupt, fmt.Sprintf("item %d: %v", tuple.Item, reason))
+	}
+
+	// Drop the tuple
+	for i, t := range result.Tuples {
+		if t == tuple {
+			result.Tuples = append(result.Tuples[:i], result.Tuples[i+1:]...)
+			break
+		}
+	}
+	result.SkippedTuples = append(result.SkippedTuples, SkippedTuple{
+		Block:  result.Block,
+		Item:   tuple.Item,
+		Reason: reason.Error(),
+	})
+	if result.Damaged != nil {
+		result.Damaged.Salvaged = len(result.Tuples)
+	}
+
+	return nil
+}
 
-		// TODO: Process tuples
+// RecordBlock adds the damage found on a page to the damage report. It
+// returns ErrErrorBudgetExceeded once more pages and tuples were skipped
+// than allowed.
+func (p *PageProcessor) RecordBlock(result *BlockResult) error {
+	p.damage.Tuples += int64(len(result.Tuples))
+	if result.Damaged != nil {
+		p.damage.DamagedBlocks = append(p.damage.DamagedBlocks, *result.Damaged)
 	}
+	p.damage.SkippedTuples = append(p.damage.SkippedTuples, result.SkippedTuples...)
 
+	if p.maxErrors > 0 && p.damage.Errors() > p.maxErrors {
+		return fmt.Errorf("%s: %d pages and tuples skipped, more than %d: %w",
+			p.filePath, p.damage.Errors(), p.maxErrors, ErrErrorBudgetExceeded)
+	}
+	return nil
+}
+
+// parseItem parses the tuple a line pointer points to. The line pointer is
+// checked against the page bounds only, as it may come from a damaged page.
+func (p *PageProcessor) parseItem(page *Page, itemId pgtypes.ItemIdData) (*Tuple, error) {
+	offset := int(pgtypes.ItemIdGetOffset(itemId))
+	length := int(pgtypes.ItemIdGetLength(itemId))
+	if offset < pgtypes.SizeOfPageHeaderData+page.ItemCount*pgtypes.SizeOfItemIdData || offset+length > len(page.RawData) {
+		return nil, fmt.Errorf("tuple is out of bounds: offset=%d, length=%d, page size=%d",
+			offset, length, len(page.RawData))
+	}
+
+	tuple, err := p.parser.ParseTuple(page.RawData[offset : offset+length])
+	if err != nil {
+		return nil, err
+	}
+	tuple.Size = length
+
+	return tuple, nil
+}
+
+// skipBlock marks the page of result as skipped, dropping its tuples, or
+// returns it as a *PageError if the policy is fail-fast.
+func (p *PageProcessor) skipBlock(result *BlockResult, class PageClass, reason string) error {
+	if p.policy == PolicyFailFast {
+		return &PageError{Relation: p.filePath, Block: result.Block, Class: class, Reason: reason}
+	}
+	result.Tuples = nil
+	result.SkippedTuples = nil
+	result.Damaged = &DamagedBlock{
+		Block:   result.Block,
+		Class:   class,
+		Reason:  reason,
+		Skipped: true,
+	}
 	return nil
 }
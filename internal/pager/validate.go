@@ -0,0 +1,183 @@
+package pager
+
+import (
+	"fmt"
+
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// PageClass classifies the state of a page found by validation.
+type PageClass int
+
+// Page classes
+const (
+	PageValid              PageClass = iota // header and line pointers are consistent
+	PageNew                                 // page is all zero (allocated but never initialized)
+	PageHeaderCorrupt                       // pd_lower, pd_upper, pd_special or pd_flags are inconsistent
+	PageLinePointerCorrupt                  // a line pointer points outside the tuple area
+	PageTornWrite                           // header is valid but part of the tuple area was never written
+	PageWrongSize                           // pd_pagesize_version does not match the block size
+	PageWrongVersion                        // unsupported page layout version
+	PageTupleCorrupt                        // page is valid but a tuple on it is not
+	PageUnreadable                          // page could not be read
+)
+
+// String returns the name of the page class.
+func (c PageClass) String() string {
+	switch c {
+	case PageValid:
+		return "valid"
+	case PageNew:
+		return "new"
+	case PageHeaderCorrupt:
+		return "header-corrupt"
+	case PageLinePointerCorrupt:
+		return "line-pointer-corrupt"
+	case PageTornWrite:
+		return "torn-write"
+	case PageWrongSize:
+		return "wrong-page-size"
+	case PageWrongVersion:
+		return "wrong-page-version"
+	case PageTupleCorrupt:
+		return "tuple-corrupt"
+	case PageUnreadable:
+		return "unreadable"
+	default:
+		return fmt.Sprintf("page-class(%d)", int(c))
+	}
+}
+
+// PageError describes a page that failed validation.
+type PageError struct {
+	Relation string    // relation path, if known
+	Block    int64     // block number, or -1 if unknown
+	Class    PageClass // what is wrong with the page
+	Reason   string    // human-readable details
+}
+
+// Error implements the error interface.
+func (e *PageError) Error() string {
+	switch {
+	case e.Relation != "" && e.Block >= 0:
+		return fmt.Sprintf("%s block %d: %s: %s", e.Relation, e.Block, e.Class, e.Reason)
+	case e.Block >= 0:
+		return fmt.Sprintf("block %d: %s: %s", e.Block, e.Class, e.Reason)
+	default:
+		return fmt.Sprintf("%s: %s", e.Class, e.Reason)
+	}
+}
+
+// newPageError creates a PageError for a page whose location is not yet known.
+func newPageError(class PageClass, format string, args ...interface{}) *PageError {
+	return &PageError{
+		Block:  -1,
+		Class:  class,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// tornSectorSize is the unit in which storage can tear a page write.
+const tornSectorSize = 512
+
+// validateHeader checks the page header the way PostgreSQL's
+// PageIsVerified does, and returns PageNew for an all-zero page.
+func (p *PgPageParser) validateHeader(header pgtypes.HeapPageHeaderData, pageData []byte) *PageError {
+	// New pages are all zero
+	if header.PDUpper == 0 {
+		if isZero(pageData[:p.blockSize]) {
+			return newPageError(PageNew, "page is all zero")
+		}
+		return newPageError(PageHeaderCorrupt, "pd_upper is zero but page is not empty")
+	}
+
+	// Page size and layout version
+	if size := pgtypes.PageGetPageSize(header); size != p.blockSize {
+		return newPageError(PageWrongSize, "page size %d does not match block size %d", size, p.blockSize)
+	}
+	if version := pgtypes.PageGetPageLayoutVersion(header); version != pgtypes.PG_PAGE_LAYOUT_VERSION {
+		return newPageError(PageWrongVersion, "page layout version %d, expected %d", version, pgtypes.PG_PAGE_LAYOUT_VERSION)
+	}
+
+	// Flags and free space pointers
+	if header.PDFlags&^pgtypes.PD_VALID_FLAG_BITS != 0 {
+		return newPageError(PageHeaderCorrupt, "invalid pd_flags 0x%04X", header.PDFlags)
+	}
+	lower, upper, special := int(header.PDLower), int(header.PDUpper), int(header.PDSpecial)
+	if lower < pgtypes.SizeOfPageHeaderData || lower > upper || upper > special || special > p.blockSize {
+		return newPageError(PageHeaderCorrupt, "inconsistent pd_lower %d, pd_upper %d, pd_special %d", lower, upper, special)
+	}
+	if special%p.maxAlign != 0 {
+		return newPageError(PageHeaderCorrupt, "pd_special %d is not aligned to %d", special, p.maxAlign)
+	}
+	if (lower-pgtypes.SizeOfPageHeaderData)%pgtypes.SizeOfItemIdData != 0 {
+		return newPageError(PageHeaderCorrupt, "pd_lower %d does not end on a line pointer boundary", lower)
+	}
+
+	return nil
+}
+
+// validateItemIds checks that every line pointer stays within the tuple area
+// between pd_upper and pd_special, and looks for tuple storage that is
+// entirely zero, which is what a torn write leaves behind.
+func (p *PgPageParser) validateItemIds(header pgtypes.HeapPageHeaderData, itemIds []pgtypes.ItemIdData, pageData []byte) *PageError {
+	upper, special := int(header.PDUpper), int(header.PDSpecial)
+
+	for i, itemId := range itemIds {
+		offset := int(itemId.LpOff)
+		length := int(itemId.LpLen)
+
+		switch itemId.LpFlags {
+		case pgtypes.LP_REDIRECT:
+			// Redirects point to another line pointer of the same page
+			if length != 0 || offset < 1 || offset > len(itemIds) {
+				return newPageError(PageLinePointerCorrupt, "line pointer %d: invalid redirect to %d (length %d)", i+1, offset, length)
+			}
+
+		case pgtypes.LP_NORMAL, pgtypes.LP_DEAD:
+			// Dead items may have had their storage removed
+			if itemId.LpFlags == pgtypes.LP_DEAD && length == 0 {
+				continue
+			}
+			if offset < upper || offset+length > special || offset%p.maxAlign != 0 {
+				return newPageError(PageLinePointerCorrupt, "line pointer %d: offset %d length %d outside tuple area %d-%d",
+					i+1, offset, length, upper, special)
+			}
+			if length < pgtypes.SizeOfHeapTupleHeader {
+				return newPageError(PageLinePointerCorrupt, "line pointer %d: length %d shorter than a tuple header", i+1, length)
+			}
+			if sector := zeroSector(pageData, offset, length); sector >= 0 {
+				return newPageError(PageTornWrite, "line pointer %d: tuple at offset %d lies in all-zero sector at %d",
+					i+1, offset, sector)
+			}
+		}
+	}
+
+	return nil
+}
+
+// zeroSector returns the offset of the first 512-byte sector that is
+// entirely zero and covers the start of the tuple at offset, or -1 if the
+// tuple header has data. A written tuple header is never all zero, so an
+// all-zero sector under it means the write of that sector was lost.
+func zeroSector(pageData []byte, offset, length int) int {
+	// Check the tuple header bytes
+	end := offset + pgtypes.SizeOfHeapTupleHeader
+	if end > offset+length {
+		end = offset + length
+	}
+	if !isZero(pageData[offset:end]) {
+		return -1
+	}
+
+	// Confirm that the whole sector is zero rather than just the header
+	start := offset - offset%tornSectorSize
+	stop := start + tornSectorSize
+	if stop > len(pageData) {
+		stop = len(pageData)
+	}
+	if !isZero(pageData[start:stop]) {
+		return -1
+	}
+	return start
+}
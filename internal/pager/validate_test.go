@@ -0,0 +1,165 @@
+package pager
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// testParams returns the storage parameters of a cluster with 8kB blocks
+// written in order.
+func testParams(order binary.ByteOrder) pgtypes.StorageParams {
+	params := pgtypes.DefaultStorageParams()
+	params.ByteOrder = order
+	return params
+}
+
+// testTuple returns a tuple of one int4 column holding value, inserted by
+// transaction 1000, which committed.
+func testTuple(order binary.ByteOrder, value uint32) []byte {
+	tuple := make([]byte, 28)
+	order.PutUint32(tuple[0:], 1000)                                                   // t_xmin
+	order.PutUint16(tuple[18:], 1)                                                     // t_infomask2
+	order.PutUint16(tuple[20:], pgtypes.HEAP_XMIN_COMMITTED|pgtypes.HEAP_XMAX_INVALID) // t_infomask
+	tuple[22] = 24                                                                     // t_hoff
+	order.PutUint32(tuple[24:], value)
+	return tuple
+}
+
+// testItemId returns the line pointer word for a tuple at off of length n.
+func testItemId(order binary.ByteOrder, off, flags, n int) uint32 {
+	if order == binary.BigEndian {
+		return uint32(off)<<17 | uint32(flags)<<15 | uint32(n)
+	}
+	return uint32(n)<<17 | uint32(flags)<<15 | uint32(off)
+}
+
+// testPage returns an 8kB heap page holding the tuples, stored from the end
+// of the page like PostgreSQL does.
+func testPage(order binary.ByteOrder, tuples ...[]byte) []byte {
+	page := make([]byte, pgtypes.BLCKSZ)
+	upper := len(page)
+	for i, tuple := range tuples {
+		upper = (upper - len(tuple)) &^ 7
+		copy(page[upper:], tuple)
+		order.PutUint32(page[24+4*i:], testItemId(order, upper, pgtypes.LP_NORMAL, len(tuple)))
+	}
+	order.PutUint16(page[12:], uint16(24+4*len(tuples))) // pd_lower
+	order.PutUint16(page[14:], uint16(upper))            // pd_upper
+	order.PutUint16(page[16:], uint16(len(page)))        // pd_special
+	order.PutUint16(page[18:], uint16(len(page))|pgtypes.PG_PAGE_LAYOUT_VERSION)
+	return page
+}
+
+func TestParsePage(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		parser := NewPageParser(testParams(order))
+		page, err := parser.ParsePage(testPage(order, testTuple(order, 1), testTuple(order, 2)))
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		if page.Class != PageValid || page.ItemCount != 2 || page.ItemIds[1].LpOff != 8128 || page.ItemIds[1].LpLen != 28 {
+			t.Errorf("%s: class %s, %d items, %+v", order, page.Class, page.ItemCount, page.ItemIds)
+		}
+		tuples, err := parser.GetTuples(page)
+		if err != nil || len(tuples) != 2 || order.Uint32(tuples[1].Data) != 2 {
+			t.Errorf("%s: %d tuples (%v)", order, len(tuples), err)
+		}
+	}
+
+	// Pages that were allocated but never written are empty
+	page, err := NewPageParser(testParams(binary.LittleEndian)).ParsePage(make([]byte, pgtypes.BLCKSZ))
+	if err != nil || page.Class != PageNew || page.ItemCount != 0 {
+		t.Errorf("all-zero page: class %s, %d items (%v)", page.Class, page.ItemCount, err)
+	}
+}
+
+func TestParsePageClassification(t *testing.T) {
+	order := binary.LittleEndian
+	tests := []struct {
+		name   string
+		damage func(page []byte)
+		want   PageClass
+	}{
+		{"pd_upper zero", func(page []byte) { order.PutUint16(page[14:], 0) }, PageHeaderCorrupt},
+		{"page size", func(page []byte) { order.PutUint16(page[18:], 4096|4) }, PageWrongSize},
+		{"layout version", func(page []byte) { order.PutUint16(page[18:], 8192|3) }, PageWrongVersion},
+		{"pd_flags", func(page []byte) { order.PutUint16(page[10:], 0x0100) }, PageHeaderCorrupt},
+		{"pd_lower above pd_upper", func(page []byte) { order.PutUint16(page[12:], 8168) }, PageHeaderCorrupt},
+		{"pd_lower below header", func(page []byte) { order.PutUint16(page[12:], 20) }, PageHeaderCorrupt},
+		{"pd_lower within a line pointer", func(page []byte) { order.PutUint16(page[12:], 30) }, PageHeaderCorrupt},
+		{"pd_special beyond page", func(page []byte) { order.PutUint16(page[16:], 8200) }, PageHeaderCorrupt},
+		{"pd_special unaligned", func(page []byte) { order.PutUint16(page[16:], 8188) }, PageHeaderCorrupt},
+		{"tuple beyond pd_special", func(page []byte) {
+			order.PutUint32(page[24:], testItemId(order, 8176, pgtypes.LP_NORMAL, 28))
+		}, PageLinePointerCorrupt},
+		{"tuple below pd_upper", func(page []byte) {
+			order.PutUint32(page[24:], testItemId(order, 8000, pgtypes.LP_NORMAL, 28))
+		}, PageLinePointerCorrupt},
+		{"tuple unaligned", func(page []byte) {
+			order.PutUint32(page[24:], testItemId(order, 8162, pgtypes.LP_NORMAL, 28))
+		}, PageLinePointerCorrupt},
+		{"tuple shorter than its header", func(page []byte) {
+			order.PutUint32(page[24:], testItemId(order, 8160, pgtypes.LP_NORMAL, 12))
+		}, PageLinePointerCorrupt},
+		{"redirect beyond line pointers", func(page []byte) {
+			order.PutUint32(page[24:], testItemId(order, 5, pgtypes.LP_REDIRECT, 0))
+		}, PageLinePointerCorrupt},
+		{"torn write", func(page []byte) { copy(page[7680:], make([]byte, 512)) }, PageTornWrite},
+	}
+
+	parser := NewPageParser(testParams(order))
+	for _, test := range tests {
+		page := testPage(order, testTuple(order, 1), testTuple(order, 2))
+		test.damage(page)
+		parsed, err := parser.ParsePage(page)
+		var perr *PageError
+		if !errors.As(err, &perr) || perr.Class != test.want || parsed == nil || parsed.Class != test.want {
+			t.Errorf("%s: %v, want %s", test.name, err, test.want)
+		}
+	}
+
+	// Dead line pointers and valid redirects need no storage
+	page := testPage(order, testTuple(order, 1), testTuple(order, 2))
+	order.PutUint32(page[24:], testItemId(order, 0, pgtypes.LP_DEAD, 0))
+	order.PutUint32(page[28:], testItemId(order, 1, pgtypes.LP_REDIRECT, 0))
+	if parsed, err := parser.ParsePage(page); err != nil || parsed.Class != PageValid {
+		t.Errorf("dead item and redirect: %v", err)
+	}
+}
+
+func TestProcessPageError(t *testing.T) {
+	order := binary.LittleEndian
+	path := filepath.Join(t.TempDir(), "16384")
+	corrupt := testPage(order, testTuple(order, 3))
+	order.PutUint16(corrupt[12:], 8190)
+	data := append(testPage(order, testTuple(order, 1)), corrupt...)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	params := testParams(order)
+	processor := NewPageProcessor(fileio.NewPgFileReader(params), NewPageParser(params))
+	if err := processor.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer processor.Close()
+
+	if _, tuples, err := processor.ProcessPage(0); err != nil || len(tuples) != 1 {
+		t.Errorf("block 0: %d tuples (%v)", len(tuples), err)
+	}
+	page, _, err := processor.ProcessPage(1)
+	var perr *PageError
+	if !errors.As(err, &perr) || perr.Relation != path || perr.Block != 1 || page.BlockNumber != 1 {
+		t.Fatalf("block 1: %v", err)
+	}
+	want := path + " block 1: header-corrupt: inconsistent pd_lower 8190, pd_upper 8160, pd_special 8192"
+	if perr.Error() != want {
+		t.Errorf("block 1: %q, want %q", perr.Error(), want)
+	}
+}
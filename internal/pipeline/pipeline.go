@@ -0,0 +1,241 @@
+// Package pipeline reads a relation with several goroutines: a reader reads
+// pages, a pool of workers parses them and decodes their tuples, and a single
+// sink consumes the rows, optionally in block order.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/pager"
+)
+
+// Options configures a pipeline.
+type Options struct {
+	Workers int  // parse and decode workers; 0 means one per CPU
+	Ordered bool // deliver rows in block and line pointer order
+}
+
+// OptionsFromConfig reads the pipeline options from the PDU configuration.
+func OptionsFromConfig() Options {
+	return Options{
+		Workers: viper.GetInt("WORKERS"),
+		Ordered: viper.GetBool("ORDERED_OUTPUT"),
+	}
+}
+
+// workerCount returns the number of workers to start.
+func (o Options) workerCount() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// Row is a tuple together with its decoded columns. A row shares memory with
+// the buffer of its page, which is reused once the sink has returned for the
+// last row of the page: the tuple, its page and the data of its datums are
+// only valid until then, and a sink that keeps them must copy them.
+type Row struct {
+	*pager.ScannedTuple
+
+	// Decoded columns, or nil if the pipeline has no deformer
+	Datums []decoder.Datum
+}
+
+// Sink consumes rows. It is called from a single goroutine. Returning
+// pager.ErrStopIteration ends the pipeline without an error.
+type Sink func(row *Row) error
+
+// rawPage is a page as read by the reader.
+type rawPage struct {
+	block int64
+	data  []byte
+	err   error
+}
+
+// decodedPage is a page as produced by a worker.
+type decodedPage struct {
+	data   []byte // page buffer, released once the page is delivered
+	result *pager.BlockResult
+	rows   []*Row
+	err    error
+}
+
+// Run reads every page of the file open in processor and passes its rows to
+// sink. Each page is released to the reader's page pool after the sink has
+// consumed its rows, or when Run returns before that. Pages are parsed and,
+// if deformer is not nil, their tuples decoded by opts.Workers goroutines.
+// Damaged pages and tuples, including tuples that fail to decode, are handled
+// by the processor's error policy and recorded in its damage report. Run
+// stops when ctx is cancelled.
+func Run(ctx context.Context, processor *pager.PageProcessor, deformer *decoder.Deformer, opts Options, sink Sink) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	processor.ResetDamage()
+	workers := opts.workerCount()
+
+	// Limit the pages in flight, so that ordered output cannot pile up
+	// pages behind a slow one
+	window := make(chan struct{}, 4*workers)
+
+	// Reader
+	var wg sync.WaitGroup
+	pages := make(chan rawPage, workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pages)
+		pageCount := processor.PageCount()
+		for block := int64(0); block < pageCount; block++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			data, err := processor.ReadBlock(block)
+			select {
+			case pages <- rawPage{block: block, data: data, err: err}:
+			case <-ctx.Done():
+				processor.ReleaseBlock(data)
+				return
+			}
+		}
+	}()
+
+	// Workers
+	decoded := make(chan decodedPage, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Pages read after a stop are released undecoded, until the
+			// reader stops too
+			for page := range pages {
+				if ctx.Err() != nil {
+					processor.ReleaseBlock(page.data)
+					continue
+				}
+				result := decodePage(processor, deformer, page)
+				select {
+				case decoded <- result:
+				case <-ctx.Done():
+					processor.ReleaseBlock(result.data)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(decoded)
+	}()
+
+	// Sink
+	deliver := func(page decodedPage) error {
+		defer processor.ReleaseBlock(page.data)
+		<-window
+		if page.err != nil {
+			return page.err
+		}
+		if err := processor.RecordBlock(page.result); err != nil {
+			return err
+		}
+		for _, row := range page.rows {
+			if err := sink(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := consume(decoded, opts.Ordered, deliver, processor.ReleaseBlock)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	// Stop the reader and workers, release the pages they still hold, and
+	// wait for them to be done with the processor before returning
+	cancel()
+	for page := range decoded {
+		processor.ReleaseBlock(page.data)
+	}
+
+	if errors.Is(err, pager.ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+// decodePage parses a page and decodes its tuples.
+func decodePage(processor *pager.PageProcessor, deformer *decoder.Deformer, page rawPage) decodedPage {
+	result, err := processor.ProcessBlock(page.block, page.data, page.err)
+	if err != nil {
+		return decodedPage{data: page.data, result: result, err: err}
+	}
+
+	// Decode tuples; the slice is copied because rejected tuples are
+	// removed from result.Tuples
+	tuples := append([]*pager.ScannedTuple(nil), result.Tuples...)
+	rows := make([]*Row, 0, len(tuples))
+	for _, tuple := range tuples {
+		row := &Row{ScannedTuple: tuple}
+		if deformer != nil {
+			datums, err := deformer.Deform(tuple.Tuple)
+			if err != nil {
+				if err := processor.RejectTuple(result, tuple, err); err != nil {
+					return decodedPage{data: page.data, result: result, err: err}
+				}
+				if result.Damaged != nil && result.Damaged.Skipped {
+					return decodedPage{data: page.data, result: result}
+				}
+				continue
+			}
+			row.Datums = datums
+		}
+		rows = append(rows, row)
+	}
+
+	return decodedPage{data: page.data, result: result, rows: rows}
+}
+
+// consume passes decoded pages to deliver as they arrive or, if ordered, in
+// block order. Pages held back when delivery fails are passed to release.
+func consume(decoded <-chan decodedPage, ordered bool, deliver func(decodedPage) error, release func([]byte)) error {
+	pending := make(map[int64]decodedPage)
+	next := int64(0)
+	defer func() {
+		for _, page := range pending {
+			release(page.data)
+		}
+	}()
+
+	for page := range decoded {
+		if !ordered {
+			if err := deliver(page); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Hold pages back until all earlier pages are delivered
+		pending[page.result.Block] = page
+		for {
+			page, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := deliver(page); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
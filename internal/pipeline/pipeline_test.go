@@ -0,0 +1,311 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wublabdubdub/pdu/internal/decoder"
+	"github.com/wublabdubdub/pdu/internal/fileio"
+	"github.com/wublabdubdub/pdu/internal/pager"
+	"github.com/wublabdubdub/pdu/pkg/pgtypes"
+)
+
+// order is the byte order of the test relations.
+var order = binary.LittleEndian
+
+// testAttributes describe the single int4 column of the test relations.
+var testAttributes = []decoder.Attribute{
+	{Name: "a", AttNum: 1, AttLen: 4, AttAlign: decoder.TYPALIGN_INT, AttByVal: true},
+}
+
+// testTuple returns a tuple of one column holding data, inserted by
+// transaction 1000, which committed.
+func testTuple(data []byte) []byte {
+	tuple := make([]byte, 24, 24+len(data))
+	order.PutUint32(tuple[0:], 1000)
+	order.PutUint16(tuple[18:], 1)
+	order.PutUint16(tuple[20:], pgtypes.HEAP_XMIN_COMMITTED|pgtypes.HEAP_XMAX_INVALID)
+	tuple[22] = 24
+	return append(tuple, data...)
+}
+
+// testPage returns an 8kB heap page holding the tuples.
+func testPage(tuples ...[]byte) []byte {
+	page := make([]byte, pgtypes.BLCKSZ)
+	upper := len(page)
+	for i, tuple := range tuples {
+		upper = (upper - len(tuple)) &^ 7
+		copy(page[upper:], tuple)
+		order.PutUint32(page[24+4*i:], uint32(len(tuple))<<17|pgtypes.LP_NORMAL<<15|uint32(upper))
+	}
+	order.PutUint16(page[12:], uint16(24+4*len(tuples)))
+	order.PutUint16(page[14:], uint16(upper))
+	order.PutUint16(page[16:], uint16(len(page)))
+	order.PutUint16(page[18:], uint16(len(page))|pgtypes.PG_PAGE_LAYOUT_VERSION)
+	return page
+}
+
+// int4 returns the bytes of an int4 value.
+func int4(v int) []byte {
+	return order.AppendUint32(nil, uint32(v))
+}
+
+// writeRelation writes pages holding rows tuples each, numbered from 0, and
+// returns the path of the relation. Tuples for which damaged returns true
+// hold only two bytes, too few for their int4.
+func writeRelation(t *testing.T, pages, rows int, damaged func(v int) bool) string {
+	t.Helper()
+	var data []byte
+	for block := 0; block < pages; block++ {
+		var tuples [][]byte
+		for i := 0; i < rows; i++ {
+			v := block*rows + i
+			if damaged != nil && damaged(v) {
+				tuples = append(tuples, testTuple([]byte{0xEE, 0xEE}))
+			} else {
+				tuples = append(tuples, testTuple(int4(v)))
+			}
+		}
+		data = append(data, testPage(tuples...)...)
+	}
+	path := filepath.Join(t.TempDir(), "16384")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// openRelation writes a relation as writeRelation does and opens it.
+func openRelation(t *testing.T, pages, rows int, damaged func(v int) bool) *pager.PageProcessor {
+	t.Helper()
+	path := writeRelation(t, pages, rows, damaged)
+	params := pgtypes.DefaultStorageParams()
+	params.ByteOrder = order
+	processor := pager.NewPageProcessor(fileio.NewPgFileReader(params), pager.NewPageParser(params))
+	if err := processor.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { processor.Close() })
+	return processor
+}
+
+// collect runs the pipeline and returns the values of the rows in the order
+// the sink saw them.
+func collect(t *testing.T, processor *pager.PageProcessor, opts Options) ([]int, error) {
+	t.Helper()
+	var values []int
+	deformer := decoder.NewDeformer(testAttributes, order)
+	err := Run(context.Background(), processor, deformer, opts, func(row *Row) error {
+		if len(row.Datums) != 1 {
+			t.Fatalf("block %d item %d: %d datums", row.Block, row.Item, len(row.Datums))
+		}
+		values = append(values, int(order.Uint32(row.Datums[0].Data)))
+		return nil
+	})
+	return values, err
+}
+
+func TestRunOrdered(t *testing.T) {
+	processor := openRelation(t, 50, 20, nil)
+	for _, workers := range []int{1, 4, 16} {
+		values, err := collect(t, processor, Options{Workers: workers, Ordered: true})
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		if len(values) != 1000 {
+			t.Fatalf("%d workers: %d rows", workers, len(values))
+		}
+		for i, v := range values {
+			if v != i {
+				t.Fatalf("%d workers: row %d is %d", workers, i, v)
+			}
+		}
+		if damage := processor.Damage(); damage.Tuples != 1000 || damage.Damaged() {
+			t.Errorf("%d workers: %d tuples, damaged %v", workers, damage.Tuples, damage.Damaged())
+		}
+	}
+}
+
+func TestRunUnordered(t *testing.T) {
+	processor := openRelation(t, 50, 20, nil)
+	values, err := collect(t, processor, Options{Workers: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(values)
+	if len(values) != 1000 || values[0] != 0 || values[999] != 999 {
+		t.Fatalf("%d rows from %d to %d", len(values), values[0], values[len(values)-1])
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i] == values[i-1] {
+			t.Fatalf("row %d delivered twice", values[i])
+		}
+	}
+}
+
+func TestRunDecodeErrors(t *testing.T) {
+	damaged := func(v int) bool { return v == 7 || v == 33 }
+
+	// Tuples that fail to decode are skipped
+	processor := openRelation(t, 5, 10, damaged)
+	values, err := collect(t, processor, Options{Workers: 3, Ordered: true})
+	if err != nil || len(values) != 48 || values[7] != 8 {
+		t.Errorf("skip-tuple: %d rows (%v)", len(values), err)
+	}
+	damage := processor.Damage()
+	if len(damage.SkippedTuples) != 2 || damage.SkippedTuples[0].Block != 0 || damage.SkippedTuples[0].Item != 8 ||
+		damage.SkippedTuples[1].Block != 3 || damage.SkippedTuples[1].Item != 4 {
+		t.Errorf("skip-tuple: skipped %+v", damage.SkippedTuples)
+	}
+
+	// or cost their pages
+	processor.SetErrorPolicy(pager.PolicySkipPage, 0)
+	values, err = collect(t, processor, Options{Workers: 3, Ordered: true})
+	if err != nil || len(values) != 30 || values[0] != 10 || values[10] != 20 || values[20] != 40 {
+		t.Errorf("skip-page: rows %v (%v)", values, err)
+	}
+	if damage := processor.Damage(); len(damage.DamagedBlocks) != 2 || damage.Errors() != 2 {
+		t.Errorf("skip-page: damaged %+v", damage.DamagedBlocks)
+	}
+
+	// or stop the pipeline
+	processor.SetErrorPolicy(pager.PolicyFailFast, 0)
+	_, err = collect(t, processor, Options{Workers: 3, Ordered: true})
+	var perr *pager.PageError
+	if !errors.As(err, &perr) || perr.Block != 0 || perr.Class != pager.PageTupleCorrupt {
+		t.Errorf("fail-fast: %v", err)
+	}
+
+	// and count against the error budget
+	processor.SetErrorPolicy(pager.PolicySkipTuple, 1)
+	if _, err := collect(t, processor, Options{Workers: 3}); !errors.Is(err, pager.ErrErrorBudgetExceeded) {
+		t.Errorf("budget of 1: %v", err)
+	}
+}
+
+func TestRunStop(t *testing.T) {
+	processor := openRelation(t, 20, 10, nil)
+	deformer := decoder.NewDeformer(testAttributes, order)
+
+	// The sink can end the pipeline early
+	rows := 0
+	err := Run(context.Background(), processor, deformer, Options{Workers: 4, Ordered: true}, func(row *Row) error {
+		rows++
+		if rows == 15 {
+			return pager.ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil || rows != 15 {
+		t.Errorf("stopped: %d rows (%v)", rows, err)
+	}
+
+	// Sink errors are returned
+	errTest := errors.New("test")
+	err = Run(context.Background(), processor, deformer, Options{Workers: 4}, func(row *Row) error { return errTest })
+	if err != errTest {
+		t.Errorf("failed: %v", err)
+	}
+
+	// So is cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	err = Run(ctx, processor, nil, Options{Workers: 4}, func(row *Row) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: %v", err)
+	}
+}
+
+// countingReader counts the pages read and released through a reader.
+type countingReader struct {
+	*fileio.PgFileReader
+	read, released int64
+}
+
+func (r *countingReader) ReadPage(pageNumber int64) ([]byte, error) {
+	page, err := r.PgFileReader.ReadPage(pageNumber)
+	if page != nil {
+		atomic.AddInt64(&r.read, 1)
+	}
+	return page, err
+}
+
+func (r *countingReader) ReleasePage(page []byte) {
+	atomic.AddInt64(&r.released, 1)
+	r.PgFileReader.ReleasePage(page)
+}
+
+func TestRunReleasesPages(t *testing.T) {
+	path := writeRelation(t, 40, 10, nil)
+	params := pgtypes.DefaultStorageParams()
+	params.ByteOrder = order
+	deformer := decoder.NewDeformer(testAttributes, order)
+	errTest := errors.New("test")
+
+	tests := []struct {
+		name  string
+		opts  Options
+		after int // rows the sink takes before it fails
+	}{
+		{"complete", Options{Workers: 4}, -1},
+		{"failed at once", Options{Workers: 4}, 0},
+		{"failed", Options{Workers: 4}, 25},
+		{"failed ordered", Options{Workers: 4, Ordered: true}, 25},
+		{"failed ordered, one worker", Options{Workers: 1, Ordered: true}, 25},
+	}
+	for _, test := range tests {
+		reader := &countingReader{PgFileReader: fileio.NewPgFileReader(params)}
+		processor := pager.NewPageProcessor(reader, pager.NewPageParser(params))
+		if err := processor.Open(path); err != nil {
+			t.Fatal(err)
+		}
+
+		rows := 0
+		err := Run(context.Background(), processor, deformer, test.opts, func(row *Row) error {
+			if rows == test.after {
+				return errTest
+			}
+			rows++
+			return nil
+		})
+		processor.Close()
+		if (err != nil) != (test.after >= 0) {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if reader.read == 0 || reader.read != reader.released {
+			t.Errorf("%s: %d pages read, %d released", test.name, reader.read, reader.released)
+		}
+	}
+}
+
+func TestConsumeOrdered(t *testing.T) {
+	// Pages arrive out of order
+	decoded := make(chan decodedPage, 5)
+	for _, block := range []int64{2, 0, 4, 1, 3} {
+		decoded <- decodedPage{result: &pager.BlockResult{Block: block}}
+	}
+	close(decoded)
+
+	var blocks []int64
+	err := consume(decoded, true, func(page decodedPage) error {
+		blocks = append(blocks, page.result.Block)
+		return nil
+	}, func([]byte) { t.Error("page released undelivered") })
+	if err != nil || len(blocks) != 5 {
+		t.Fatalf("blocks %v (%v)", blocks, err)
+	}
+	for i, block := range blocks {
+		if block != int64(i) {
+			t.Errorf("blocks delivered in order %v", blocks)
+			break
+		}
+	}
+}
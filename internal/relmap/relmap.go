@@ -0,0 +1,122 @@
+// Package relmap reads the relation map files (pg_filenode.map) that record
+// the relfilenodes of mapped catalogs, whose pg_class entries have relfilenode 0.
+package relmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+
+	"github.com/wublabdubdub/pdu/internal/fileio"
+)
+
+// RELMAPPER_FILEMAGIC identifies a relation map file.
+const RELMAPPER_FILEMAGIC = 0x592717
+
+// FileName is the name of the relation map file in global and in each database directory.
+const FileName = "pg_filenode.map"
+
+// Layouts of RelMapFile: magic, num_mappings, mappings[MAX_MAPPINGS] of
+// (mapoid, mapfilenumber), crc, and before 16 a pad word
+const (
+	fileSize         = 512 // RELMAPPER_FILESIZE before 16, MAX_MAPPINGS 62
+	fileSizeV16      = 524 // sizeof(RelMapFile) since 16, MAX_MAPPINGS 64
+	maxMappings      = 62
+	maxMappingsV16   = 64
+	sizeOfRelMapping = 8
+)
+
+// Mapping maps a catalog OID to its relfilenode.
+type Mapping struct {
+	Oid      uint32 `json:"oid"`      // mapoid
+	FileNode uint32 `json:"filenode"` // mapfilenumber
+}
+
+// RelationMap is the decoded contents of a pg_filenode.map file.
+type RelationMap struct {
+	Magic    uint32    `json:"magic"`
+	Mappings []Mapping `json:"mappings"`
+	CRC      uint32    `json:"crc"`       // CRC-32C stored in the file
+	CRCValid bool      `json:"crc_valid"` // stored CRC matches the contents
+}
+
+// FileNode returns the relfilenode of a mapped catalog, and false if the map
+// has no entry for it.
+func (m *RelationMap) FileNode(oid uint32) (uint32, bool) {
+	for _, mapping := range m.Mappings {
+		if mapping.Oid == oid {
+			return mapping.FileNode, true
+		}
+	}
+	return 0, false
+}
+
+// Path returns the location of the relation map in a directory, either
+// global or a database directory such as base/<dboid>.
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// ReadRelationMap reads and decodes the relation map in a directory.
+func ReadRelationMap(dir string) (*RelationMap, error) {
+	data, err := fileio.ReadFile(Path(dir))
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseRelationMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", Path(dir), err)
+	}
+	return m, nil
+}
+
+// ParseRelationMap decodes the contents of a pg_filenode.map file. The byte
+// order is detected from the magic number and the layout from the file size.
+// A CRC mismatch is reported in CRCValid, leaving it to the caller whether
+// to trust the mappings.
+func ParseRelationMap(data []byte) (*RelationMap, error) {
+	var max int
+	switch len(data) {
+	case fileSize:
+		max = maxMappings
+	case fileSizeV16:
+		max = maxMappingsV16
+	default:
+		return nil, fmt.Errorf("unexpected size %d bytes, expected %d or %d", len(data), fileSize, fileSizeV16)
+	}
+
+	// Detect byte order
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(data) == RELMAPPER_FILEMAGIC:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(data) == RELMAPPER_FILEMAGIC:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("bad magic number %#x", binary.LittleEndian.Uint32(data))
+	}
+
+	m := &RelationMap{Magic: RELMAPPER_FILEMAGIC}
+	count := int32(order.Uint32(data[4:]))
+	if count < 0 || int(count) > max {
+		return nil, fmt.Errorf("invalid number of mappings %d", count)
+	}
+	for i := 0; i < int(count); i++ {
+		entry := data[8+i*sizeOfRelMapping:]
+		m.Mappings = append(m.Mappings, Mapping{
+			Oid:      order.Uint32(entry),
+			FileNode: order.Uint32(entry[4:]),
+		})
+	}
+
+	// The CRC covers everything before it
+	crcOffset := 8 + max*sizeOfRelMapping
+	m.CRC = order.Uint32(data[crcOffset:])
+	m.CRCValid = crc32.Checksum(data[:crcOffset], crc32cTable) == m.CRC
+
+	return m, nil
+}
+
+// crc32cTable is the CRC-32C (Castagnoli) table PostgreSQL's pg_crc32c uses.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
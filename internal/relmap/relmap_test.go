@@ -0,0 +1,102 @@
+package relmap
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+// testRelationMap returns a pg_filenode.map of size bytes, 512 before
+// PostgreSQL 16 and 524 since, holding the mappings and a valid CRC.
+func testRelationMap(order binary.ByteOrder, size int, mappings ...Mapping) []byte {
+	data := make([]byte, size)
+	order.PutUint32(data[0:], RELMAPPER_FILEMAGIC)
+	order.PutUint32(data[4:], uint32(len(mappings)))
+	for i, m := range mappings {
+		order.PutUint32(data[8+8*i:], m.Oid)
+		order.PutUint32(data[12+8*i:], m.FileNode)
+	}
+
+	// The CRC follows the last possible mapping
+	crcOffset := 504
+	if size == 524 {
+		crcOffset = 520
+	}
+	order.PutUint32(data[crcOffset:], crc32.Checksum(data[:crcOffset], crc32.MakeTable(crc32.Castagnoli)))
+	return data
+}
+
+func TestParseRelationMap(t *testing.T) {
+	mappings := []Mapping{{1259, 1259}, {1249, 16401}, {2662, 2662}}
+	tests := []struct {
+		order binary.ByteOrder
+		size  int
+	}{
+		{binary.LittleEndian, 512},
+		{binary.BigEndian, 512},
+		{binary.LittleEndian, 524},
+		{binary.BigEndian, 524},
+	}
+
+	for _, test := range tests {
+		m, err := ParseRelationMap(testRelationMap(test.order, test.size, mappings...))
+		if err != nil {
+			t.Errorf("%s, %d bytes: %v", test.order, test.size, err)
+			continue
+		}
+		if !m.CRCValid || m.Magic != RELMAPPER_FILEMAGIC || !reflect.DeepEqual(m.Mappings, mappings) {
+			t.Errorf("%s, %d bytes: CRC valid %v, magic %#x, mappings %v", test.order, test.size, m.CRCValid, m.Magic, m.Mappings)
+		}
+		if node, ok := m.FileNode(1249); !ok || node != 16401 {
+			t.Errorf("%s, %d bytes: pg_attribute maps to %d (%v)", test.order, test.size, node, ok)
+		}
+		if node, ok := m.FileNode(1255); ok {
+			t.Errorf("%s, %d bytes: unmapped pg_proc maps to %d", test.order, test.size, node)
+		}
+	}
+}
+
+func TestParseRelationMapCRC(t *testing.T) {
+	data := testRelationMap(binary.LittleEndian, 512, Mapping{1259, 1259})
+	data[12]++
+	m, err := ParseRelationMap(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CRCValid || m.Mappings[0].FileNode != 1260 {
+		t.Errorf("CRC valid %v, mappings %v", m.CRCValid, m.Mappings)
+	}
+
+	// The pad word after the CRC is not covered
+	data = testRelationMap(binary.LittleEndian, 512, Mapping{1259, 1259})
+	data[510] = 0xFF
+	if m, err := ParseRelationMap(data); err != nil || !m.CRCValid {
+		t.Errorf("damaged pad word: %v", err)
+	}
+}
+
+func TestParseRelationMapErrors(t *testing.T) {
+	badMagic := testRelationMap(binary.LittleEndian, 512)
+	badMagic[0]++
+	tooMany := testRelationMap(binary.LittleEndian, 512)
+	binary.LittleEndian.PutUint32(tooMany[4:], 63)
+	negative := testRelationMap(binary.LittleEndian, 524)
+	binary.LittleEndian.PutUint32(negative[4:], 0xFFFFFFFF)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"wrong size", make([]byte, 516)},
+		{"bad magic", badMagic},
+		{"too many mappings", tooMany},
+		{"negative number of mappings", negative},
+	}
+	for _, test := range tests {
+		if m, err := ParseRelationMap(test.data); err == nil {
+			t.Errorf("%s: %+v, want an error", test.name, m)
+		}
+	}
+}
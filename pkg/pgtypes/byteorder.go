@@ -0,0 +1,29 @@
+package pgtypes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// DefaultByteOrder is the byte order assumed for data files when none is
+// configured. PostgreSQL writes pages in the native order of the host that
+// ran the cluster, and almost all of them are little-endian today.
+var DefaultByteOrder binary.ByteOrder = binary.LittleEndian
+
+// ParseByteOrder converts a configuration value into a binary.ByteOrder.
+// It accepts "little" (or "le") and "big" (or "be"); an empty string selects
+// DefaultByteOrder. Big-endian is needed for clusters copied off AIX, SPARC
+// or other big-endian hosts.
+func ParseByteOrder(name string) (binary.ByteOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return DefaultByteOrder, nil
+	case "little", "le", "little-endian":
+		return binary.LittleEndian, nil
+	case "big", "be", "big-endian":
+		return binary.BigEndian, nil
+	default:
+		return nil, fmt.Errorf("unknown byte order %q: expected \"little\" or \"big\"", name)
+	}
+}
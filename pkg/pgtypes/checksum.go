@@ -0,0 +1,73 @@
+package pgtypes
+
+import "encoding/binary"
+
+// Checksum algorithm parameters (see PostgreSQL's checksum_impl.h)
+const (
+	checksumNSums    = 32       // number of parallel partial checksums
+	checksumFNVPrime = 16777619 // FNV-1a prime
+)
+
+// checksumBaseOffsets are the random initial values of the partial checksums.
+var checksumBaseOffsets = [checksumNSums]uint32{
+	0x5B1F36E9, 0xB8525960, 0x02AB50AA, 0x1DE66D2A,
+	0x79FF467A, 0x9BB9F8A3, 0x217E7CD2, 0x83E13D2C,
+	0xF8D4474F, 0xE39EB970, 0x42C6AE16, 0x993216FA,
+	0x7B093B5D, 0x98DAFF3C, 0xF718902A, 0x0B1C9CDB,
+	0xE58F764B, 0x187636BC, 0x5D7B3BB1, 0xE73DE7DE,
+	0x92BEC979, 0xCCA6C0B2, 0x304A0979, 0x85AA43D4,
+	0x783125BB, 0x6CA8EAA2, 0xE407EAC6, 0x4B5CFC3E,
+	0x9FBF8C76, 0x15CA20BE, 0xF2CA9FFF, 0x3E9E4E2E,
+}
+
+// checksumComp mixes one word into a partial checksum.
+func checksumComp(checksum, value uint32) uint32 {
+	tmp := checksum ^ value
+	return tmp*checksumFNVPrime ^ (tmp >> 17)
+}
+
+// checksumBlock computes the unsalted checksum of a block, reading it as
+// 32-bit words in the given byte order and treating pd_checksum as zero.
+func checksumBlock(page []byte, order binary.ByteOrder) uint32 {
+	sums := checksumBaseOffsets
+	words := len(page) / 4
+
+	// The word holding pd_checksum and pd_flags, with pd_checksum zeroed
+	checksumWord := order.Uint32([]byte{0, 0, page[10], page[11]})
+
+	// Main loop: the page is processed as rows of N_SUMS words
+	for i := 0; i+checksumNSums <= words; i += checksumNSums {
+		for j := 0; j < checksumNSums; j++ {
+			value := order.Uint32(page[(i+j)*4:])
+			if i+j == 2 {
+				value = checksumWord
+			}
+			sums[j] = checksumComp(sums[j], value)
+		}
+	}
+
+	// Two rounds of zeroes for additional mixing
+	for i := 0; i < 2; i++ {
+		for j := 0; j < checksumNSums; j++ {
+			sums[j] = checksumComp(sums[j], 0)
+		}
+	}
+
+	// Fold the partial checksums together
+	var result uint32
+	for _, sum := range sums {
+		result ^= sum
+	}
+	return result
+}
+
+// PageChecksum computes the data checksum of a page as pg_checksum_page does:
+// the block is hashed with pd_checksum taken as zero, salted with the block number
+// (counted from the start of the relation fork, across segments) and reduced
+// to a non-zero 16-bit value.
+func PageChecksum(page []byte, blockNumber uint32, order binary.ByteOrder) uint16 {
+	checksum := checksumBlock(page, order)
+	checksum ^= blockNumber
+
+	return uint16(checksum%65535 + 1)
+}
@@ -0,0 +1,62 @@
+package pgtypes
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// checksumTestPage returns a page of size bytes filled with a fixed pattern,
+// with a stale pd_checksum that the checksum must ignore.
+func checksumTestPage(size int) []byte {
+	page := make([]byte, size)
+	for i := range page {
+		page[i] = byte(i % 251)
+	}
+	page[8], page[9] = 0xAB, 0xCD
+	return page
+}
+
+func TestPageChecksum(t *testing.T) {
+	// Expected values computed with pg_checksum_page from checksum_impl.h,
+	// reading the words in each byte order
+	tests := []struct {
+		size  int
+		block uint32
+		order binary.ByteOrder
+		want  uint16
+	}{
+		{8192, 0, binary.LittleEndian, 48023},
+		{8192, 1, binary.LittleEndian, 48022},
+		{8192, 123456, binary.LittleEndian, 56662},
+		{8192, 0, binary.BigEndian, 43564},
+		{8192, 1, binary.BigEndian, 43565},
+		{8192, 123456, binary.BigEndian, 52204},
+		{4096, 0, binary.LittleEndian, 36778},
+		{4096, 123456, binary.LittleEndian, 29161},
+		{4096, 0, binary.BigEndian, 46753},
+		{4096, 123456, binary.BigEndian, 55391},
+	}
+
+	for _, test := range tests {
+		page := checksumTestPage(test.size)
+		if got := PageChecksum(page, test.block, test.order); got != test.want {
+			t.Errorf("%d-byte %s page, block %d: checksum %d, want %d", test.size, test.order, test.block, got, test.want)
+		}
+		if page[8] != 0xAB || page[9] != 0xCD {
+			t.Errorf("%d-byte %s page, block %d: pd_checksum was modified", test.size, test.order, test.block)
+		}
+	}
+}
+
+func TestPageChecksumIgnoresStoredChecksum(t *testing.T) {
+	page := checksumTestPage(8192)
+	want := PageChecksum(page, 7, binary.LittleEndian)
+	binary.LittleEndian.PutUint16(page[8:], want)
+	if got := PageChecksum(page, 7, binary.LittleEndian); got != want {
+		t.Errorf("checksum %d after storing it, want %d", got, want)
+	}
+	page[100]++
+	if got := PageChecksum(page, 7, binary.LittleEndian); got == want {
+		t.Errorf("checksum %d unchanged after modifying the page", got)
+	}
+}
@@ -0,0 +1,86 @@
+package pgtypes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Encoding is a character set encoding, as a pg_enc number
+// (pg_database.encoding).
+type Encoding int32
+
+// Server encodings (pg_enc). The numbers are stored in pg_database and have
+// not changed since PostgreSQL 8.3.
+const (
+	PG_SQL_ASCII     Encoding = iota // bytes as the client sent them, no conversion
+	PG_EUC_JP                        // EUC for Japanese
+	PG_EUC_CN                        // EUC for Chinese
+	PG_EUC_KR                        // EUC for Korean
+	PG_EUC_TW                        // EUC for Taiwan
+	PG_EUC_JIS_2004                  // EUC-JIS-2004
+	PG_UTF8                          // Unicode UTF8
+	PG_MULE_INTERNAL                 // Mule internal code
+	PG_LATIN1                        // ISO-8859-1 Latin 1
+	PG_LATIN2                        // ISO-8859-2 Latin 2
+	PG_LATIN3                        // ISO-8859-3 Latin 3
+	PG_LATIN4                        // ISO-8859-4 Latin 4
+	PG_LATIN5                        // ISO-8859-9 Latin 5
+	PG_LATIN6                        // ISO-8859-10 Latin 6
+	PG_LATIN7                        // ISO-8859-13 Latin 7
+	PG_LATIN8                        // ISO-8859-14 Latin 8
+	PG_LATIN9                        // ISO-8859-15 Latin 9
+	PG_LATIN10                       // ISO-8859-16 Latin 10
+	PG_WIN1256                       // windows-1256
+	PG_WIN1258                       // windows-1258
+	PG_WIN866                        // CP866
+	PG_WIN874                        // windows-874
+	PG_KOI8R                         // KOI8-R
+	PG_WIN1251                       // windows-1251
+	PG_WIN1252                       // windows-1252
+	PG_ISO_8859_5                    // ISO-8859-5
+	PG_ISO_8859_6                    // ISO-8859-6
+	PG_ISO_8859_7                    // ISO-8859-7
+	PG_ISO_8859_8                    // ISO-8859-8
+	PG_WIN1250                       // windows-1250
+	PG_WIN1253                       // windows-1253
+	PG_WIN1254                       // windows-1254
+	PG_WIN1255                       // windows-1255
+	PG_WIN1257                       // windows-1257
+	PG_KOI8U                         // KOI8-U
+)
+
+// encodingNames are the names of the server encodings, as pg_encoding_to_char
+// returns them.
+var encodingNames = []string{
+	"SQL_ASCII", "EUC_JP", "EUC_CN", "EUC_KR", "EUC_TW", "EUC_JIS_2004", "UTF8", "MULE_INTERNAL",
+	"LATIN1", "LATIN2", "LATIN3", "LATIN4", "LATIN5", "LATIN6", "LATIN7", "LATIN8", "LATIN9", "LATIN10",
+	"WIN1256", "WIN1258", "WIN866", "WIN874", "KOI8R", "WIN1251", "WIN1252",
+	"ISO_8859_5", "ISO_8859_6", "ISO_8859_7", "ISO_8859_8",
+	"WIN1250", "WIN1253", "WIN1254", "WIN1255", "WIN1257", "KOI8U",
+}
+
+// String returns the name of the encoding, e.g. "UTF8".
+func (e Encoding) String() string {
+	if e >= 0 && int(e) < len(encodingNames) {
+		return encodingNames[e]
+	}
+	return fmt.Sprintf("encoding %d", int32(e))
+}
+
+// ParseEncoding parses the name of a server encoding. Case, dashes and
+// underscores are ignored, so "utf-8" and "Latin1" are accepted.
+func ParseEncoding(name string) (Encoding, error) {
+	clean := func(s string) string {
+		return strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(s))
+	}
+	want := clean(name)
+	if want == "UTF8" || want == "UNICODE" {
+		return PG_UTF8, nil
+	}
+	for i, n := range encodingNames {
+		if clean(n) == want {
+			return Encoding(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown server encoding %q", name)
+}
@@ -1,15 +1,97 @@
 // Package pgtypes defines PostgreSQL data types and structures.
 package pgtypes
 
-import "encoding/binary"
-
-// PG_VERSION_NUM defines the PostgreSQL version number.
-const PG_VERSION_NUM = 15
+import (
+	"encoding/binary"
+	"fmt"
+)
 
-// Block size constants
+// Default block size constants. The values of the cluster being read are in StorageParams.
 const (
 	BLCKSZ      = 8192
 	NAMEDATALEN = 64
+	RELSEG_SIZE = 131072 // blocks per segment file (1GB with 8kB blocks)
+
+	MAXIMUM_ALIGNOF = 8 // MAXALIGN on 64-bit platforms
+
+	XLOG_BLCKSZ           = 8192             // WAL page size
+	DEFAULT_XLOG_SEG_SIZE = 16 * 1024 * 1024 // WAL segment size
+)
+
+// StorageParams holds the build-time parameters of the cluster that wrote the
+// data files. They are read from pg_control at runtime; the constants above
+// are only the PostgreSQL defaults.
+type StorageParams struct {
+	ByteOrder     binary.ByteOrder // byte order of the host that wrote the files
+	BlockSize     int              // BLCKSZ: size of a data page in bytes
+	RelSegSize    int              // RELSEG_SIZE: blocks per relation segment file
+	XLogBlockSize int              // XLOG_BLCKSZ: size of a WAL page in bytes
+	WalSegSize    int              // wal_segment_size: size of a WAL segment file in bytes
+	Profile       *Profile         // layout profile of the PostgreSQL major version
+	DataChecksums bool             // pages carry data checksums (data_checksum_version != 0)
+	MaxAlign      int              // MAXALIGN: alignment of tuples and special space
+}
+
+// DefaultStorageParams returns the parameters of a default PostgreSQL build.
+func DefaultStorageParams() StorageParams {
+	return StorageParams{
+		ByteOrder:     DefaultByteOrder,
+		BlockSize:     BLCKSZ,
+		RelSegSize:    RELSEG_SIZE,
+		XLogBlockSize: XLOG_BLCKSZ,
+		WalSegSize:    DEFAULT_XLOG_SEG_SIZE,
+		Profile:       DefaultProfile(),
+		MaxAlign:      MAXIMUM_ALIGNOF,
+	}
+}
+
+// Validate checks that the parameters are values PostgreSQL can be built with.
+func (p StorageParams) Validate() error {
+	if p.ByteOrder == nil {
+		return fmt.Errorf("byte order not set")
+	}
+	if p.Profile == nil {
+		return fmt.Errorf("PostgreSQL version not set")
+	}
+	if !isPowerOfTwo(p.BlockSize) || p.BlockSize < 1024 || p.BlockSize > 32768 {
+		return fmt.Errorf("invalid block size %d: must be a power of 2 between 1024 and 32768", p.BlockSize)
+	}
+	if p.RelSegSize <= 0 {
+		return fmt.Errorf("invalid segment size %d blocks", p.RelSegSize)
+	}
+	if p.MaxAlign != 4 && p.MaxAlign != 8 {
+		return fmt.Errorf("invalid maximum alignment %d: must be 4 or 8", p.MaxAlign)
+	}
+	if !isPowerOfTwo(p.XLogBlockSize) || p.XLogBlockSize < 1024 || p.XLogBlockSize > 65536 {
+		return fmt.Errorf("invalid WAL block size %d: must be a power of 2 between 1024 and 65536", p.XLogBlockSize)
+	}
+	if !isPowerOfTwo(p.WalSegSize) || p.WalSegSize < 1024*1024 || p.WalSegSize > 1024*1024*1024 {
+		return fmt.Errorf("invalid WAL segment size %d: must be a power of 2 between 1MB and 1GB", p.WalSegSize)
+	}
+	return nil
+}
+
+// String returns a one-line summary of the parameters.
+func (p StorageParams) String() string {
+	return fmt.Sprintf("%s, byte order %s, block size %d, segment size %d blocks, WAL block size %d, WAL segment size %d",
+		p.Profile, p.ByteOrder, p.BlockSize, p.RelSegSize, p.XLogBlockSize, p.WalSegSize)
+}
+
+// SegmentBytes returns the size of a full relation segment file in bytes.
+func (p StorageParams) SegmentBytes() int64 {
+	return int64(p.RelSegSize) * int64(p.BlockSize)
+}
+
+// isPowerOfTwo checks if n is a positive power of two.
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// On-disk structure sizes
+const (
+	SizeOfPageHeaderData  = 24 // size of a page header in bytes
+	SizeOfItemIdData      = 4  // size of a line pointer in bytes
+	SizeOfHeapTupleHeader = 23 // size of a heap tuple header in bytes, excluding t_bits
 )
 
 // ItemId flags
@@ -25,23 +107,74 @@ const (
 	PD_HAS_FREE_LINES = 0x0001 // are there any unused line pointers?
 	PD_PAGE_FULL      = 0x0002 // not enough free space for new tuple?
 	PD_ALL_VISIBLE    = 0x0004 // all tuples on page are visible to everyone
+
+	PD_VALID_FLAG_BITS = 0x0007 // OR of all valid pd_flags bits
+)
+
+// PG_PAGE_LAYOUT_VERSION is the page layout version written since PostgreSQL 8.3.
+const PG_PAGE_LAYOUT_VERSION = 4
+
+// Tuple header info mask bits (t_infomask)
+const (
+	HEAP_HASNULL          = 0x0001 // has null attribute(s)
+	HEAP_HASVARWIDTH      = 0x0002 // has variable-width attribute(s)
+	HEAP_HASEXTERNAL      = 0x0004 // has external stored attribute(s)
+	HEAP_HASOID           = 0x0008 // has object id (before PostgreSQL 12)
+	HEAP_XMAX_KEYSHR_LOCK = 0x0010 // xmax is a key-shared locker
+	HEAP_COMBOCID         = 0x0020 // t_cid is a combo CID
+	HEAP_XMAX_EXCL_LOCK   = 0x0040 // xmax is exclusive locker
+	HEAP_XMAX_LOCK_ONLY   = 0x0080 // xmax, if valid, is only a locker
+	HEAP_XMIN_COMMITTED   = 0x0100 // t_xmin committed
+	HEAP_XMIN_INVALID     = 0x0200 // t_xmin invalid/aborted
+	HEAP_XMIN_FROZEN      = 0x0300 // both bits set: t_xmin frozen
+	HEAP_XMAX_COMMITTED   = 0x0400 // t_xmax committed
+	HEAP_XMAX_INVALID     = 0x0800 // t_xmax invalid/aborted
+	HEAP_XMAX_IS_MULTI    = 0x1000 // t_xmax is a MultiXactId
+	HEAP_UPDATED          = 0x2000 // this is UPDATEd version of row
+	HEAP_MOVED_OFF        = 0x4000 // moved to another place by pre-9.0 VACUUM FULL
+	HEAP_MOVED_IN         = 0x8000 // moved from another place by pre-9.0 VACUUM FULL
+	HEAP_MOVED            = HEAP_MOVED_OFF | HEAP_MOVED_IN
+	HEAP_XMAX_SHR_LOCK    = HEAP_XMAX_EXCL_LOCK | HEAP_XMAX_KEYSHR_LOCK
+	HEAP_LOCK_MASK        = HEAP_XMAX_SHR_LOCK | HEAP_XMAX_EXCL_LOCK | HEAP_XMAX_KEYSHR_LOCK
 )
 
-// Tuple header info mask bits
+// Tuple header info mask bits (t_infomask2)
 const (
-	HEAP_HASNULL           = 0x0001 // has null attribute(s)
-	HEAP_HASOID            = 0x0008 // has object id
-	HEAP_XMAX_IS_MULTI     = 0x1000 // t_xmax is a MultiXactId
-	HEAP_NATTS_MASK        = 0x07FF // 11 bits for number of attributes
+	HEAP_NATTS_MASK   = 0x07FF // 11 bits for number of attributes
+	HEAP_KEYS_UPDATED = 0x2000 // tuple was updated and key cols modified, or tuple deleted
+	HEAP_HOT_UPDATED  = 0x4000 // tuple was HOT-updated
+	HEAP_ONLY_TUPLE   = 0x8000 // this is heap-only tuple
 )
 
+// MaxHeapAttributeNumber is the maximum number of columns a heap tuple can have.
+const MaxHeapAttributeNumber = 1600
+
 // PageXLogRecPtr represents a pointer to a location in the WAL.
 type PageXLogRecPtr struct {
 	XLogID  uint32
 	XRecOff uint32
 }
 
-// ItemIdData represents a line pointer on a page.
+// XLogRecPtr is a byte position in the WAL stream (an LSN).
+type XLogRecPtr uint64
+
+// String formats the LSN the way PostgreSQL does, e.g. 0/16B3A28.
+func (p XLogRecPtr) String() string {
+	return fmt.Sprintf("%X/%X", uint32(p>>32), uint32(p))
+}
+
+// MarshalText implements encoding.TextMarshaler so LSNs appear in their usual form in JSON.
+func (p XLogRecPtr) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// LSN returns the page LSN as an XLogRecPtr.
+func (p PageXLogRecPtr) LSN() XLogRecPtr {
+	return XLogRecPtr(uint64(p.XLogID)<<32 | uint64(p.XRecOff))
+}
+
+// ItemIdData represents a line pointer on a page. On disk the three fields are
+// packed into one 32-bit word; see DecodeItemIdData.
 type ItemIdData struct {
 	LpOff   uint16 // offset to tuple (from start of page)
 	LpFlags uint16 // state of line pointer
@@ -73,46 +206,77 @@ type HeapTupleHeaderData struct {
 		}
 	}
 	
-	TCTID     [6]byte // current TID of this or newer tuple
-	TInfomask2 uint16  // number of attributes + various flags
-	TInfomask  uint16  // various flag bits
-	THoff      uint8   // sizeof header incl. bitmap, padding
-	TBits      []byte  // bitmap of NULLs (flexible array)
+	TCTID      ItemPointerData // current TID of this or newer tuple
+	TInfomask2 uint16          // number of attributes + various flags
+	TInfomask  uint16          // various flag bits
+	THoff      uint8           // sizeof header incl. bitmap, padding
+	TBits      []byte          // bitmap of NULLs (flexible array)
+}
+
+// ItemPointerData identifies a tuple by block number and line pointer number.
+type ItemPointerData struct {
+	BlockNumber  uint32 // block number, stored on disk as two 16-bit halves
+	OffsetNumber uint16 // line pointer number, starting at 1
 }
 
 // ReadHeapPageHeader reads a HeapPageHeaderData from a byte slice.
-func ReadHeapPageHeader(data []byte) HeapPageHeaderData {
+func ReadHeapPageHeader(data []byte, order binary.ByteOrder) HeapPageHeaderData {
 	var header HeapPageHeaderData
-	
+
 	// Read LSN
-	header.PDLSN.XLogID = binary.BigEndian.Uint32(data[0:4])
-	header.PDLSN.XRecOff = binary.BigEndian.Uint32(data[4:8])
-	
+	header.PDLSN.XLogID = order.Uint32(data[0:4])
+	header.PDLSN.XRecOff = order.Uint32(data[4:8])
+
 	// Read checksum and flags
-	header.PDChecksum = binary.BigEndian.Uint16(data[8:10])
-	header.PDFlags = binary.BigEndian.Uint16(data[10:12])
-	
+	header.PDChecksum = order.Uint16(data[8:10])
+	header.PDFlags = order.Uint16(data[10:12])
+
 	// Read free space pointers
-	header.PDLower = binary.BigEndian.Uint16(data[12:14])
-	header.PDUpper = binary.BigEndian.Uint16(data[14:16])
-	header.PDSpecial = binary.BigEndian.Uint16(data[16:18])
-	
+	header.PDLower = order.Uint16(data[12:14])
+	header.PDUpper = order.Uint16(data[14:16])
+	header.PDSpecial = order.Uint16(data[16:18])
+
 	// Read page size version and prune XID
-	header.PDPagesizeVersion = binary.BigEndian.Uint16(data[18:20])
-	header.PDPruneXID = binary.BigEndian.Uint32(data[20:24])
-	
+	header.PDPagesizeVersion = order.Uint16(data[18:20])
+	header.PDPruneXID = order.Uint32(data[20:24])
+
 	return header
 }
 
+// PageGetPageSize returns the page size recorded in pd_pagesize_version.
+func PageGetPageSize(header HeapPageHeaderData) int {
+	return int(header.PDPagesizeVersion & 0xFF00)
+}
+
+// PageGetPageLayoutVersion returns the layout version recorded in pd_pagesize_version.
+func PageGetPageLayoutVersion(header HeapPageHeaderData) int {
+	return int(header.PDPagesizeVersion & 0x00FF)
+}
+
 // ReadItemIdData reads an ItemIdData from a byte slice at the given offset.
-func ReadItemIdData(data []byte, offset int) ItemIdData {
+func ReadItemIdData(data []byte, offset int, order binary.ByteOrder) ItemIdData {
+	// The line pointer is a single 32-bit word holding lp_off:15, lp_flags:2
+	// and lp_len:15 as C bit-fields.
+	return DecodeItemIdData(order.Uint32(data[offset:offset+SizeOfItemIdData]), order)
+}
+
+// DecodeItemIdData unpacks the bit-fields of a line pointer word. C compilers
+// allocate bit-fields from the least significant bit on little-endian targets
+// and from the most significant bit on big-endian ones, so the field positions
+// depend on the byte order the page was written in.
+func DecodeItemIdData(word uint32, order binary.ByteOrder) ItemIdData {
 	var itemId ItemIdData
-	
-	// Read offset, flags, and length
-	itemId.LpOff = binary.BigEndian.Uint16(data[offset : offset+2])
-	itemId.LpFlags = binary.BigEndian.Uint16(data[offset+2 : offset+4]) & 0x0003 // only 2 bits for flags
-	itemId.LpLen = binary.BigEndian.Uint16(data[offset+2 : offset+4]) >> 2       // remaining 14 bits for length
-	
+
+	if order == binary.BigEndian {
+		itemId.LpOff = uint16(word >> 17)
+		itemId.LpFlags = uint16(word>>15) & 0x0003
+		itemId.LpLen = uint16(word) & 0x7FFF
+	} else {
+		itemId.LpOff = uint16(word) & 0x7FFF
+		itemId.LpFlags = uint16(word>>15) & 0x0003
+		itemId.LpLen = uint16(word >> 17)
+	}
+
 	return itemId
 }
 
@@ -140,3 +304,105 @@ func ItemIdGetLength(itemId ItemIdData) uint16 {
 func ItemIdGetFlags(itemId ItemIdData) uint16 {
 	return itemId.LpFlags
 }
+
+// ReadHeapTupleHeader reads the fixed part of a HeapTupleHeaderData from a
+// byte slice. The slice must hold at least SizeOfHeapTupleHeader bytes. The
+// null bitmap is not read here because its length depends on the number of
+// attributes, which the caller has to validate first.
+func ReadHeapTupleHeader(data []byte, order binary.ByteOrder) HeapTupleHeaderData {
+	var header HeapTupleHeaderData
+
+	// Read transaction IDs
+	header.THeap.TXmin = order.Uint32(data[0:4])
+	header.THeap.TXmax = order.Uint32(data[4:8])
+
+	// Read infomask bits, which decide how t_field3 is interpreted
+	header.TInfomask2 = order.Uint16(data[18:20])
+	header.TInfomask = order.Uint16(data[20:22])
+
+	// Read t_cid or t_xvac (they share storage)
+	field3 := order.Uint32(data[8:12])
+	if header.TInfomask&HEAP_MOVED != 0 {
+		header.THeap.TField3.TXvac = field3
+	} else {
+		header.THeap.TField3.TCid = field3
+	}
+
+	// Read current TID
+	header.TCTID = ReadItemPointerData(data, 12, order)
+
+	// Read header size
+	header.THoff = data[22]
+
+	return header
+}
+
+// ReadItemPointerData reads an ItemPointerData from a byte slice at the given offset.
+func ReadItemPointerData(data []byte, offset int, order binary.ByteOrder) ItemPointerData {
+	biHi := order.Uint16(data[offset : offset+2])
+	biLo := order.Uint16(data[offset+2 : offset+4])
+
+	return ItemPointerData{
+		BlockNumber:  uint32(biHi)<<16 | uint32(biLo),
+		OffsetNumber: order.Uint16(data[offset+4 : offset+6]),
+	}
+}
+
+// BitmapLen returns the number of bytes needed for a null bitmap covering natts attributes.
+func BitmapLen(natts int) int {
+	return (natts + 7) / 8
+}
+
+// HeapTupleHeaderGetNatts gets the number of attributes stored in a tuple.
+func HeapTupleHeaderGetNatts(header HeapTupleHeaderData) int {
+	return int(header.TInfomask2 & HEAP_NATTS_MASK)
+}
+
+// HeapTupleHasNulls checks if a tuple has a null bitmap.
+func HeapTupleHasNulls(header HeapTupleHeaderData) bool {
+	return header.TInfomask&HEAP_HASNULL != 0
+}
+
+// HeapTupleHeaderIsHotUpdated checks if a tuple was HOT-updated.
+func HeapTupleHeaderIsHotUpdated(header HeapTupleHeaderData) bool {
+	return header.TInfomask2&HEAP_HOT_UPDATED != 0
+}
+
+// HeapTupleHeaderIsHeapOnly checks if a tuple is a heap-only tuple.
+func HeapTupleHeaderIsHeapOnly(header HeapTupleHeaderData) bool {
+	return header.TInfomask2&HEAP_ONLY_TUPLE != 0
+}
+
+// HeapTupleHeaderKeysUpdated checks if a tuple was deleted or had key columns updated.
+func HeapTupleHeaderKeysUpdated(header HeapTupleHeaderData) bool {
+	return header.TInfomask2&HEAP_KEYS_UPDATED != 0
+}
+
+// HeapXmaxIsLockedOnly checks if the xmax of a tuple only locked it, rather
+// than deleting or updating it. Before 9.3 an exclusive lock did not set
+// HEAP_XMAX_LOCK_ONLY.
+func HeapXmaxIsLockedOnly(infomask uint16) bool {
+	return infomask&HEAP_XMAX_LOCK_ONLY != 0 ||
+		infomask&(HEAP_XMAX_IS_MULTI|HEAP_LOCK_MASK) == HEAP_XMAX_EXCL_LOCK
+}
+
+// HeapLockedUpgraded checks if the xmax of a tuple is a multixact of shared
+// lockers from before 9.3, which pg_upgrade kept but pg_multixact no longer
+// describes.
+func HeapLockedUpgraded(infomask uint16) bool {
+	return infomask&HEAP_XMAX_IS_MULTI != 0 && infomask&HEAP_XMAX_LOCK_ONLY != 0 &&
+		infomask&(HEAP_XMAX_EXCL_LOCK|HEAP_XMAX_KEYSHR_LOCK) == 0
+}
+
+// HeapTupleHeaderAttIsNull checks if the attribute with the given zero-based
+// index is null according to the tuple's null bitmap. Tuples without a
+// bitmap have no nulls.
+func HeapTupleHeaderAttIsNull(header HeapTupleHeaderData, attnum int) bool {
+	if !HeapTupleHasNulls(header) {
+		return false
+	}
+	if attnum>>3 >= len(header.TBits) {
+		return true
+	}
+	return header.TBits[attnum>>3]&(1<<uint(attnum&0x07)) == 0
+}
@@ -0,0 +1,96 @@
+package pgtypes
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeItemIdData(t *testing.T) {
+	tests := []struct {
+		order binary.ByteOrder
+		bytes []byte // the line pointer as stored on the page
+		want  ItemIdData
+	}{
+		// lp_off 8144, lp_flags LP_NORMAL, lp_len 48
+		{binary.LittleEndian, []byte{0xD0, 0x9F, 0x60, 0x00}, ItemIdData{LpOff: 8144, LpFlags: LP_NORMAL, LpLen: 48}},
+		{binary.BigEndian, []byte{0x3F, 0xA0, 0x80, 0x30}, ItemIdData{LpOff: 8144, LpFlags: LP_NORMAL, LpLen: 48}},
+		// Redirect to item 5: lp_off is the target, lp_len 0
+		{binary.LittleEndian, []byte{0x05, 0x00, 0x01, 0x00}, ItemIdData{LpOff: 5, LpFlags: LP_REDIRECT}},
+		{binary.BigEndian, []byte{0x00, 0x0B, 0x00, 0x00}, ItemIdData{LpOff: 5, LpFlags: LP_REDIRECT}},
+		// Dead item with storage, at the largest offset and length
+		{binary.LittleEndian, []byte{0xFF, 0xFF, 0xFF, 0xFF}, ItemIdData{LpOff: 0x7FFF, LpFlags: LP_DEAD, LpLen: 0x7FFF}},
+		{binary.BigEndian, []byte{0xFF, 0xFF, 0xFF, 0xFF}, ItemIdData{LpOff: 0x7FFF, LpFlags: LP_DEAD, LpLen: 0x7FFF}},
+		// Unused
+		{binary.LittleEndian, []byte{0, 0, 0, 0}, ItemIdData{}},
+	}
+
+	for _, test := range tests {
+		data := append([]byte{0xEE, 0xEE}, test.bytes...)
+		if got := ReadItemIdData(data, 2, test.order); got != test.want {
+			t.Errorf("%s % x: %+v, want %+v", test.order, test.bytes, got, test.want)
+		}
+		if got := DecodeItemIdData(test.order.Uint32(test.bytes), test.order); got != test.want {
+			t.Errorf("%s % x: decoded %+v, want %+v", test.order, test.bytes, got, test.want)
+		}
+	}
+}
+
+func TestReadHeapTupleHeader(t *testing.T) {
+	want := HeapTupleHeaderData{
+		TCTID:      ItemPointerData{BlockNumber: 0x00010002, OffsetNumber: 3},
+		TInfomask2: 3 | HEAP_HOT_UPDATED,
+		TInfomask:  HEAP_HASNULL | HEAP_XMIN_COMMITTED | HEAP_XMAX_INVALID,
+		THoff:      24,
+	}
+	want.THeap.TXmin = 745
+
+	// xmin 745, xmax 0, cid 0, ctid (65538,3), 3 attributes, hoff 24
+	fixtures := map[binary.ByteOrder][]byte{
+		binary.LittleEndian: {
+			0xE9, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+			0x03, 0x40, 0x01, 0x09, 0x18,
+		},
+		binary.BigEndian: {
+			0x00, 0x00, 0x02, 0xE9, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x01, 0x00, 0x02, 0x00, 0x03,
+			0x40, 0x03, 0x09, 0x01, 0x18,
+		},
+	}
+
+	for order, data := range fixtures {
+		header := ReadHeapTupleHeader(data, order)
+		if header.THeap != want.THeap || header.TCTID != want.TCTID || header.TInfomask2 != want.TInfomask2 ||
+			header.TInfomask != want.TInfomask || header.THoff != want.THoff {
+			t.Errorf("%s: %+v, want %+v", order, header, want)
+		}
+		if HeapTupleHeaderGetNatts(header) != 3 || !HeapTupleHeaderIsHotUpdated(header) || HeapTupleHeaderIsHeapOnly(header) {
+			t.Errorf("%s: natts %d, HOT updated %v, heap-only %v", order, HeapTupleHeaderGetNatts(header),
+				HeapTupleHeaderIsHotUpdated(header), HeapTupleHeaderIsHeapOnly(header))
+		}
+
+		// t_field3 is t_xvac for tuples moved by VACUUM FULL before 9.0
+		moved := append([]byte(nil), data...)
+		order.PutUint16(moved[20:], HEAP_MOVED_OFF)
+		order.PutUint32(moved[8:], 700)
+		if header := ReadHeapTupleHeader(moved, order); header.THeap.TField3.TXvac != 700 || header.THeap.TField3.TCid != 0 {
+			t.Errorf("%s: moved tuple has xvac %d, cid %d", order, header.THeap.TField3.TXvac, header.THeap.TField3.TCid)
+		}
+	}
+}
+
+func TestHeapTupleHeaderAttIsNull(t *testing.T) {
+	// Attributes 2 and 10 are null, and so are those beyond the bitmap
+	header := HeapTupleHeaderData{TInfomask: HEAP_HASNULL, TBits: []byte{0xFD, 0xFD}}
+	for attnum, want := range map[int]bool{0: false, 1: true, 2: false, 9: true, 15: false, 16: true} {
+		if got := HeapTupleHeaderAttIsNull(header, attnum); got != want {
+			t.Errorf("attribute %d: null %v, want %v", attnum, got, want)
+		}
+	}
+
+	// Without HEAP_HASNULL the bitmap is not consulted
+	header.TInfomask = 0
+	if HeapTupleHeaderAttIsNull(header, 1) {
+		t.Errorf("attribute 1 is null without a bitmap")
+	}
+}
@@ -0,0 +1,182 @@
+package pgtypes
+
+import "strings"
+
+// Built-in type OIDs (pg_type.oid)
+const (
+	BOOLOID         = 16
+	BYTEAOID        = 17
+	CHAROID         = 18
+	NAMEOID         = 19
+	INT8OID         = 20
+	INT2OID         = 21
+	INT2VECTOROID   = 22
+	INT4OID         = 23
+	REGPROCOID      = 24
+	TEXTOID         = 25
+	OIDOID          = 26
+	TIDOID          = 27
+	XIDOID          = 28
+	CIDOID          = 29
+	OIDVECTOROID    = 30
+	JSONOID         = 114
+	XMLOID          = 142
+	PGNODETREEOID   = 194
+	CIDROID         = 650
+	FLOAT4OID       = 700
+	FLOAT8OID       = 701
+	MONEYOID        = 790
+	MACADDROID      = 829
+	INETOID         = 869
+	INT2ARRAYOID    = 1005
+	INT4ARRAYOID    = 1007
+	TEXTARRAYOID    = 1009
+	VARCHARARRAYOID = 1015
+	INT8ARRAYOID    = 1016
+	FLOAT8ARRAYOID  = 1022
+	ACLITEMOID      = 1033
+	ACLITEMARRAYOID = 1034
+	BPCHAROID       = 1042
+	VARCHAROID      = 1043
+	DATEOID         = 1082
+	TIMEOID         = 1083
+	TIMESTAMPOID    = 1114
+	TIMESTAMPTZOID  = 1184
+	INTERVALOID     = 1186
+	TIMETZOID       = 1266
+	BITOID          = 1560
+	VARBITOID       = 1562
+	NUMERICOID      = 1700
+	REGCLASSOID     = 2205
+	REGTYPEOID      = 2206
+	ANYARRAYOID     = 2277
+	UUIDOID         = 2950
+	PGLSNOID        = 3220
+	JSONBOID        = 3802
+)
+
+// TypeInfo describes how values of a type are stored, mirroring the pg_type
+// fields the tuple deformer needs.
+type TypeInfo struct {
+	Oid     uint32 // type OID
+	Name    string // type name as in pg_type.typname
+	Len     int16  // typlen: fixed length, -1 for varlena, -2 for cstring
+	Align   byte   // typalign: 'c', 's', 'i' or 'd'
+	ByVal   bool   // typbyval
+	Storage byte   // typstorage: 'p', 'e', 'm' or 'x'
+}
+
+// builtinTypes lists the storage properties of common built-in types.
+var builtinTypes = []TypeInfo{
+	{BOOLOID, "bool", 1, 'c', true, 'p'},
+	{BYTEAOID, "bytea", -1, 'i', false, 'x'},
+	{CHAROID, "char", 1, 'c', true, 'p'},
+	{NAMEOID, "name", NAMEDATALEN, 'c', false, 'p'},
+	{INT8OID, "int8", 8, 'd', true, 'p'},
+	{INT2OID, "int2", 2, 's', true, 'p'},
+	{INT2VECTOROID, "int2vector", -1, 'i', false, 'p'},
+	{INT4OID, "int4", 4, 'i', true, 'p'},
+	{REGPROCOID, "regproc", 4, 'i', true, 'p'},
+	{TEXTOID, "text", -1, 'i', false, 'x'},
+	{OIDOID, "oid", 4, 'i', true, 'p'},
+	{TIDOID, "tid", 6, 's', false, 'p'},
+	{XIDOID, "xid", 4, 'i', true, 'p'},
+	{CIDOID, "cid", 4, 'i', true, 'p'},
+	{OIDVECTOROID, "oidvector", -1, 'i', false, 'p'},
+	{JSONOID, "json", -1, 'i', false, 'x'},
+	{XMLOID, "xml", -1, 'i', false, 'x'},
+	{PGNODETREEOID, "pg_node_tree", -1, 'i', false, 'x'},
+	{CIDROID, "cidr", -1, 'i', false, 'm'},
+	{FLOAT4OID, "float4", 4, 'i', true, 'p'},
+	{FLOAT8OID, "float8", 8, 'd', true, 'p'},
+	{MONEYOID, "money", 8, 'd', true, 'p'},
+	{MACADDROID, "macaddr", 6, 'i', false, 'p'},
+	{INETOID, "inet", -1, 'i', false, 'm'},
+	{INT2ARRAYOID, "_int2", -1, 'i', false, 'x'},
+	{INT4ARRAYOID, "_int4", -1, 'i', false, 'x'},
+	{TEXTARRAYOID, "_text", -1, 'i', false, 'x'},
+	{VARCHARARRAYOID, "_varchar", -1, 'i', false, 'x'},
+	{INT8ARRAYOID, "_int8", -1, 'd', false, 'x'},
+	{FLOAT8ARRAYOID, "_float8", -1, 'd', false, 'x'},
+	{ACLITEMOID, "aclitem", 12, 'i', false, 'p'},
+	{ACLITEMARRAYOID, "_aclitem", -1, 'i', false, 'x'},
+	{BPCHAROID, "bpchar", -1, 'i', false, 'x'},
+	{VARCHAROID, "varchar", -1, 'i', false, 'x'},
+	{DATEOID, "date", 4, 'i', true, 'p'},
+	{TIMEOID, "time", 8, 'd', true, 'p'},
+	{TIMESTAMPOID, "timestamp", 8, 'd', true, 'p'},
+	{TIMESTAMPTZOID, "timestamptz", 8, 'd', true, 'p'},
+	{INTERVALOID, "interval", 16, 'd', false, 'p'},
+	{TIMETZOID, "timetz", 12, 'd', false, 'p'},
+	{BITOID, "bit", -1, 'i', false, 'x'},
+	{VARBITOID, "varbit", -1, 'i', false, 'x'},
+	{NUMERICOID, "numeric", -1, 'i', false, 'm'},
+	{REGCLASSOID, "regclass", 4, 'i', true, 'p'},
+	{REGTYPEOID, "regtype", 4, 'i', true, 'p'},
+	{ANYARRAYOID, "anyarray", -1, 'd', false, 'x'},
+	{UUIDOID, "uuid", 16, 'c', false, 'p'},
+	{PGLSNOID, "pg_lsn", 8, 'd', true, 'p'},
+	{JSONBOID, "jsonb", -1, 'i', false, 'x'},
+}
+
+// typeAliases maps SQL spellings of types to their pg_type names.
+var typeAliases = map[string]string{
+	"boolean":                     "bool",
+	"smallint":                    "int2",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"bigint":                      "int8",
+	"real":                        "float4",
+	"double precision":            "float8",
+	"float":                       "float8",
+	"decimal":                     "numeric",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"\"char\"":                    "char",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+	"bit varying":                 "varbit",
+	"smallserial":                 "int2",
+	"serial":                      "int4",
+	"bigserial":                   "int8",
+}
+
+// LookupTypeByOid finds a built-in type by OID.
+func LookupTypeByOid(oid uint32) (TypeInfo, bool) {
+	for _, t := range builtinTypes {
+		if t.Oid == oid {
+			return t, true
+		}
+	}
+	return TypeInfo{}, false
+}
+
+// LookupTypeByName finds a built-in type by its pg_type name or SQL spelling,
+// e.g. "int4", "integer" or "character varying". Array types can be given as
+// "text[]" or "_text". Note that "char" is the single-byte internal type, as
+// in pg_type; the SQL type char(n) is "bpchar" or "character".
+func LookupTypeByName(name string) (TypeInfo, bool) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+
+	// Arrays
+	if strings.HasSuffix(name, "[]") {
+		element, ok := LookupTypeByName(strings.TrimSuffix(name, "[]"))
+		if !ok {
+			return TypeInfo{}, false
+		}
+		name = "_" + element.Name
+	}
+
+	// Exact pg_type names take precedence over SQL spellings
+	for _, t := range builtinTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	if alias, ok := typeAliases[name]; ok {
+		return LookupTypeByName(alias)
+	}
+	return TypeInfo{}, false
+}
@@ -0,0 +1,383 @@
+package pgtypes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultVersionNum is the PostgreSQL version assumed when the version of a
+// cluster cannot be detected.
+const DefaultVersionNum = 150000
+
+// CatalogColumn describes one column of a system catalog.
+type CatalogColumn struct {
+	Name string // column name
+	Type string // pg_type name of the column type
+}
+
+// Profile describes the on-disk layout differences of one PostgreSQL major
+// version. Code that depends on the version consults the profile instead of
+// comparing version numbers itself.
+type Profile struct {
+	// Major version as a PG_VERSION_NUM value, e.g. 90600 or 150000
+	VersionNum int
+
+	// Major version as written in PG_VERSION, e.g. "9.6" or "15"
+	Name string
+
+	// catversion of the release
+	CatalogVersion uint32
+
+	// PG_CONTROL_VERSION of the release
+	ControlVersion uint32
+
+	// XLOG_PAGE_MAGIC of the release
+	XLogPageMagic uint16
+
+	// Tuples can carry an OID in the header (HEAP_HASOID, before 12)
+	TuplesHaveOids bool
+
+	// Catalog OIDs are stored as a regular first column (12 and later)
+	CatalogOidColumn bool
+
+	// pg_attribute has atthasmissing/attmissingval (11 and later)
+	AttributeMissingValues bool
+
+	// TOAST pointers and compressed datums record a compression method (14 and later)
+	ToastCompressionMethods bool
+
+	// xl_heap_freeze_page uses deduplicated freeze plans (16 and later)
+	HeapFreezePlans bool
+
+	// Pruning and freezing share a single xl_heap_prune record (17 and later)
+	HeapPruneFreezeCombined bool
+
+	// aclitem is 16 bytes with double alignment (16 and later)
+	WideAclItem bool
+
+	// Column sets of the system catalogs needed to bootstrap metadata
+	Catalogs map[string][]CatalogColumn
+}
+
+// String returns the version name.
+func (p *Profile) String() string {
+	return "PostgreSQL " + p.Name
+}
+
+// Major returns the major version number: 9 for 9.x releases, otherwise the first component.
+func (p *Profile) Major() int {
+	return p.VersionNum / 10000
+}
+
+// Catalog returns the column set of a system catalog, e.g. "pg_class".
+func (p *Profile) Catalog(name string) ([]CatalogColumn, bool) {
+	columns, ok := p.Catalogs[name]
+	return columns, ok
+}
+
+// LookupType finds a built-in type by name, adjusted to the storage it has in this version.
+func (p *Profile) LookupType(name string) (TypeInfo, bool) {
+	t, ok := LookupTypeByName(name)
+	if !ok {
+		return t, false
+	}
+	if p.WideAclItem {
+		switch t.Oid {
+		case ACLITEMOID:
+			t.Len, t.Align = 16, 'd'
+		case ACLITEMARRAYOID:
+			t.Align = 'd'
+		}
+	}
+	return t, true
+}
+
+// releases lists the supported major versions with their catversion,
+// pg_control version and WAL page magic.
+var releases = []struct {
+	versionNum     int
+	name           string
+	catalogVersion uint32
+	controlVersion uint32
+	xlogPageMagic  uint16
+}{
+	{90600, "9.6", 201608131, 960, 0xD093},
+	{100000, "10", 201707211, 1002, 0xD097},
+	{110000, "11", 201809051, 1100, 0xD098},
+	{120000, "12", 201909212, 1201, 0xD101},
+	{130000, "13", 202007201, 1300, 0xD106},
+	{140000, "14", 202107181, 1300, 0xD10D},
+	{150000, "15", 202209061, 1300, 0xD110},
+	{160000, "16", 202307071, 1300, 0xD113},
+	{170000, "17", 202406281, 1700, 0xD116},
+	{180000, "18", 202506291, 1800, 0xD118},
+}
+
+// profiles holds one Profile per supported release, in version order.
+var profiles = buildProfiles()
+
+// buildProfiles derives the profile of every supported release.
+func buildProfiles() []*Profile {
+	var result []*Profile
+	for _, r := range releases {
+		v := r.versionNum
+		result = append(result, &Profile{
+			VersionNum:              v,
+			Name:                    r.name,
+			CatalogVersion:          r.catalogVersion,
+			ControlVersion:          r.controlVersion,
+			XLogPageMagic:           r.xlogPageMagic,
+			TuplesHaveOids:          v < 120000,
+			CatalogOidColumn:        v >= 120000,
+			AttributeMissingValues:  v >= 110000,
+			ToastCompressionMethods: v >= 140000,
+			HeapFreezePlans:         v >= 160000,
+			HeapPruneFreezeCombined: v >= 170000,
+			WideAclItem:             v >= 160000,
+			Catalogs: map[string][]CatalogColumn{
+				"pg_database":  pgDatabaseColumns(v),
+				"pg_namespace": pgNamespaceColumns(v),
+				"pg_class":     pgClassColumns(v),
+				"pg_attribute": pgAttributeColumns(v),
+				"pg_type":      pgTypeColumns(v),
+			},
+		})
+	}
+	return result
+}
+
+// SupportedProfiles returns the profiles of all supported releases.
+func SupportedProfiles() []*Profile {
+	return profiles
+}
+
+// DefaultProfile returns the profile of DefaultVersionNum.
+func DefaultProfile() *Profile {
+	p, _ := ProfileFor(DefaultVersionNum)
+	return p
+}
+
+// ProfileFor returns the profile of a major version given as a PG_VERSION_NUM
+// value. Minor version digits are ignored.
+func ProfileFor(versionNum int) (*Profile, error) {
+	major := versionNum - versionNum%100
+	if versionNum >= 100000 {
+		major = versionNum - versionNum%10000
+	}
+	for _, p := range profiles {
+		if p.VersionNum == major {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported PostgreSQL version %d", versionNum)
+}
+
+// ParseVersionName converts a major version as written in PG_VERSION
+// ("9.6", "15") into a PG_VERSION_NUM value.
+func ParseVersionName(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	parts := strings.Split(name, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major <= 0 {
+		return 0, fmt.Errorf("invalid PostgreSQL version %q", name)
+	}
+	if major >= 10 {
+		return major * 10000, nil
+	}
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid PostgreSQL version %q", name)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid PostgreSQL version %q", name)
+	}
+	return major*10000 + minor*100, nil
+}
+
+// ProfileForCatalogVersion returns the profile of the release a catversion
+// belongs to: the newest release whose catversion is not later than it.
+// Development snapshots map to the release they branched from.
+func ProfileForCatalogVersion(catalogVersion uint32) (*Profile, error) {
+	var found *Profile
+	for _, p := range profiles {
+		if p.CatalogVersion <= catalogVersion {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unsupported catalog version %d", catalogVersion)
+	}
+	return found, nil
+}
+
+// columns builds a column list from alternating name and type strings.
+func columns(nameTypes ...string) []CatalogColumn {
+	result := make([]CatalogColumn, 0, len(nameTypes)/2)
+	for i := 0; i+1 < len(nameTypes); i += 2 {
+		result = append(result, CatalogColumn{Name: nameTypes[i], Type: nameTypes[i+1]})
+	}
+	return result
+}
+
+// withOid prepends the oid column for releases that store it as a regular column.
+func withOid(v int, cols []CatalogColumn) []CatalogColumn {
+	if v < 120000 {
+		return cols
+	}
+	return append(columns("oid", "oid"), cols...)
+}
+
+// pgDatabaseColumns returns the columns of pg_database.
+func pgDatabaseColumns(v int) []CatalogColumn {
+	switch {
+	case v < 150000:
+		return withOid(v, columns(
+			"datname", "name", "datdba", "oid", "encoding", "int4",
+			"datcollate", "name", "datctype", "name",
+			"datistemplate", "bool", "datallowconn", "bool", "datconnlimit", "int4",
+			"datlastsysoid", "oid", "datfrozenxid", "xid", "datminmxid", "xid",
+			"dattablespace", "oid", "datacl", "_aclitem",
+		))
+	case v < 160000:
+		return withOid(v, columns(
+			"datname", "name", "datdba", "oid", "encoding", "int4", "datlocprovider", "char",
+			"datistemplate", "bool", "datallowconn", "bool", "datconnlimit", "int4",
+			"datfrozenxid", "xid", "datminmxid", "xid", "dattablespace", "oid",
+			"datcollate", "text", "datctype", "text", "daticulocale", "text",
+			"datcollversion", "text", "datacl", "_aclitem",
+		))
+	case v < 170000:
+		return withOid(v, columns(
+			"datname", "name", "datdba", "oid", "encoding", "int4", "datlocprovider", "char",
+			"datistemplate", "bool", "datallowconn", "bool", "datconnlimit", "int4",
+			"datfrozenxid", "xid", "datminmxid", "xid", "dattablespace", "oid",
+			"datcollate", "text", "datctype", "text", "daticulocale", "text",
+			"daticurules", "text", "datcollversion", "text", "datacl", "_aclitem",
+		))
+	default:
+		return withOid(v, columns(
+			"datname", "name", "datdba", "oid", "encoding", "int4", "datlocprovider", "char",
+			"datistemplate", "bool", "datallowconn", "bool", "dathasloginevt", "bool",
+			"datconnlimit", "int4", "datfrozenxid", "xid", "datminmxid", "xid", "dattablespace", "oid",
+			"datcollate", "text", "datctype", "text", "datlocale", "text",
+			"daticurules", "text", "datcollversion", "text", "datacl", "_aclitem",
+		))
+	}
+}
+
+// pgNamespaceColumns returns the columns of pg_namespace.
+func pgNamespaceColumns(v int) []CatalogColumn {
+	return withOid(v, columns("nspname", "name", "nspowner", "oid", "nspacl", "_aclitem"))
+}
+
+// pgClassColumns returns the columns of pg_class.
+func pgClassColumns(v int) []CatalogColumn {
+	cols := columns(
+		"relname", "name", "relnamespace", "oid", "reltype", "oid", "reloftype", "oid",
+		"relowner", "oid", "relam", "oid", "relfilenode", "oid", "reltablespace", "oid",
+		"relpages", "int4", "reltuples", "float4", "relallvisible", "int4",
+	)
+	if v >= 180000 {
+		cols = append(cols, columns("relallfrozen", "int4")...)
+	}
+	cols = append(cols, columns(
+		"reltoastrelid", "oid", "relhasindex", "bool", "relisshared", "bool",
+		"relpersistence", "char", "relkind", "char", "relnatts", "int2", "relchecks", "int2",
+	)...)
+	if v < 120000 {
+		cols = append(cols, columns("relhasoids", "bool")...)
+	}
+	if v < 110000 {
+		cols = append(cols, columns("relhaspkey", "bool")...)
+	}
+	cols = append(cols, columns(
+		"relhasrules", "bool", "relhastriggers", "bool", "relhassubclass", "bool",
+		"relrowsecurity", "bool", "relforcerowsecurity", "bool", "relispopulated", "bool",
+		"relreplident", "char",
+	)...)
+	if v >= 100000 {
+		cols = append(cols, columns("relispartition", "bool")...)
+	}
+	if v >= 110000 {
+		cols = append(cols, columns("relrewrite", "oid")...)
+	}
+	cols = append(cols, columns(
+		"relfrozenxid", "xid", "relminmxid", "xid", "relacl", "_aclitem", "reloptions", "_text",
+	)...)
+	if v >= 100000 {
+		cols = append(cols, columns("relpartbound", "pg_node_tree")...)
+	}
+	return withOid(v, cols)
+}
+
+// pgAttributeColumns returns the columns of pg_attribute.
+func pgAttributeColumns(v int) []CatalogColumn {
+	var cols []CatalogColumn
+	switch {
+	case v < 140000:
+		cols = columns(
+			"attrelid", "oid", "attname", "name", "atttypid", "oid", "attstattarget", "int4",
+			"attlen", "int2", "attnum", "int2", "attndims", "int4", "attcacheoff", "int4",
+			"atttypmod", "int4", "attbyval", "bool", "attstorage", "char", "attalign", "char",
+			"attnotnull", "bool", "atthasdef", "bool",
+		)
+	case v < 160000:
+		cols = columns(
+			"attrelid", "oid", "attname", "name", "atttypid", "oid", "attstattarget", "int4",
+			"attlen", "int2", "attnum", "int2", "attndims", "int4", "attcacheoff", "int4",
+			"atttypmod", "int4", "attbyval", "bool", "attalign", "char", "attstorage", "char",
+			"attcompression", "char", "attnotnull", "bool", "atthasdef", "bool",
+		)
+	default:
+		cols = columns(
+			"attrelid", "oid", "attname", "name", "atttypid", "oid",
+			"attlen", "int2", "attnum", "int2", "attcacheoff", "int4", "atttypmod", "int4",
+			"attndims", "int2", "attbyval", "bool", "attalign", "char", "attstorage", "char",
+			"attcompression", "char", "attnotnull", "bool", "atthasdef", "bool",
+		)
+	}
+	if v >= 110000 {
+		cols = append(cols, columns("atthasmissing", "bool")...)
+	}
+	if v >= 100000 {
+		cols = append(cols, columns("attidentity", "char")...)
+	}
+	if v >= 120000 {
+		cols = append(cols, columns("attgenerated", "char")...)
+	}
+	cols = append(cols, columns("attisdropped", "bool", "attislocal", "bool")...)
+	switch {
+	case v < 160000:
+		cols = append(cols, columns("attinhcount", "int4", "attcollation", "oid")...)
+	case v < 170000:
+		cols = append(cols, columns("attinhcount", "int2", "attstattarget", "int2", "attcollation", "oid")...)
+	default:
+		cols = append(cols, columns("attinhcount", "int2", "attcollation", "oid", "attstattarget", "int2")...)
+	}
+	cols = append(cols, columns("attacl", "_aclitem", "attoptions", "_text", "attfdwoptions", "_text")...)
+	if v >= 110000 {
+		cols = append(cols, columns("attmissingval", "anyarray")...)
+	}
+	return cols
+}
+
+// pgTypeColumns returns the columns of pg_type.
+func pgTypeColumns(v int) []CatalogColumn {
+	cols := columns(
+		"typname", "name", "typnamespace", "oid", "typowner", "oid", "typlen", "int2",
+		"typbyval", "bool", "typtype", "char", "typcategory", "char", "typispreferred", "bool",
+		"typisdefined", "bool", "typdelim", "char", "typrelid", "oid",
+	)
+	if v >= 140000 {
+		cols = append(cols, columns("typsubscript", "regproc")...)
+	}
+	cols = append(cols, columns(
+		"typelem", "oid", "typarray", "oid", "typinput", "regproc", "typoutput", "regproc",
+		"typreceive", "regproc", "typsend", "regproc", "typmodin", "regproc", "typmodout", "regproc",
+		"typanalyze", "regproc", "typalign", "char", "typstorage", "char", "typnotnull", "bool",
+		"typbasetype", "oid", "typtypmod", "int4", "typndims", "int4", "typcollation", "oid",
+		"typdefaultbin", "pg_node_tree", "typdefault", "text", "typacl", "_aclitem",
+	)...)
+	return withOid(v, cols)
+}